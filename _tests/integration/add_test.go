@@ -43,7 +43,7 @@ func TestAdd(t *testing.T) {
 
 		cont, err := fileutil.ReadStringFromFile(envstore)
 		require.NoError(t, err, out)
-		require.Equal(t, "envs:\n- KEY: value\n", cont)
+		require.Equal(t, "format_version: 1\nenvs:\n- KEY: value\n", cont)
 	}
 
 	t.Log("add sensitive env")
@@ -53,7 +53,7 @@ func TestAdd(t *testing.T) {
 
 		cont, err := fileutil.ReadStringFromFile(envstore)
 		require.NoError(t, err, out)
-		require.Equal(t, "envs:\n- KEY: value\n  opts:\n    is_sensitive: true\n", cont)
+		require.Equal(t, "format_version: 1\nenvs:\n- KEY: value\n  opts:\n    is_sensitive: true\n", cont)
 	}
 
 	t.Log("add file flag value")
@@ -66,7 +66,7 @@ func TestAdd(t *testing.T) {
 
 		cont, err := fileutil.ReadStringFromFile(envstore)
 		require.NoError(t, err, out)
-		require.Equal(t, "envs:\n- KEY: some content\n", cont)
+		require.Equal(t, "format_version: 1\nenvs:\n- KEY: some content\n", cont)
 	}
 
 	t.Log("add piped value")
@@ -76,7 +76,7 @@ func TestAdd(t *testing.T) {
 
 		cont, err := fileutil.ReadStringFromFile(envstore)
 		require.NoError(t, err, out)
-		require.Equal(t, "envs:\n- KEY: some piped value\n", cont)
+		require.Equal(t, "format_version: 1\nenvs:\n- KEY: some piped value\n", cont)
 	}
 
 	t.Log("add piped value - zero EnvBytesLimitInKB")
@@ -146,6 +146,6 @@ To increase env var limits please visit: https://support.bitrise.io/en/articles/
 
 		cont, err := fileutil.ReadStringFromFile(envstore)
 		require.NoError(t, err, out)
-		require.Equal(t, "envs:\n- KEY: \"\"\n", cont)
+		require.Equal(t, "format_version: 1\nenvs:\n- KEY: \"\"\n", cont)
 	}
 }
@@ -0,0 +1,27 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirBackend(t *testing.T) {
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, "envs")
+	location := "dir://" + dir
+
+	require.NoError(t, EnvmanInitAtPath(location))
+	require.NoError(t, EnvmanAdd(location, "GREETING", "hello", true, false))
+	require.NoError(t, EnvmanAdd(location, "MESSAGE", "$GREETING world", true, false))
+
+	content, err := os.ReadFile(filepath.Join(dir, "MESSAGE.yml"))
+	require.NoError(t, err)
+	require.Equal(t, "- MESSAGE: $GREETING world\n", string(content))
+
+	out, err := EnvmanRun(location, tmpDir, []string{"bash", "-c", "echo $MESSAGE"})
+	require.NoError(t, err, out)
+	require.Equal(t, "hello world", out)
+}
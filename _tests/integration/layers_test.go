@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayers(t *testing.T) {
+	tmpDir := t.TempDir()
+	basePth := filepath.Join(tmpDir, "base.yml")
+	jobPth := filepath.Join(tmpDir, "job.yml")
+	require.NoError(t, os.WriteFile(basePth, []byte("envs:\n- GREETING: hello\n"), 0644))
+	require.NoError(t, os.WriteFile(jobPth, []byte("envs: []\n"), 0644))
+
+	layers := strings.Join([]string{basePth, jobPth}, string(os.PathListSeparator))
+
+	t.Log("write commands require a layer")
+	{
+		out, err := command.New(binPath(), "--path", layers, "add", "--key", "NAME", "--value", "world").RunAndReturnTrimmedCombinedOutput()
+		require.Error(t, err, out)
+		require.Contains(t, out, "2 envstore layers are specified, select the one to modify with --layer")
+	}
+
+	t.Log("later layers can reference earlier ones")
+	{
+		out, err := command.New(binPath(), "--path", layers, "--layer", "2", "add", "--key", "MESSAGE", "--value", "$GREETING world").RunAndReturnTrimmedCombinedOutput()
+		require.NoError(t, err, out)
+
+		out, err = command.New(binPath(), "--loglevel", "panic", "--path", layers, "run", "bash", "-c", "echo $MESSAGE").RunAndReturnTrimmedCombinedOutput()
+		require.NoError(t, err, out)
+		require.Equal(t, "hello world", out)
+	}
+
+	t.Log("print shows the layer of the values")
+	{
+		out, err := command.New(binPath(), "--path", layers, "print", "--expand", "--show-layer", "--format", "json").RunAndReturnTrimmedCombinedOutput()
+		require.NoError(t, err, out)
+		require.Equal(t, `{"GREETING":{"value":"hello","layer":"`+basePth+`"},"MESSAGE":{"value":"hello world","layer":"`+jobPth+`"}}`, out)
+	}
+
+	t.Log("ENVMAN_ENVSTORE_PATH accepts a list of envstores")
+	{
+		out, err := command.New(binPath(), "--loglevel", "panic", "run", "bash", "-c", "echo $MESSAGE").AppendEnvs("ENVMAN_ENVSTORE_PATH=" + layers).RunAndReturnTrimmedCombinedOutput()
+		require.NoError(t, err, out)
+		require.Equal(t, "hello world", out)
+	}
+
+	t.Log("--path can be repeated")
+	{
+		out, err := command.New(binPath(), "--loglevel", "panic", "--path", basePth, "--path", jobPth, "run", "bash", "-c", "echo $MESSAGE").RunAndReturnTrimmedCombinedOutput()
+		require.NoError(t, err, out)
+		require.Equal(t, "hello world", out)
+	}
+
+	t.Log("the path of an existing envstore is not split, even if it contains the path list separator")
+	{
+		pth := filepath.Join(tmpDir, "base.yml"+string(os.PathListSeparator)+"copy.yml")
+		require.NoError(t, os.WriteFile(pth, []byte("envs:\n- GREETING: hi\n"), 0644))
+
+		out, err := EnvmanRun(pth, tmpDir, []string{"bash", "-c", "echo $GREETING"})
+		require.NoError(t, err, out)
+		require.Equal(t, "hi", out)
+	}
+}
@@ -0,0 +1,52 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/stretchr/testify/require"
+)
+
+func profileCommand(homeDir, workDir string, args ...string) *command.Model {
+	return command.New(binPath(), args...).SetDir(workDir).AppendEnvs("HOME="+homeDir, "ENVMAN_PROFILE=", "ENVMAN_ENVSTORE_PATH=")
+}
+
+func TestProfile(t *testing.T) {
+	homeDir := t.TempDir()
+	workDir := t.TempDir()
+
+	out, err := profileCommand(homeDir, workDir, "profile", "create", "staging", "--use").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+
+	out, err = profileCommand(homeDir, workDir, "profile", "create", "production").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+
+	out, err = profileCommand(homeDir, workDir, "profile", "current").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+	require.Equal(t, "staging", out)
+
+	out, err = profileCommand(homeDir, workDir, "add", "--key", "STAGE", "--value", "staging").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+
+	out, err = profileCommand(homeDir, workDir, "--profile", "production", "add", "--key", "STAGE", "--value", "production").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+
+	out, err = profileCommand(homeDir, workDir, "print", "--format", "json").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+	require.Equal(t, `{"STAGE":"staging"}`, out)
+
+	out, err = profileCommand(homeDir, workDir, "profile", "use", "production").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+
+	out, err = profileCommand(homeDir, workDir, "print", "--format", "json").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+	require.Equal(t, `{"STAGE":"production"}`, out)
+
+	out, err = profileCommand(homeDir, workDir, "profile", "list").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+	require.Equal(t, "* production\n  staging", out)
+
+	out, err = profileCommand(homeDir, t.TempDir(), "profile", "current").RunAndReturnTrimmedCombinedOutput()
+	require.NoError(t, err, out)
+	require.Equal(t, "", out)
+}
@@ -72,6 +72,22 @@ func TestRun(t *testing.T) {
 
 }
 
+func TestRun_DollarEscape(t *testing.T) {
+	tmpDir := t.TempDir()
+	envstore := filepath.Join(tmpDir, ".envstore")
+	require.NoError(t, os.WriteFile(envstore, []byte("format_version: 1\nenvs:\n- CURRENCY: usd\n- PRICE: $$5 $CURRENCY\n"), 0644))
+
+	require.NoError(t, EnvmanAdd(envstore, "DISCOUNT", "$$1 $CURRENCY", true, false))
+
+	output, err := EnvmanRun(envstore, tmpDir, []string{"env"})
+	require.NoError(t, err, output)
+
+	envs, err := parseEnvRawOut(output)
+	require.NoError(t, err)
+	require.Equal(t, "5 usd", envs["PRICE"])
+	require.Equal(t, "$1 usd", envs["DISCOUNT"])
+}
+
 // Used for tests only, to parse env command output
 func parseEnvRawOut(output string) (map[string]string, error) {
 	// matches a single line like MYENVKEY_1=myvalue
@@ -0,0 +1,53 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrict(t *testing.T) {
+	tmpDir := t.TempDir()
+	envstorePth := filepath.Join(tmpDir, ".envstore.yml")
+	require.NoError(t, os.WriteFile(envstorePth, []byte(`envs:
+- BUILD_DIR: $ENVMAN_UNDEFINED_SOURCE_DRI/build
+`), 0644))
+
+	t.Log("undefined references expand to empty strings by default")
+	{
+		out, err := EnvmanRun(envstorePth, tmpDir, []string{"bash", "-c", "echo $BUILD_DIR"})
+		require.NoError(t, err, out)
+		require.Equal(t, "/build", out)
+	}
+
+	t.Log("run --strict fails")
+	{
+		out, err := command.New(binPath(), "--path", envstorePth, "run", "--strict", "bash", "-c", "echo $BUILD_DIR").RunAndReturnTrimmedCombinedOutput()
+		require.Error(t, err, out)
+		require.Contains(t, out, "BUILD_DIR (index 0) references undefined env var: ENVMAN_UNDEFINED_SOURCE_DRI")
+	}
+
+	t.Log("print --strict-warn warns")
+	{
+		out, err := command.New(binPath(), "--path", envstorePth, "print", "--expand", "--strict-warn").RunAndReturnTrimmedCombinedOutput()
+		require.NoError(t, err, out)
+		require.Contains(t, out, "BUILD_DIR (index 0) references undefined env var: ENVMAN_UNDEFINED_SOURCE_DRI")
+		require.Contains(t, out, "BUILD_DIR: /build")
+	}
+
+	t.Log("the envstore defaults enable the strict mode")
+	{
+		require.NoError(t, os.WriteFile(envstorePth, []byte(`defaults:
+  strict: error
+envs:
+- BUILD_DIR: $ENVMAN_UNDEFINED_SOURCE_DRI/build
+`), 0644))
+
+		out, err := command.New(binPath(), "--path", envstorePth, "print", "--expand").RunAndReturnTrimmedCombinedOutput()
+		require.Error(t, err, out)
+		require.Contains(t, out, "1 undefined env var reference(s)")
+	}
+}
@@ -17,7 +17,7 @@ func EnvmanInitAtPath(envstorePth string) error {
 }
 
 // EnvmanAdd ...
-func EnvmanAdd(envstorePth, key, value string, expand, skipIfEmpty bool) error {
+func EnvmanAdd(envstorePth, key, value string, expand, skipIfEmpty bool, addArgs ...string) error {
 	const logLevel = "debug"
 	args := []string{"--loglevel", logLevel, "--path", envstorePth, "add", "--key", key, "--append"}
 	if !expand {
@@ -26,6 +26,7 @@ func EnvmanAdd(envstorePth, key, value string, expand, skipIfEmpty bool) error {
 	if skipIfEmpty {
 		args = append(args, "--skip-if-empty")
 	}
+	args = append(args, addArgs...)
 
 	envman := exec.Command(binPath(), args...)
 	envman.Stdin = strings.NewReader(value)
@@ -82,7 +83,31 @@ func ExportEnvironmentsList(envstorePth string, envsList []models.EnvironmentIte
 			continue
 		}
 
-		if err := EnvmanAdd(envstorePth, key, value, isExpand, skipIfEmpty); err != nil {
+		var addArgs []string
+		if opts.Expansion != nil {
+			addArgs = append(addArgs, "--expansion", *opts.Expansion)
+		}
+		if opts.IsTemplate != nil && *opts.IsTemplate && opts.Expansion != nil && *opts.Expansion == models.ExpansionTemplate {
+			addArgs = append(addArgs, "--template")
+		}
+		if opts.Operation != nil {
+			switch *opts.Operation {
+			case models.OperationPrepend:
+				addArgs = append(addArgs, "--prepend")
+			case models.OperationAppend:
+				addArgs = append(addArgs, "--append-item")
+			case models.OperationRemove:
+				addArgs = append(addArgs, "--remove-item")
+			}
+		}
+		if opts.Separator != nil {
+			addArgs = append(addArgs, "--separator", *opts.Separator)
+		}
+		if opts.Dedupe != nil && *opts.Dedupe {
+			addArgs = append(addArgs, "--dedupe")
+		}
+
+		if err := EnvmanAdd(envstorePth, key, value, isExpand, skipIfEmpty, addArgs...); err != nil {
 			return err
 		}
 	}
@@ -1,12 +1,16 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
+	"github.com/bitrise-io/envman/v2/env"
 	"github.com/bitrise-io/envman/v2/envman"
+	"github.com/bitrise-io/envman/v2/envstore"
 	"github.com/bitrise-io/envman/v2/models"
 	"github.com/bitrise-io/go-utils/pointers"
 	log "github.com/sirupsen/logrus"
@@ -16,6 +20,8 @@ import (
 const envVarLimitErrorKnowledgeBaseURL = "https://support.bitrise.io/en/articles/9676692-env-var-value-too-large-env-var-list-too-large"
 
 func add(c *cli.Context) error {
+	ensureWriteLayer()
+
 	log.Debugln("[ENVMAN] Work path:", CurrentEnvStoreFilePath)
 
 	key := c.String(KeyKey)
@@ -59,7 +65,61 @@ func add(c *cli.Context) error {
 		}
 	}
 
-	if err := AddEnv(CurrentEnvStoreFilePath, key, value, expand, replace, skipIfEmpty, sensitive); err != nil {
+	opts := models.EnvironmentItemOptionsModel{
+		IsExpand:    pointers.NewBoolPtr(expand),
+		SkipIfEmpty: pointers.NewBoolPtr(skipIfEmpty),
+		IsSensitive: pointers.NewBoolPtr(sensitive),
+	}
+	if expansion := c.String(ExpansionKey); expansion != "" {
+		opts.Expansion = pointers.NewStringPtr(expansion)
+	}
+	if c.Bool(TemplateKey) {
+		if opts.Expansion != nil && *opts.Expansion != models.ExpansionTemplate {
+			log.Fatalf("[ENVMAN] - --%s can't be used with the %s expansion", TemplateKey, *opts.Expansion)
+		}
+		opts.IsTemplate = pointers.NewBoolPtr(true)
+		opts.Expansion = pointers.NewStringPtr(models.ExpansionTemplate)
+	}
+	if valueType := c.String(TypeKey); valueType != "" {
+		opts.Type = pointers.NewStringPtr(valueType)
+	}
+	opts.ValueOptions = c.StringSlice(ValueOptionKey)
+
+	if c.IsSet(PatternKey) {
+		opts.Pattern = pointers.NewStringPtr(c.String(PatternKey))
+	}
+	if c.IsSet(MinLengthKey) {
+		opts.MinLength = pointers.NewIntPtr(c.Int(MinLengthKey))
+	}
+	if c.IsSet(MaxLengthKey) {
+		opts.MaxLength = pointers.NewIntPtr(c.Int(MaxLengthKey))
+	}
+	if c.IsSet(MinKey) {
+		minValue := c.Float64(MinKey)
+		opts.Min = &minValue
+	}
+	if c.IsSet(MaxKey) {
+		maxValue := c.Float64(MaxKey)
+		opts.Max = &maxValue
+	}
+
+	operation, err := listOperation(c.Bool(PrependKey), c.Bool(AppendItemKey), c.Bool(RemoveItemKey))
+	if err != nil {
+		log.Fatalf("[ENVMAN] - %s", err)
+	}
+	if operation != "" {
+		// list operations build on the previous declarations of the env var
+		replace = false
+		opts.Operation = pointers.NewStringPtr(operation)
+		if separator := c.String(SeparatorKey); separator != "" {
+			opts.Separator = pointers.NewStringPtr(separator)
+		}
+		if c.Bool(DedupeKey) {
+			opts.Dedupe = pointers.NewBoolPtr(true)
+		}
+	}
+
+	if err := addEnv(CurrentEnvStoreFilePath, key, value, replace, opts); err != nil {
 		var envVarValueTooLargeErr EnvVarValueTooLargeError
 		var envVarListTooLargeErr EnvVarListTooLargeError
 		if errors.As(err, &envVarValueTooLargeErr) || errors.As(err, &envVarListTooLargeErr) {
@@ -79,38 +139,55 @@ func add(c *cli.Context) error {
 
 // AddEnv ...
 func AddEnv(envStorePth string, key string, value string, expand, replace, skipIfEmpty, sensitive bool) error {
-	// Load envs, or create if not exist
-	environments, err := ReadEnvsOrCreateEmptyList(envStorePth)
+	return addEnv(envStorePth, key, value, replace, models.EnvironmentItemOptionsModel{
+		IsExpand:    pointers.NewBoolPtr(expand),
+		SkipIfEmpty: pointers.NewBoolPtr(skipIfEmpty),
+		IsSensitive: pointers.NewBoolPtr(sensitive),
+	})
+}
+
+func addEnv(envStorePth string, key string, value string, replace bool, opts models.EnvironmentItemOptionsModel) error {
+	store, err := openEnvstore(envStorePth)
 	if err != nil {
 		return err
 	}
 
+	// Add or update envlist
+	return store.Add(context.Background(), key, value, envstore.AddOptions{
+		Append:     !replace,
+		EnvOptions: opts,
+		Validate:   validateAddedEnv,
+	})
+}
+
+// validateAddedEnv validates the added env against the envstore's current envs (while the envstore is locked):
+// the value rules of the previous declaration are inherited, the size limits and the value's type are checked,
+// and it's decided whether the env replaces the existing envs with the same key.
+func validateAddedEnv(environments []models.EnvironmentItemModel, key, value string, opts envstore.AddOptions) (string, envstore.AddOptions, error) {
+	envOpts, err := inheritValueRules(opts.EnvOptions, key, environments)
+	if err != nil {
+		return "", envstore.AddOptions{}, err
+	}
+
 	// Validate input
-	validatedValue, err := validateEnv(key, value, environments)
+	value, err = validateEnv(key, value, environments)
 	if err != nil {
-		return err
+		return "", envstore.AddOptions{}, err
 	}
-	value = validatedValue
 
-	// Add or update envlist
-	newEnv := models.EnvironmentItemModel{
-		key: value,
-		models.OptionsKey: models.EnvironmentItemOptionsModel{
-			IsExpand:    pointers.NewBoolPtr(expand),
-			SkipIfEmpty: pointers.NewBoolPtr(skipIfEmpty),
-			IsSensitive: pointers.NewBoolPtr(sensitive),
-		},
-	}
-	if err := newEnv.NormalizeValidateFillDefaults(); err != nil {
-		return err
+	value, err = validateEnvType(key, value, envOpts)
+	if err != nil {
+		return "", envstore.AddOptions{}, err
 	}
 
-	newEnvSlice, err := UpdateOrAddToEnvlist(environments, newEnv, replace)
+	replace, err := resolveReplace(environments, key, !opts.Append)
 	if err != nil {
-		return err
+		return "", envstore.AddOptions{}, err
 	}
 
-	return WriteEnvMapToFile(envStorePth, newEnvSlice)
+	opts.Append = !replace
+	opts.EnvOptions = envOpts
+	return value, opts, nil
 }
 
 func envListSizeInBytes(envs []models.EnvironmentItemModel) (int, error) {
@@ -156,6 +233,89 @@ func validateEnv(key, value string, envList []models.EnvironmentItemModel) (stri
 	return value, nil
 }
 
+// validateEnvType validates the options, normalizes the value by its type and checks its value constraint rules.
+// Values evaluated at envman run (expanded or rendered ones with references, and value sources) are only checked at evaluation.
+func validateEnvType(key, value string, opts models.EnvironmentItemOptionsModel) (string, error) {
+	if err := (models.EnvironmentItemModel{key: value, models.OptionsKey: opts}).Validate(); err != nil {
+		return "", err
+	}
+
+	evaluated := opts.ValueFrom != nil || opts.Operation != nil ||
+		(opts.IsTemplate != nil && *opts.IsTemplate && opts.Expansion != nil && *opts.Expansion == models.ExpansionTemplate && strings.Contains(value, "{{")) ||
+		(opts.IsExpand != nil && *opts.IsExpand && strings.Contains(value, "$"))
+	skipped := opts.SkipIfEmpty != nil && *opts.SkipIfEmpty && value == ""
+	if evaluated || skipped || len(opts.Transforms) > 0 {
+		return value, nil
+	}
+	value, err := env.NormalizeValue(key, value, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := env.CheckValueRules(key, value, opts); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// inheritValueRules sets the value constraint rules (pattern, min_length, max_length, min and max),
+// which are not set in opts, from the last declaration of the env var (key), so replacing a value keeps its contract.
+func inheritValueRules(opts models.EnvironmentItemOptionsModel, key string, environments []models.EnvironmentItemModel) (models.EnvironmentItemOptionsModel, error) {
+	for i := len(environments) - 1; i >= 0; i-- {
+		envKey, _, err := environments[i].GetKeyValuePair()
+		if err != nil {
+			return models.EnvironmentItemOptionsModel{}, err
+		}
+		if envKey != key {
+			continue
+		}
+
+		declared, err := environments[i].GetOptions()
+		if err != nil {
+			return models.EnvironmentItemOptionsModel{}, err
+		}
+		if opts.Pattern == nil {
+			opts.Pattern = declared.Pattern
+		}
+		if opts.MinLength == nil {
+			opts.MinLength = declared.MinLength
+		}
+		if opts.MaxLength == nil {
+			opts.MaxLength = declared.MaxLength
+		}
+		if opts.Min == nil {
+			opts.Min = declared.Min
+		}
+		if opts.Max == nil {
+			opts.Max = declared.Max
+		}
+		break
+	}
+	return opts, nil
+}
+
+// listOperation returns the list operation selected by the add flags (empty if none of them is set).
+func listOperation(prepend, appendItem, removeItem bool) (string, error) {
+	var operations []string
+	if prepend {
+		operations = append(operations, models.OperationPrepend)
+	}
+	if appendItem {
+		operations = append(operations, models.OperationAppend)
+	}
+	if removeItem {
+		operations = append(operations, models.OperationRemove)
+	}
+
+	switch len(operations) {
+	case 0:
+		return "", nil
+	case 1:
+		return operations[0], nil
+	default:
+		return "", fmt.Errorf("only one of --%s, --%s and --%s can be set", PrependKey, AppendItemKey, RemoveItemKey)
+	}
+}
+
 func loadValueFromFile(pth string) (string, error) {
 	buf, err := os.ReadFile(pth)
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/bitrise-io/envman/v2/envman"
 	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
 	"github.com/stretchr/testify/require"
 )
 
@@ -77,3 +78,62 @@ func TestValidateEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateEnvType(t *testing.T) {
+	opts := models.EnvironmentItemOptionsModel{Type: pointers.NewStringPtr(models.TypeBool), IsExpand: pointers.NewBoolPtr(true)}
+
+	value, err := validateEnvType("key", "yes", opts)
+	require.NoError(t, err)
+	require.Equal(t, "true", value)
+
+	_, err = validateEnvType("key", "maybe", opts)
+	require.Equal(t, NewEnvVarTypeError("key", "bool", "value is not a boolean"), err)
+
+	// expanded at envman run, checked at evaluation
+	value, err = validateEnvType("key", "$DEBUG", opts)
+	require.NoError(t, err)
+	require.Equal(t, "$DEBUG", value)
+
+	_, err = validateEnvType("key", "value", models.EnvironmentItemOptionsModel{Type: pointers.NewStringPtr("number")})
+	require.EqualError(t, err, "invalid options of env var (key): unknown type (number), supported types: string, int, bool, float, url, path, duration, enum, json")
+
+	_, err = validateEnvType("key", "value", models.EnvironmentItemOptionsModel{Type: pointers.NewStringPtr(models.TypeEnum)})
+	require.EqualError(t, err, "invalid options of env var (key): type (enum) requires value_options")
+}
+
+func TestListOperation(t *testing.T) {
+	operation, err := listOperation(false, false, false)
+	require.NoError(t, err)
+	require.Equal(t, "", operation)
+
+	operation, err = listOperation(true, false, false)
+	require.NoError(t, err)
+	require.Equal(t, models.OperationPrepend, operation)
+
+	operation, err = listOperation(false, false, true)
+	require.NoError(t, err)
+	require.Equal(t, models.OperationRemove, operation)
+
+	_, err = listOperation(true, true, false)
+	require.EqualError(t, err, "only one of --prepend, --append-item and --remove-item can be set")
+}
+
+func TestInheritValueRules(t *testing.T) {
+	environments := []models.EnvironmentItemModel{
+		{"VERSION": "v1", models.OptionsKey: map[string]interface{}{"pattern": `^v\d+$`, "max_length": 8}},
+		{"OTHER": "value", models.OptionsKey: map[string]interface{}{"min_length": 3}},
+	}
+
+	opts, err := inheritValueRules(models.EnvironmentItemOptionsModel{MaxLength: pointers.NewIntPtr(4)}, "VERSION", environments)
+	require.NoError(t, err)
+	require.Equal(t, `^v\d+$`, *opts.Pattern)
+	require.Equal(t, 4, *opts.MaxLength)
+	require.Nil(t, opts.MinLength)
+
+	_, err = validateEnvType("VERSION", "1.0", opts)
+	require.Equal(t, NewEnvVarRuleError("VERSION", "pattern", `value doesn't match ^v\d+$`), err)
+
+	opts, err = inheritValueRules(models.EnvironmentItemOptionsModel{}, "NEW", environments)
+	require.NoError(t, err)
+	require.Equal(t, models.EnvironmentItemOptionsModel{}, opts)
+}
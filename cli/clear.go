@@ -1,15 +1,15 @@
 package cli
 
 import (
-	"errors"
+	"context"
 
-	"github.com/bitrise-io/envman/v2/models"
-	"github.com/bitrise-io/go-utils/pathutil"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
 func clearEnvstore(_ *cli.Context) error {
+	ensureWriteLayer()
+
 	log.Debugln("[ENVMAN] - Work path:", CurrentEnvStoreFilePath)
 
 	if err := ClearEnvs(CurrentEnvStoreFilePath); err != nil {
@@ -23,11 +23,9 @@ func clearEnvstore(_ *cli.Context) error {
 
 // ClearEnvs ...
 func ClearEnvs(envStorePth string) error {
-	if isExists, err := pathutil.IsPathExists(envStorePth); err != nil {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
 		return err
-	} else if !isExists {
-		return errors.New("EnvStore not found in path:" + envStorePth)
 	}
-
-	return WriteEnvMapToFile(envStorePth, []models.EnvironmentItemModel{})
+	return store.Clear(context.Background())
 }
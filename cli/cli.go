@@ -49,13 +49,37 @@ func before(c *cli.Context) error {
 
 	// Before parsing cli, and running command
 	// we need to decide which path will be used by envman
-	CurrentEnvStoreFilePath = c.String(PathKey)
-	if CurrentEnvStoreFilePath == "" {
-		pth, err := filepath.Abs(path.Join("./", defaultEnvStoreName))
+	pathLists := c.StringSlice(PathKey)
+	if pathList := os.Getenv(PathEnvKey); len(pathLists) == 0 && pathList != "" {
+		pathLists = []string{pathList}
+	}
+
+	if len(pathLists) > 0 {
+		CurrentEnvStoreFilePaths = nil
+		for _, pathList := range pathLists {
+			CurrentEnvStoreFilePaths = append(CurrentEnvStoreFilePaths, splitEnvstorePathList(pathList)...)
+		}
+	} else {
+		profile, err := resolveProfile(c)
 		if err != nil {
-			log.Fatal("[ENVMAN] - Failed to set envman work path in current dir:", err)
+			log.Fatal("[ENVMAN] - Failed to resolve the active profile:", err)
+		}
+
+		if profile != "" {
+			CurrentProfile = profile
+			CurrentEnvStoreFilePaths = []string{envman.GetProfileEnvstorePath(profile)}
+		} else {
+			pth, err := filepath.Abs(path.Join("./", defaultEnvStoreName))
+			if err != nil {
+				log.Fatal("[ENVMAN] - Failed to set envman work path in current dir:", err)
+			}
+			CurrentEnvStoreFilePaths = []string{pth}
 		}
-		CurrentEnvStoreFilePath = pth
+	}
+
+	CurrentEnvStoreFilePath, err = selectWriteLayer(CurrentEnvStoreFilePaths, c.String(LayerKey))
+	if err != nil {
+		log.Fatal("[ENVMAN] - Failed to select the envstore layer to write:", err)
 	}
 
 	ToolMode = c.Bool(ToolKey)
@@ -63,6 +87,8 @@ func before(c *cli.Context) error {
 		log.Info("[ENVMAN] - Tool mode on")
 	}
 
+	LockTimeout = c.Duration(LockTimeoutKey)
+
 	if _, err := envman.GetConfigs(); err != nil {
 		log.Fatal("[ENVMAN] - Failed to init configs:", err)
 	}
@@ -70,6 +96,23 @@ func before(c *cli.Context) error {
 	return nil
 }
 
+// resolveProfile returns the profile specified by the --profile flag,
+// or the one activated for the working directory (or globally).
+func resolveProfile(c *cli.Context) (string, error) {
+	if profile := c.String(ProfileKey); profile != "" {
+		if err := envman.ValidateProfileName(profile); err != nil {
+			return "", err
+		}
+		return profile, nil
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return envman.GetActiveProfile(workDir)
+}
+
 // Run the Envman CLI.
 func Run() {
 	cli.HelpFlag = cli.BoolFlag{Name: HelpKey + ", " + helpKeyShort, Usage: "Show help."}
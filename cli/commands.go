@@ -1,6 +1,12 @@
 package cli
 
-import "github.com/urfave/cli"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/importer"
+	"github.com/urfave/cli"
+)
 
 var (
 	commands = []cli.Command{
@@ -26,6 +32,9 @@ var (
 			Action:  initEnvStore,
 			Flags: []cli.Flag{
 				flClear,
+				flJournal,
+				flExpansion,
+				flResolution,
 			},
 		},
 		{
@@ -47,6 +56,62 @@ var (
 					Name:  SensitiveKey,
 					Usage: "The environment variable will be marked as sensitive.",
 				},
+				cli.BoolFlag{
+					Name:  TemplateKey,
+					Usage: "The value will be rendered as a Go text/template, with the environment variables declared before it as data (like {{ .HOME }}). Sets the template expansion.",
+				},
+				cli.BoolFlag{
+					Name:  PrependKey,
+					Usage: "The value will be prepended to the list environment variable (like PATH) at envman run, instead of replacing it.",
+				},
+				cli.BoolFlag{
+					Name:  AppendItemKey,
+					Usage: "The value will be appended to the list environment variable (like PATH) at envman run, instead of replacing it.",
+				},
+				cli.BoolFlag{
+					Name:  RemoveItemKey,
+					Usage: "The value will be removed from the list environment variable (like PATH) at envman run, instead of replacing it.",
+				},
+				cli.StringFlag{
+					Name:  SeparatorKey,
+					Usage: "The separator of the list items, if not set then : will be used.",
+				},
+				cli.BoolFlag{
+					Name:  DedupeKey,
+					Usage: "The duplicated items of the list will be removed (the first occurrence is kept).",
+				},
+				cli.StringFlag{
+					Name:  TypeKey,
+					Usage: "The type of the value: string, int, bool, float, url, path, duration, enum or json. The value is validated and normalized by its type (like yes to true).",
+				},
+				cli.StringSliceFlag{
+					Name:  ValueOptionKey,
+					Usage: "An allowed value of an enum type environment variable, can be specified multiple times.",
+				},
+				cli.StringFlag{
+					Name:  PatternKey,
+					Usage: "A regular expression, which the value has to match.",
+				},
+				cli.IntFlag{
+					Name:  MinLengthKey,
+					Usage: "The minimum length of the value.",
+				},
+				cli.IntFlag{
+					Name:  MaxLengthKey,
+					Usage: "The maximum length of the value.",
+				},
+				cli.Float64Flag{
+					Name:  MinKey,
+					Usage: "The minimum of the numeric value.",
+				},
+				cli.Float64Flag{
+					Name:  MaxKey,
+					Usage: "The maximum of the numeric value.",
+				},
+				cli.StringFlag{
+					Name:  ExpansionKey,
+					Usage: "The expansion of the environment variable: simple ($VAR and ${VAR} references), bash (bash parameter expansion operators too, like ${VAR:-default}) or template (is_template values are rendered as Go text/templates). If not set then the envstore's default expansion will be used.",
+				},
 			},
 		},
 		{
@@ -55,6 +120,127 @@ var (
 			Usage:   "Clear the envstore.",
 			Action:  clearEnvstore,
 		},
+		{
+			Name:      "import",
+			Usage:     "Import env vars from a .env file, a shell script of export statements, a JSON or YAML map, or Kubernetes ConfigMap and Secret manifests (- reads the standard input). The existing env vars with the same key are replaced, unless --append is set. Values referencing other env vars are expanded, except the single-quoted ones.",
+			ArgsUsage: "FILE",
+			Action:    importCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  FormatKey,
+					Usage: fmt.Sprintf("Format of the imported file (options: %s), defaults to %s.", strings.Join(importer.Formats, ", "), importer.FormatDotenv),
+				},
+				cli.StringFlag{
+					Name:  SeparatorKey,
+					Usage: fmt.Sprintf("Separator joining the keys of the nested JSON and YAML maps, defaults to %s.", importer.DefaultSeparator),
+				},
+				cli.StringFlag{
+					Name:  PrefixKey,
+					Usage: "Prefix prepended to the keys of the imported env vars.",
+				},
+				flAppend,
+				cli.BoolFlag{
+					Name:  DryRunKey,
+					Usage: "Only print what the import would add (+) and replace (~), without modifying the envstore.",
+				},
+				cli.BoolFlag{
+					Name:  NoExpandKey,
+					Usage: "Import every value as is, without expanding the referenced env vars.",
+				},
+				cli.StringSliceFlag{
+					Name:  LiteralKey,
+					Usage: "Key of an env var to import as is (not expanded), can be specified multiple times.",
+				},
+			},
+		},
+		{
+			Name:   "history",
+			Usage:  "List the recorded revisions (the content of the envstore before each add, unset, clear, ...) and the checkpoints of the envstore. The number of kept revisions is configured by history_limit in ~/.envman/configs.json (defaults to 50, 0 disables the history).",
+			Action: historyCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  FormatKey,
+					Usage: fmt.Sprintf("Output format (options: %s, %s).", OutputFormatRaw, OutputFormatJSON),
+				},
+			},
+		},
+		{
+			Name:   "undo",
+			Usage:  "Restore the envstore's content before its last recorded modification.",
+			Action: undoCmd,
+		},
+		{
+			Name:      "checkpoint",
+			Usage:     "Save the envstore's current content as a named checkpoint, an existing checkpoint with the same name is replaced.",
+			ArgsUsage: "NAME",
+			Action:    checkpointCmd,
+		},
+		{
+			Name:      "restore",
+			Usage:     "Restore the envstore's content saved by a checkpoint (the restore can be undone).",
+			ArgsUsage: "NAME",
+			Action:    restoreCmd,
+		},
+		{
+			Name:   "compact",
+			Usage:  "Rewrite the envstore (for example a journal envstore) into the classic .envstore.yml layout.",
+			Action: compact,
+		},
+		{
+			Name:  "profile",
+			Usage: "Manage named envstores (profiles).",
+			Subcommands: []cli.Command{
+				{
+					Name:      "create",
+					Usage:     "Create a profile with an empty envstore.",
+					ArgsUsage: "PROFILE",
+					Action:    createProfileCmd,
+					Flags: []cli.Flag{
+						flJournal,
+						cli.BoolFlag{
+							Name:  UseKey,
+							Usage: "If enabled, the new profile is activated too.",
+						},
+						flGlobal,
+					},
+				},
+				{
+					Name:   "list",
+					Usage:  "List the profiles, the active one is marked with *.",
+					Action: listProfilesCmd,
+				},
+				{
+					Name:      "use",
+					Usage:     "Activate a profile for the current working directory (and its subdirectories) or globally.",
+					ArgsUsage: "PROFILE",
+					Action:    useProfileCmd,
+					Flags: []cli.Flag{
+						flGlobal,
+						cli.BoolFlag{
+							Name:  ResetKey,
+							Usage: "Deactivate the profile instead.",
+						},
+					},
+				},
+				{
+					Name:      "delete",
+					Usage:     "Delete a profile and its envstore.",
+					ArgsUsage: "PROFILE",
+					Action:    deleteProfileCmd,
+				},
+				{
+					Name:      "copy",
+					Usage:     "Create a new profile with the envs of an existing one.",
+					ArgsUsage: "SOURCE_PROFILE DESTINATION_PROFILE",
+					Action:    copyProfileCmd,
+				},
+				{
+					Name:   "current",
+					Usage:  "Print the active profile, prints nothing if no profile is active.",
+					Action: currentProfileCmd,
+				},
+			},
+		},
 		{
 			Name:    "print",
 			Aliases: []string{"p"},
@@ -63,16 +249,92 @@ var (
 			Flags: []cli.Flag{
 				flFormat,
 				flExpand,
+				flStrict,
+				flStrictWarn,
+				flAllowCommands,
 				cli.BoolFlag{
 					Name:  SensitiveOnlyKey,
 					Usage: "Print the only environment variables that are marked as sensitive.",
 				},
+				cli.BoolFlag{
+					Name:  ShowLayerKey,
+					Usage: "Print the envstore layer each environment variable's value comes from.",
+				},
+			},
+		},
+		{
+			Name:   "validate",
+			Usage:  "Evaluate the envstore and check the declared constraints (is_required, value_options, is_dont_change_value). Exits with a non-zero code if any of them is violated.",
+			Action: validate,
+			Flags: []cli.Flag{
+				flAllowCommands,
+				cli.StringFlag{
+					Name:  FormatKey,
+					Usage: fmt.Sprintf("Output format (options: %s, %s).", OutputFormatRaw, OutputFormatJSON),
+				},
+			},
+		},
+		{
+			Name:      "diff",
+			Usage:     "Compare two envstores (added, removed and changed env vars, option and ordering changes), or with --against-process-env show what envman run would change in the current process environment. Sensitive values are shown as salted hash fingerprints.",
+			ArgsUsage: "[OLD_ENVSTORE NEW_ENVSTORE]",
+			Action:    diffCmd,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  ExpandKey,
+					Usage: "Compare the expanded values instead of the raw ones.",
+				},
+				cli.BoolFlag{
+					Name:  AgainstProcessEnvKey,
+					Usage: "Compare the current envstore's evaluated env vars with the process environment.",
+				},
+				flAllowCommands,
+				cli.StringFlag{
+					Name:  FormatKey,
+					Usage: fmt.Sprintf("Output format (options: %s, %s, %s).", OutputFormatRaw, OutputFormatJSON, OutputFormatPatch),
+				},
+			},
+		},
+		{
+			Name:      "explain",
+			Usage:     "Explain where the effective value of an environment variable comes from: the declarations setting, skipping and unsetting it, and the sources of the referenced values.",
+			ArgsUsage: "KEY",
+			Action:    explainCmd,
+			Flags: []cli.Flag{
+				flAllowCommands,
+				cli.BoolFlag{
+					Name:  ShowInheritedKey,
+					Usage: "Print the values inherited from the process environment, and the values expanded from them. These values are masked by default.",
+				},
+				cli.StringFlag{
+					Name:  FormatKey,
+					Usage: fmt.Sprintf("Output format (options: %s, %s).", OutputFormatRaw, OutputFormatJSON),
+				},
+			},
+		},
+		{
+			Name:   "rekey",
+			Usage:  "Rotate the encryption key and re-encrypt the sensitive values of the envstore.",
+			Action: rekey,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  KeepKeyKey,
+					Usage: "Do not rotate the encryption key, only re-encrypt the values (for example after changing the recipients).",
+				},
+				cli.StringSliceFlag{
+					Name:  AddRecipientKey,
+					Usage: "Recipient (public key) to encrypt the sensitive values for, can be specified multiple times.",
+				},
+				cli.StringSliceFlag{
+					Name:  RemoveRecipientKey,
+					Usage: "Recipient (public key) to remove from the envstore, can be specified multiple times.",
+				},
 			},
 		},
 		{
 			Name:            "run",
 			Aliases:         []string{"r"},
-			Usage:           "Run the specified command with the environment variables stored in the envstore.",
+			Usage:           "Run the specified command with the environment variables stored in the envstore. The --strict, --strict-warn, --allow-commands and --validate flags are accepted before the command.",
 			SkipFlagParsing: true,
 			Action:          run,
 		},
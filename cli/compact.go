@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+func compact(_ *cli.Context) error {
+	ensureWriteLayer()
+
+	log.Debugln("[ENVMAN] - Work path:", CurrentEnvStoreFilePath)
+
+	if err := CompactEnvStore(CurrentEnvStoreFilePath); err != nil {
+		log.Fatal("[ENVMAN] - Failed to compact EnvStore:", err)
+	}
+
+	log.Info("[ENVMAN] - EnvStore compacted")
+
+	return nil
+}
+
+// CompactEnvStore rewrites the envstore (usually a journal) into the classic .envstore.yml layout.
+func CompactEnvStore(envStorePth string) error {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return err
+	}
+	return store.Compact(context.Background())
+}
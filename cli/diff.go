@@ -0,0 +1,382 @@
+package cli
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/models"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+)
+
+// envSnapshot is the state of an env var in an envstore (or in the process environment):
+// the last declaration's (raw or evaluated) value and options.
+type envSnapshot struct {
+	Key string
+	// Value is nil if the env var is unset
+	Value *string
+	// Declared is set for the raw envstore env vars, they are compared even if they are declared as unset
+	Declared  bool
+	Sensitive bool
+	// Options are the compared options, nil for the process environment
+	Options map[string]bool
+}
+
+func (s envSnapshot) present() bool {
+	return s.Value != nil || s.Declared
+}
+
+type optionChangeJSONModel struct {
+	Name string `json:"name"`
+	Old  bool   `json:"old"`
+	New  bool   `json:"new"`
+}
+
+type envChangeJSONModel struct {
+	Key       string                  `json:"key"`
+	Change    string                  `json:"change"`
+	Old       *string                 `json:"old,omitempty"`
+	New       *string                 `json:"new,omitempty"`
+	Sensitive bool                    `json:"sensitive,omitempty"`
+	Options   []optionChangeJSONModel `json:"options,omitempty"`
+}
+
+type orderChangeJSONModel struct {
+	Old []string `json:"old"`
+	New []string `json:"new"`
+}
+
+// envDiffJSONModel is the difference of two envstores.
+type envDiffJSONModel struct {
+	Changes []envChangeJSONModel `json:"changes"`
+	// Order is set if the common env vars are declared in a different order
+	Order *orderChangeJSONModel `json:"order,omitempty"`
+}
+
+func diffCmd(c *cli.Context) error {
+	format := c.String(FormatKey)
+	if format == "" {
+		format = OutputFormatRaw
+	} else if format != OutputFormatRaw && format != OutputFormatJSON && format != OutputFormatPatch {
+		log.Fatalf("[ENVMAN] - Invalid format: %s", format)
+	}
+
+	opts, err := withConfigLimits(env.DeclarationOptions{ValueSources: env.ValueSourceOptions{AllowCommands: c.Bool(AllowCommandsKey)}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	envSource := &env.DefaultEnvironmentSource{}
+
+	var oldName, newName string
+	var oldEnvs, newEnvs []envSnapshot
+	if c.Bool(AgainstProcessEnvKey) {
+		if c.NArg() != 0 {
+			log.Fatalf("[ENVMAN] - No envstores can be specified with --%s", AgainstProcessEnvKey)
+		}
+
+		layers, err := ReadEnvstoreLayers(CurrentEnvStoreFilePaths)
+		if err != nil {
+			log.Fatal(err)
+		}
+		newEnvs, err = envstoreSnapshots(layers, true, envSource, opts)
+		if err != nil {
+			log.Fatalf("[ENVMAN] - Failed to evaluate envstore: %s", err)
+		}
+		oldEnvs = processEnvSnapshots(envSource.GetEnvironment(), newEnvs)
+		oldName, newName = "process environment", strings.Join(CurrentEnvStoreFilePaths, ", ")
+	} else {
+		if c.NArg() != 2 {
+			log.Fatalf("[ENVMAN] - Two envstores have to be specified (or --%s)", AgainstProcessEnvKey)
+		}
+		oldName, newName = c.Args().Get(0), c.Args().Get(1)
+
+		for _, side := range []struct {
+			pth  string
+			envs *[]envSnapshot
+		}{{oldName, &oldEnvs}, {newName, &newEnvs}} {
+			layers, err := ReadEnvstoreLayers([]string{side.pth})
+			if err != nil {
+				log.Fatal(err)
+			}
+			*side.envs, err = envstoreSnapshots(layers, c.Bool(ExpandKey), envSource, opts)
+			if err != nil {
+				log.Fatalf("[ENVMAN] - Failed to read envstore (%s): %s", side.pth, err)
+			}
+		}
+	}
+
+	salt, err := newFingerprintSalt()
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to generate fingerprint salt: %s", err)
+	}
+	oldEnvs = fingerprintSensitiveValues(oldEnvs, newEnvs, salt)
+	newEnvs = fingerprintSensitiveValues(newEnvs, oldEnvs, salt)
+
+	switch format {
+	case OutputFormatPatch:
+		fmt.Print(unifiedDiff(oldName, newName, snapshotLines(oldEnvs), snapshotLines(newEnvs)))
+	case OutputFormatJSON:
+		bytes, err := json.Marshal(diffSnapshots(oldEnvs, newEnvs))
+		if err != nil {
+			log.Fatalf("[ENVMAN] - Failed to print diff: %s", err)
+		}
+		fmt.Println(string(bytes))
+	default:
+		printEnvDiff(os.Stdout, diffSnapshots(oldEnvs, newEnvs))
+	}
+	return nil
+}
+
+// envstoreSnapshots returns the state of the env vars declared by the envstore layers, in the order of their first declaration.
+// The values are evaluated if expand is set, otherwise they are the raw values of the last declarations.
+func envstoreSnapshots(layers []EnvstoreLayer, expand bool, envSource env.EnvironmentSource, opts env.DeclarationOptions) ([]envSnapshot, error) {
+	envs, _ := flattenEnvstoreLayers(layers)
+
+	var result env.DeclarationSideEffects
+	if expand {
+		var err error
+		result, err = env.GetDeclarationsSideEffectsWithOptions(envs, envSource, layersEvaluationOptions(layers, envSource, opts))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var snapshots []envSnapshot
+	idxs := map[string]int{}
+	for _, e := range envs {
+		key, value, err := e.GetKeyValuePair()
+		if err != nil {
+			return nil, err
+		}
+		options, err := e.GetOptions()
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot := envSnapshot{
+			Key:       key,
+			Declared:  !expand,
+			Sensitive: options.IsSensitive != nil && *options.IsSensitive,
+			Options: map[string]bool{
+				"is_sensitive":  options.IsSensitive != nil && *options.IsSensitive,
+				"is_expand":     options.IsExpand == nil || *options.IsExpand,
+				"skip_if_empty": options.SkipIfEmpty != nil && *options.SkipIfEmpty,
+			},
+		}
+		if options.Unset == nil || !*options.Unset {
+			snapshot.Value = &value
+		}
+
+		if idx, ok := idxs[key]; ok {
+			snapshots[idx] = snapshot
+		} else {
+			idxs[key] = len(snapshots)
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	if expand {
+		for i, snapshot := range snapshots {
+			snapshots[i].Value = nil
+			if value, ok := result.ResultEnvironment[snapshot.Key]; ok {
+				snapshots[i].Value = &value
+			}
+			// the evaluated value may contain sensitive values of other env vars
+			if events := result.Provenance[snapshot.Key]; len(events) > 0 && events[len(events)-1].Sensitive {
+				snapshots[i].Sensitive = true
+			}
+		}
+	}
+	return snapshots, nil
+}
+
+// processEnvSnapshots returns the state of the env vars declared by the envstore (envstoreEnvs) in the process environment.
+func processEnvSnapshots(processEnvs map[string]string, envstoreEnvs []envSnapshot) []envSnapshot {
+	var snapshots []envSnapshot
+	for _, envstoreEnv := range envstoreEnvs {
+		snapshot := envSnapshot{Key: envstoreEnv.Key, Sensitive: envstoreEnv.Sensitive}
+		if value, ok := processEnvs[envstoreEnv.Key]; ok {
+			snapshot.Value = &value
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+func newFingerprintSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+// fingerprint returns the salted hash fingerprint of a sensitive value:
+// equal values have equal fingerprints within a diff, but the value can't be looked up by its hash.
+func fingerprint(salt []byte, value string) string {
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write([]byte(value))
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// fingerprintSensitiveValues replaces the values of the env vars, which are sensitive on either side, with their fingerprints.
+func fingerprintSensitiveValues(snapshots, otherSnapshots []envSnapshot, salt []byte) []envSnapshot {
+	sensitive := map[string]bool{}
+	for _, snapshot := range otherSnapshots {
+		if snapshot.Sensitive {
+			sensitive[snapshot.Key] = true
+		}
+	}
+
+	fingerprinted := make([]envSnapshot, len(snapshots))
+	for i, snapshot := range snapshots {
+		if (snapshot.Sensitive || sensitive[snapshot.Key]) && snapshot.Value != nil {
+			value := fingerprint(salt, *snapshot.Value)
+			snapshot.Value = &value
+			snapshot.Sensitive = true
+		}
+		fingerprinted[i] = snapshot
+	}
+	return fingerprinted
+}
+
+// diffSnapshots returns the changes between the old and the new env vars:
+// the changes of the old env vars in their old order, then the env vars missing from the old ones in their new order.
+func diffSnapshots(oldEnvs, newEnvs []envSnapshot) envDiffJSONModel {
+	newByKey := map[string]envSnapshot{}
+	for _, newEnv := range newEnvs {
+		newByKey[newEnv.Key] = newEnv
+	}
+	oldByKey := map[string]envSnapshot{}
+	for _, oldEnv := range oldEnvs {
+		oldByKey[oldEnv.Key] = oldEnv
+	}
+
+	diff := envDiffJSONModel{Changes: []envChangeJSONModel{}}
+	var oldOrder, newOrder []string
+	for _, oldEnv := range oldEnvs {
+		newEnv := newByKey[oldEnv.Key]
+		switch {
+		case !oldEnv.present() && !newEnv.present():
+			continue
+		case !newEnv.present():
+			diff.Changes = append(diff.Changes, envChangeJSONModel{Key: oldEnv.Key, Change: changeRemoved, Old: oldEnv.Value, Sensitive: oldEnv.Sensitive})
+			continue
+		case !oldEnv.present():
+			diff.Changes = append(diff.Changes, envChangeJSONModel{Key: oldEnv.Key, Change: changeAdded, New: newEnv.Value, Sensitive: newEnv.Sensitive})
+			continue
+		}
+		oldOrder = append(oldOrder, oldEnv.Key)
+
+		change := envChangeJSONModel{Key: oldEnv.Key, Change: changeChanged, Old: oldEnv.Value, New: newEnv.Value, Sensitive: oldEnv.Sensitive || newEnv.Sensitive}
+		for _, name := range []string{"is_sensitive", "is_expand", "skip_if_empty"} {
+			if oldEnv.Options != nil && newEnv.Options != nil && oldEnv.Options[name] != newEnv.Options[name] {
+				change.Options = append(change.Options, optionChangeJSONModel{Name: name, Old: oldEnv.Options[name], New: newEnv.Options[name]})
+			}
+		}
+		if !equalValues(oldEnv.Value, newEnv.Value) || len(change.Options) > 0 {
+			diff.Changes = append(diff.Changes, change)
+		}
+	}
+
+	for _, newEnv := range newEnvs {
+		oldEnv, ok := oldByKey[newEnv.Key]
+		if ok && oldEnv.present() && newEnv.present() {
+			newOrder = append(newOrder, newEnv.Key)
+			continue
+		}
+		if ok || !newEnv.present() {
+			continue
+		}
+		diff.Changes = append(diff.Changes, envChangeJSONModel{Key: newEnv.Key, Change: changeAdded, New: newEnv.Value, Sensitive: newEnv.Sensitive})
+	}
+
+	if strings.Join(oldOrder, "\n") != strings.Join(newOrder, "\n") {
+		diff.Order = &orderChangeJSONModel{Old: oldOrder, New: newOrder}
+	}
+	return diff
+}
+
+func equalValues(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func formatValue(value *string) string {
+	if value == nil {
+		return "(unset)"
+	}
+	return fmt.Sprintf("%q", *value)
+}
+
+func printEnvDiff(w io.Writer, diff envDiffJSONModel) {
+	if len(diff.Changes) == 0 && diff.Order == nil {
+		fmt.Fprintln(w, "No differences")
+		return
+	}
+
+	for _, change := range diff.Changes {
+		switch change.Change {
+		case changeAdded:
+			fmt.Fprintf(w, "+ %s=%s\n", change.Key, formatValue(change.New))
+		case changeRemoved:
+			fmt.Fprintf(w, "- %s=%s\n", change.Key, formatValue(change.Old))
+		default:
+			if equalValues(change.Old, change.New) {
+				fmt.Fprintf(w, "~ %s\n", change.Key)
+			} else {
+				fmt.Fprintf(w, "~ %s: %s -> %s\n", change.Key, formatValue(change.Old), formatValue(change.New))
+			}
+		}
+		for _, option := range change.Options {
+			fmt.Fprintf(w, "    %s: %t -> %t\n", option.Name, option.Old, option.New)
+		}
+	}
+	if diff.Order != nil {
+		fmt.Fprintf(w, "order: %s -> %s\n", strings.Join(diff.Order.Old, ", "), strings.Join(diff.Order.New, ", "))
+	}
+}
+
+// snapshotLines returns the lines of the env vars in the unified patch: KEY="value", with the non-default options.
+func snapshotLines(snapshots []envSnapshot) []string {
+	var lines []string
+	for _, snapshot := range snapshots {
+		line := snapshot.Key + "=" + formatValue(snapshot.Value)
+		var options []string
+		for _, name := range []string{"is_sensitive", "is_expand", "skip_if_empty"} {
+			if snapshot.Options != nil && snapshot.Options[name] != defaultOptionValue(name) {
+				options = append(options, fmt.Sprintf("%s=%t", name, snapshot.Options[name]))
+			}
+		}
+		if len(options) > 0 {
+			line += " # " + strings.Join(options, " ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func defaultOptionValue(name string) bool {
+	switch name {
+	case "is_sensitive":
+		return models.DefaultIsSensitive
+	case "is_expand":
+		return models.DefaultIsExpand
+	default:
+		return models.DefaultSkipIfEmpty
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPth := filepath.Join(tmpDir, "old.yml")
+	newPth := filepath.Join(tmpDir, "new.yml")
+
+	require.NoError(t, os.WriteFile(oldPth, []byte(`envs:
+- HOST: example.com
+- TOKEN: secret
+  opts:
+    is_sensitive: true
+- URL: https://$HOST/api
+- DEBUG: "false"
+- REMOVED: value
+`), 0644))
+	require.NoError(t, os.WriteFile(newPth, []byte(`envs:
+- TOKEN: secret
+  opts:
+    is_sensitive: true
+- HOST: example.com
+- URL: https://$HOST/api
+  opts:
+    is_expand: false
+- DEBUG: "true"
+- CACHE: ""
+  opts:
+    unset: true
+`), 0644))
+
+	salt := []byte("salt")
+	snapshots := func(pth string, expand bool) []envSnapshot {
+		layers, err := ReadEnvstoreLayers([]string{pth})
+		require.NoError(t, err)
+		snapshots, err := envstoreSnapshots(layers, expand, testEnvSource{}, env.DeclarationOptions{})
+		require.NoError(t, err)
+		return snapshots
+	}
+
+	oldEnvs, newEnvs := snapshots(oldPth, false), snapshots(newPth, false)
+	oldEnvs = fingerprintSensitiveValues(oldEnvs, newEnvs, salt)
+	newEnvs = fingerprintSensitiveValues(newEnvs, oldEnvs, salt)
+
+	var out bytes.Buffer
+	printEnvDiff(&out, diffSnapshots(oldEnvs, newEnvs))
+	require.Equal(t, `~ URL
+    is_expand: true -> false
+~ DEBUG: "false" -> "true"
+- REMOVED="value"
++ CACHE=(unset)
+order: HOST, TOKEN, URL, DEBUG -> TOKEN, HOST, URL, DEBUG
+`, out.String())
+	require.NotContains(t, out.String(), "secret")
+
+	oldEnvs, newEnvs = snapshots(oldPth, true), snapshots(newPth, true)
+	diff := diffSnapshots(fingerprintSensitiveValues(oldEnvs, newEnvs, salt), fingerprintSensitiveValues(newEnvs, oldEnvs, salt))
+	require.Equal(t, envChangeJSONModel{
+		Key:     "URL",
+		Change:  changeChanged,
+		Old:     strPtr("https://example.com/api"),
+		New:     strPtr("https://$HOST/api"),
+		Options: []optionChangeJSONModel{{Name: "is_expand", Old: true, New: false}},
+	}, diff.Changes[0])
+	// the unset env var is not part of the evaluated environment
+	require.Len(t, diff.Changes, 3)
+
+	out.Reset()
+	printEnvDiff(&out, diffSnapshots(oldEnvs, oldEnvs))
+	require.Equal(t, "No differences\n", out.String())
+}
+
+func TestProcessEnvSnapshots(t *testing.T) {
+	envstoreEnvs := []envSnapshot{
+		{Key: "CHANGED", Value: strPtr("new"), Options: map[string]bool{}},
+		{Key: "ADDED", Value: strPtr("value"), Options: map[string]bool{}},
+		{Key: "UNSET", Options: map[string]bool{}},
+		{Key: "TOKEN", Value: strPtr("secret"), Sensitive: true, Options: map[string]bool{}},
+	}
+	processEnvs := processEnvSnapshots(map[string]string{"CHANGED": "old", "UNSET": "value", "TOKEN": "secret", "OTHER": "value"}, envstoreEnvs)
+
+	var out bytes.Buffer
+	printEnvDiff(&out, diffSnapshots(processEnvs, envstoreEnvs))
+	require.Equal(t, `~ CHANGED: "old" -> "new"
++ ADDED="value"
+- UNSET="value"
+`, out.String())
+}
+
+func TestFingerprint(t *testing.T) {
+	require.Equal(t, fingerprint([]byte("salt"), "secret"), fingerprint([]byte("salt"), "secret"))
+	require.NotEqual(t, fingerprint([]byte("salt"), "secret"), fingerprint([]byte("other salt"), "secret"))
+	require.True(t, strings.HasPrefix(fingerprint([]byte("salt"), "secret"), "sha256:"))
+	require.Len(t, fingerprint([]byte("salt"), "secret"), len("sha256:")+16)
+}
+
+func strPtr(value string) *string {
+	return &value
+}
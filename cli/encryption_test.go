@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/encryption"
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/envman"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedEnvStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv(envman.EncryptionKeyFileEnvKey, filepath.Join(tmpDir, "encryption.key"))
+	t.Setenv(envman.EncryptionKeyEnvKey, "")
+
+	envStorePth := filepath.Join(tmpDir, ".envstore.yml")
+	require.NoError(t, InitEnvStore(envStorePth, false))
+
+	t.Log("without encryption key sensitive values are stored as they are, with a warning")
+	{
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+
+		require.NoError(t, AddEnv(envStorePth, "SECRET", "top secret", true, true, false, true))
+
+		content, err := os.ReadFile(envStorePth)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "top secret")
+		require.Contains(t, logs.String(), "Sensitive env vars are stored unencrypted")
+		require.Contains(t, logs.String(), "SECRET")
+		log.SetOutput(os.Stderr)
+	}
+
+	identity, err := encryption.GenerateIdentity()
+	require.NoError(t, err)
+	t.Setenv(envman.EncryptionKeyEnvKey, identity.String())
+
+	t.Log("with encryption key sensitive values are encrypted")
+	{
+		require.NoError(t, AddEnv(envStorePth, "PUBLIC", "not a secret", true, true, false, false))
+
+		content, err := os.ReadFile(envStorePth)
+		require.NoError(t, err)
+		require.NotContains(t, string(content), "top secret")
+		require.Contains(t, string(content), "not a secret")
+
+		envsJSON, err := ReadEnvsJSONList(envStorePth, true, false, &env.DefaultEnvironmentSource{})
+		require.NoError(t, err)
+		require.Equal(t, "top secret", envsJSON["SECRET"])
+		require.Equal(t, "not a secret", envsJSON["PUBLIC"])
+	}
+
+	t.Log("encrypted values can not be read without the encryption key")
+	{
+		t.Setenv(envman.EncryptionKeyEnvKey, "")
+		_, err := ReadEnvs(envStorePth)
+		require.Error(t, err)
+		require.True(t, strings.HasPrefix(err.Error(), "env var (SECRET) is encrypted, but no encryption key is configured"))
+	}
+
+	t.Log("rekey rotates the key and adds recipients")
+	{
+		keyFileContent, err := os.ReadFile(os.Getenv(envman.EncryptionKeyFileEnvKey))
+		require.True(t, os.IsNotExist(err), string(keyFileContent))
+
+		// the first rekey needs the old key to decrypt the envstore
+		require.NoError(t, envman.SaveEncryptionIdentities([]encryption.Identity{identity}))
+
+		teammate, err := encryption.GenerateIdentity()
+		require.NoError(t, err)
+
+		primary, err := RekeyEnvStore(envStorePth, true, []string{teammate.Recipient().String()}, nil)
+		require.NoError(t, err)
+		require.NotEqual(t, identity.Recipient().String(), primary.String())
+
+		identities, err := envman.GetEncryptionIdentities()
+		require.NoError(t, err)
+		require.Equal(t, 2, len(identities))
+		require.Equal(t, primary.String(), identities[0].Recipient().String())
+
+		// the old key can no longer decrypt the envstore
+		t.Setenv(envman.EncryptionKeyEnvKey, identity.String())
+		_, err = ReadEnvs(envStorePth)
+		require.Error(t, err)
+
+		// the teammate can
+		t.Setenv(envman.EncryptionKeyEnvKey, teammate.String())
+		envs, err := ReadEnvs(envStorePth)
+		require.NoError(t, err)
+		_, value, err := envs[0].GetKeyValuePair()
+		require.NoError(t, err)
+		require.Equal(t, "top secret", value)
+
+		// recipients are kept when the envstore is rewritten
+		require.NoError(t, AddEnv(envStorePth, "SECRET2", "another secret", true, true, false, true))
+		content, err := os.ReadFile(envStorePth)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "recipients:\n- "+teammate.Recipient().String())
+		require.NotContains(t, string(content), "another secret")
+	}
+}
+
+func TestEncryptedJournalEnvStore(t *testing.T) {
+	tmpDir := t.TempDir()
+	identity, err := encryption.GenerateIdentity()
+	require.NoError(t, err)
+	t.Setenv(envman.EncryptionKeyEnvKey, identity.String())
+
+	envStorePth := filepath.Join(tmpDir, ".envstore.yml")
+	require.NoError(t, InitJournalEnvStore(envStorePth, false))
+	require.NoError(t, AddEnv(envStorePth, "SECRET", "top secret", true, true, false, true))
+
+	content, err := os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "top secret")
+
+	teammate, err := encryption.GenerateIdentity()
+	require.NoError(t, err)
+	_, err = RekeyEnvStore(envStorePth, false, []string{teammate.Recipient().String()}, nil)
+	require.NoError(t, err)
+
+	content, err = os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(content), "# envman journal v1\n"))
+
+	t.Setenv(envman.EncryptionKeyEnvKey, teammate.String())
+	envs, err := ReadEnvs(envStorePth)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(envs))
+	_, value, err := envs[0].GetKeyValuePair()
+	require.NoError(t, err)
+	require.Equal(t, "top secret", value)
+}
@@ -1,37 +1,44 @@
 package cli
 
-import "fmt"
+import (
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/envstore"
+)
 
-type EnvVarValueTooLargeError struct {
-	Key           string
-	ValueSizeInKB float64
-	MaxSizeInKB   float64
-}
+// EnvVarValueTooLargeError ...
+type EnvVarValueTooLargeError = env.EnvVarValueTooLargeError
 
+// NewEnvVarValueTooLargeError ...
 func NewEnvVarValueTooLargeError(key string, valueSizeInKB, maxSizeInKB float64) error {
-	return EnvVarValueTooLargeError{
-		Key:           key,
-		ValueSizeInKB: valueSizeInKB,
-		MaxSizeInKB:   maxSizeInKB,
-	}
+	return env.NewEnvVarValueTooLargeError(key, valueSizeInKB, maxSizeInKB)
 }
 
-func (err EnvVarValueTooLargeError) Error() string {
-	return fmt.Sprintf("env var (%s) value is too large (%#v KB), max allowed size: %#v KB", err.Key, err.ValueSizeInKB, err.MaxSizeInKB)
-}
+// EnvVarListTooLargeError ...
+type EnvVarListTooLargeError = env.EnvVarListTooLargeError
 
-type EnvVarListTooLargeError struct {
-	EnvListSizeInKB float64
-	MaxSizeInKB     float64
+// NewEnvVarListTooLargeError ...
+func NewEnvVarListTooLargeError(envListSizeInKB, maxSizeInKB float64) EnvVarListTooLargeError {
+	return env.NewEnvVarListTooLargeError(envListSizeInKB, maxSizeInKB)
 }
 
-func NewEnvVarListTooLargeError(envListSizeInKB, maxSizeInKB float64) EnvVarListTooLargeError {
-	return EnvVarListTooLargeError{
-		EnvListSizeInKB: envListSizeInKB,
-		MaxSizeInKB:     maxSizeInKB,
-	}
+// EnvVarTypeError ...
+type EnvVarTypeError = env.EnvVarTypeError
+
+// NewEnvVarTypeError ...
+func NewEnvVarTypeError(key, valueType, reason string) error {
+	return env.NewEnvVarTypeError(key, valueType, reason)
 }
 
-func (e EnvVarListTooLargeError) Error() string {
-	return fmt.Sprintf("env var list is too large (%#v KB), max allowed size: %#v KB", e.EnvListSizeInKB, e.MaxSizeInKB)
+// EnvVarRuleError ...
+type EnvVarRuleError = env.EnvVarRuleError
+
+// NewEnvVarRuleError ...
+func NewEnvVarRuleError(key, rule, reason string) error {
+	return env.NewEnvVarRuleError(key, rule, reason)
 }
+
+// EnvstoreLockedError ...
+type EnvstoreLockedError = envstore.LockedError
+
+// EnvstoreFormatVersionError ...
+type EnvstoreFormatVersionError = envstore.FormatVersionError
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/models"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// maskedValue replaces the sensitive values in the provenance trace.
+const maskedValue = "[REDACTED]"
+
+type referenceTraceJSONModel struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Index  *int   `json:"index,omitempty"`
+}
+
+type declarationTraceJSONModel struct {
+	Action     string                    `json:"action"`
+	Index      int                       `json:"index"`
+	Layer      string                    `json:"layer"`
+	Value      *string                   `json:"value,omitempty"`
+	ValueFrom  *models.ValueSourceModel  `json:"value_from,omitempty"`
+	Sensitive  bool                      `json:"sensitive,omitempty"`
+	References []referenceTraceJSONModel `json:"references,omitempty"`
+}
+
+// envTraceJSONModel is the provenance trace of an env var.
+type envTraceJSONModel struct {
+	Key string `json:"key"`
+	// Source is where the effective value comes from: a declaration, the process environment or undefined
+	Source       string                      `json:"source"`
+	Value        *string                     `json:"value,omitempty"`
+	Declarations []declarationTraceJSONModel `json:"declarations"`
+}
+
+func explainCmd(c *cli.Context) error {
+	key := c.Args().First()
+	if key == "" {
+		log.Fatal("[ENVMAN] - No env var key specified")
+	}
+
+	format := c.String(FormatKey)
+	if format == "" {
+		format = OutputFormatRaw
+	} else if format != OutputFormatRaw && format != OutputFormatJSON {
+		log.Fatalf("[ENVMAN] - Invalid format: %s", format)
+	}
+
+	layers, err := ReadEnvstoreLayers(CurrentEnvStoreFilePaths)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts, err := withConfigLimits(env.DeclarationOptions{ValueSources: env.ValueSourceOptions{AllowCommands: c.Bool(AllowCommandsKey)}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	trace, err := explainEnv(key, layers, &env.DefaultEnvironmentSource{}, opts, c.Bool(ShowInheritedKey))
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to explain env var (%s): %s", key, err)
+	}
+
+	if format == OutputFormatJSON {
+		bytes, err := json.Marshal(trace)
+		if err != nil {
+			log.Fatalf("[ENVMAN] - Failed to print provenance trace: %s", err)
+		}
+		fmt.Println(string(bytes))
+		return nil
+	}
+
+	printEnvTrace(os.Stdout, trace)
+	return nil
+}
+
+// explainEnv returns the provenance trace of the env var's effective value, with the sensitive values masked.
+// The values inherited from the process environment (like a CI token), and the values expanded from them
+// are masked too, unless showInherited is set.
+func explainEnv(key string, layers []EnvstoreLayer, envSource env.EnvironmentSource, opts env.DeclarationOptions, showInherited bool) (envTraceJSONModel, error) {
+	envs, layerIdxs := flattenEnvstoreLayers(layers)
+	opts = layersEvaluationOptions(layers, envSource, opts)
+	result, err := env.GetDeclarationsSideEffectsWithOptions(envs, envSource, opts)
+	if err != nil {
+		return envTraceJSONModel{}, err
+	}
+	masked := maskedDeclarations(result.Provenance, !showInherited)
+
+	trace := envTraceJSONModel{Key: key, Source: string(env.ReferenceUndefined), Declarations: []declarationTraceJSONModel{}}
+	for _, event := range result.Provenance[key] {
+		declaration := declarationTraceJSONModel{
+			Action:    actionName(event.Action),
+			Index:     event.Index,
+			Layer:     layers[layerIdxs[event.Index]].Path,
+			Sensitive: event.Sensitive,
+		}
+		if event.Action == env.SetAction {
+			declaration.Value = traceValue(event.Value, masked[event.Index])
+			declaration.ValueFrom = event.ValueSource
+		}
+		for _, reference := range event.References {
+			referenceTrace := referenceTraceJSONModel{Name: reference.Name, Source: string(reference.Source)}
+			if reference.Source == env.ReferenceFromDeclaration {
+				index := reference.Index
+				referenceTrace.Index = &index
+			}
+			declaration.References = append(declaration.References, referenceTrace)
+		}
+		trace.Declarations = append(trace.Declarations, declaration)
+	}
+
+	// the last set or unset declaration decides the effective value, skipped declarations don't change it
+	var last *declarationTraceJSONModel
+	for i := range trace.Declarations {
+		if trace.Declarations[i].Action != actionName(env.SkipAction) {
+			last = &trace.Declarations[i]
+		}
+	}
+
+	switch value, ok := result.ResultEnvironment[key]; {
+	case last != nil && last.Action == actionName(env.SetAction):
+		trace.Source = string(env.ReferenceFromDeclaration)
+		trace.Value = last.Value
+	case last == nil && ok:
+		trace.Source = string(env.ReferenceFromEnvironment)
+		trace.Value = traceValue(value, !showInherited)
+	}
+
+	return trace, nil
+}
+
+// maskedDeclarations returns the indexes of the declarations with masked values: the sensitive ones,
+// and the ones expanded from a masked value. If maskInherited is set, the values inherited from the process environment
+// are masked as well.
+func maskedDeclarations(provenance map[string][]env.ProvenanceEvent, maskInherited bool) map[int]bool {
+	masked := map[int]bool{}
+	// the declarations are not necessarily evaluated in order (see the graph resolution),
+	// so the masking is propagated until no more declarations are masked
+	for changed := true; changed; {
+		changed = false
+		for _, events := range provenance {
+			for _, event := range events {
+				if event.Action != env.SetAction || masked[event.Index] {
+					continue
+				}
+				mask := event.Sensitive
+				for _, reference := range event.References {
+					switch reference.Source {
+					case env.ReferenceFromEnvironment:
+						mask = mask || maskInherited
+					case env.ReferenceFromDeclaration:
+						mask = mask || masked[reference.Index]
+					}
+				}
+				if mask {
+					masked[event.Index] = true
+					changed = true
+				}
+			}
+		}
+	}
+	return masked
+}
+
+func traceValue(value string, sensitive bool) *string {
+	if sensitive {
+		value = maskedValue
+	}
+	return &value
+}
+
+func actionName(action env.Action) string {
+	switch action {
+	case env.SetAction:
+		return "set"
+	case env.UnsetAction:
+		return "unset"
+	case env.SkipAction:
+		return "skip"
+	default:
+		return "invalid"
+	}
+}
+
+func printEnvTrace(w io.Writer, trace envTraceJSONModel) {
+	switch trace.Source {
+	case string(env.ReferenceUndefined):
+		fmt.Fprintf(w, "%s is not defined\n", trace.Key)
+	case string(env.ReferenceFromEnvironment):
+		fmt.Fprintf(w, "%s=%q (inherited from the process environment)\n", trace.Key, *trace.Value)
+	default:
+		fmt.Fprintf(w, "%s=%q\n", trace.Key, *trace.Value)
+	}
+
+	for _, declaration := range trace.Declarations {
+		fmt.Fprintf(w, "- %s (index %d, layer: %s)", declaration.Action, declaration.Index, declaration.Layer)
+		if declaration.Value != nil {
+			fmt.Fprintf(w, ": %q", *declaration.Value)
+		}
+		fmt.Fprintln(w)
+
+		if source := declaration.ValueFrom; source != nil {
+			switch {
+			case source.File != "":
+				fmt.Fprintf(w, "    value from file: %s\n", source.File)
+			case source.Envstore != "":
+				fmt.Fprintf(w, "    value from envstore: %s (key: %s)\n", source.Envstore, source.Key)
+			case source.Command != "":
+				fmt.Fprintf(w, "    value from command: %s\n", source.Command)
+			}
+		}
+		for _, reference := range declaration.References {
+			switch {
+			case reference.Index != nil:
+				fmt.Fprintf(w, "    $%s from index %d\n", reference.Name, *reference.Index)
+			case reference.Source == string(env.ReferenceFromEnvironment):
+				fmt.Fprintf(w, "    $%s from the process environment\n", reference.Name)
+			default:
+				fmt.Fprintf(w, "    $%s is undefined\n", reference.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainEnv(t *testing.T) {
+	tmpDir := t.TempDir()
+	basePth := filepath.Join(tmpDir, "base.yml")
+	jobPth := filepath.Join(tmpDir, "job.yml")
+
+	require.NoError(t, os.WriteFile(basePth, []byte(`envs:
+- TOKEN: secret
+  opts:
+    is_sensitive: true
+- URL: https://$HOST/api
+`), 0644))
+	require.NoError(t, os.WriteFile(jobPth, []byte(`envs:
+- URL: $URL?token=$TOKEN
+- URL: ""
+  opts:
+    skip_if_empty: true
+`), 0644))
+
+	layers, err := ReadEnvstoreLayers([]string{basePth, jobPth})
+	require.NoError(t, err)
+	envSource := testEnvSource{"HOST": "example.com", "HOME": "/home/john"}
+
+	trace, err := explainEnv("URL", layers, envSource, env.DeclarationOptions{}, true)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	printEnvTrace(&out, trace)
+	require.Equal(t, `URL="[REDACTED]"
+- set (index 1, layer: `+basePth+`): "https://example.com/api"
+    $HOST from the process environment
+- set (index 2, layer: `+jobPth+`): "[REDACTED]"
+    $URL from index 1
+    $TOKEN from index 0
+- skip (index 3, layer: `+jobPth+`)
+`, out.String())
+
+	traceJSON, err := json.Marshal(trace)
+	require.NoError(t, err)
+	require.NotContains(t, string(traceJSON), "secret")
+
+	// the values expanded from the inherited values are masked too
+	trace, err = explainEnv("URL", layers, envSource, env.DeclarationOptions{}, false)
+	require.NoError(t, err)
+	out.Reset()
+	printEnvTrace(&out, trace)
+	require.Equal(t, `URL="[REDACTED]"
+- set (index 1, layer: `+basePth+`): "[REDACTED]"
+    $HOST from the process environment
+- set (index 2, layer: `+jobPth+`): "[REDACTED]"
+    $URL from index 1
+    $TOKEN from index 0
+- skip (index 3, layer: `+jobPth+`)
+`, out.String())
+	traceJSON, err = json.Marshal(trace)
+	require.NoError(t, err)
+	require.NotContains(t, string(traceJSON), "example.com")
+
+	trace, err = explainEnv("HOME", layers, envSource, env.DeclarationOptions{}, false)
+	require.NoError(t, err)
+	out.Reset()
+	printEnvTrace(&out, trace)
+	require.Equal(t, "HOME=\"[REDACTED]\" (inherited from the process environment)\n", out.String())
+	traceJSON, err = json.Marshal(trace)
+	require.NoError(t, err)
+	require.NotContains(t, string(traceJSON), "/home/john")
+
+	trace, err = explainEnv("HOME", layers, envSource, env.DeclarationOptions{}, true)
+	require.NoError(t, err)
+	out.Reset()
+	printEnvTrace(&out, trace)
+	require.Equal(t, "HOME=\"/home/john\" (inherited from the process environment)\n", out.String())
+
+	trace, err = explainEnv("MISSING", layers, envSource, env.DeclarationOptions{}, false)
+	require.NoError(t, err)
+	out.Reset()
+	printEnvTrace(&out, trace)
+	require.Equal(t, "MISSING is not defined\n", out.String())
+}
@@ -13,6 +13,16 @@ const (
 	PathKey      = "path"
 	pathKeyShort = "p"
 
+	// LayerEnvKey ...
+	LayerEnvKey = "ENVMAN_ENVSTORE_LAYER"
+	// LayerKey ...
+	LayerKey = "layer"
+
+	// ProfileEnvKey ...
+	ProfileEnvKey = "ENVMAN_PROFILE"
+	// ProfileKey ...
+	ProfileKey = "profile"
+
 	// LogLevelEnvKey ...
 	LogLevelEnvKey = "LOGLEVEL"
 	// LogLevelKey ...
@@ -44,6 +54,34 @@ const (
 
 	// SensitiveKey ...
 	SensitiveKey = "sensitive"
+	// AllowCommandsKey ...
+	AllowCommandsKey = "allow-commands"
+	// TemplateKey ...
+	TemplateKey = "template"
+	// TypeKey ...
+	TypeKey = "type"
+	// ValueOptionKey ...
+	ValueOptionKey = "value-option"
+	// PrependKey ...
+	PrependKey = "prepend"
+	// AppendItemKey ...
+	AppendItemKey = "append-item"
+	// RemoveItemKey ...
+	RemoveItemKey = "remove-item"
+	// SeparatorKey ...
+	SeparatorKey = "separator"
+	// DedupeKey ...
+	DedupeKey = "dedupe"
+	// PatternKey ...
+	PatternKey = "pattern"
+	// MinLengthKey ...
+	MinLengthKey = "min-length"
+	// MaxLengthKey ...
+	MaxLengthKey = "max-length"
+	// MinKey ...
+	MinKey = "min"
+	// MaxKey ...
+	MaxKey = "max"
 
 	// ToolEnvKey ...
 	ToolEnvKey = "ENVMAN_TOOLMODE"
@@ -51,10 +89,36 @@ const (
 	ToolKey      = "tool"
 	toolKeyShort = "t"
 
+	// LockTimeoutEnvKey ...
+	LockTimeoutEnvKey = "ENVMAN_LOCK_TIMEOUT"
+	// LockTimeoutKey ...
+	LockTimeoutKey = "lock-timeout"
+
 	// ClearKey ....
 	ClearKey      = "clear"
 	clearKeyShort = "c"
 
+	// JournalKey ...
+	JournalKey = "journal"
+	// ExpansionKey ...
+	ExpansionKey = "expansion"
+	// ResolutionKey ...
+	ResolutionKey = "resolution"
+
+	// KeepKeyKey ...
+	KeepKeyKey = "keep-key"
+	// AddRecipientKey ...
+	AddRecipientKey = "add-recipient"
+	// RemoveRecipientKey ...
+	RemoveRecipientKey = "remove-recipient"
+
+	// GlobalKey ...
+	GlobalKey = "global"
+	// UseKey ...
+	UseKey = "use"
+	// ResetKey ...
+	ResetKey = "reset"
+
 	// HelpKey ...
 	HelpKey      = "help"
 	helpKeyShort = "h"
@@ -65,6 +129,16 @@ const (
 
 	// ExpandKey ...
 	ExpandKey = "expand"
+	// StrictKey ...
+	StrictKey = "strict"
+	// StrictWarnKey ...
+	StrictWarnKey = "strict-warn"
+	// ValidateKey ...
+	ValidateKey = "validate"
+	// ShowLayerKey ...
+	ShowLayerKey = "show-layer"
+	// ShowInheritedKey ...
+	ShowInheritedKey = "show-inherited"
 	// SensitiveOnlyKey ...
 	SensitiveOnlyKey = "sensitive-only"
 	// FormatKey ...
@@ -75,6 +149,16 @@ const (
 	OutputFormatJSON = "json"
 	// OutputFormatExport
 	OutputFormatEnvList = "envlist"
+	// OutputFormatPatch ...
+	OutputFormatPatch = "patch"
+	// AgainstProcessEnvKey ...
+	AgainstProcessEnvKey = "against-process-env"
+	// DryRunKey ...
+	DryRunKey = "dry-run"
+	// LiteralKey ...
+	LiteralKey = "literal"
+	// PrefixKey ...
+	PrefixKey = "prefix"
 )
 
 var (
@@ -85,21 +169,38 @@ var (
 		Usage:  "Log level (options: debug, info, warn, error, fatal, panic).",
 		EnvVar: LogLevelEnvKey,
 	}
-	flPath = cli.StringFlag{
-		Name:   PathKey + ", " + pathKeyShort,
-		EnvVar: PathEnvKey,
-		Value:  "",
-		Usage:  "Path of the envstore.",
+	// flPath is not bound to PathEnvKey by the flag, the env var's value is split by the OS path list separator (see before)
+	flPath = cli.StringSliceFlag{
+		Name:  PathKey + ", " + pathKeyShort,
+		Usage: "Path of the envstore, or its location with a storage backend scheme (file://PATH, dir://PATH, mem://NAME) [$" + PathEnvKey + "]. Multiple envstores (layers) can be specified by repeating the flag, or as a list separated by the OS path list separator (':'), these are evaluated in order. The path of an existing envstore is never split.",
+	}
+	flLayer = cli.StringFlag{
+		Name:   LayerKey,
+		EnvVar: LayerEnvKey,
+		Usage:  "The envstore layer modified by the write commands (add, unset, clear...), as a 1-based index or path. Required if multiple layers are specified.",
+	}
+	flProfile = cli.StringFlag{
+		Name:   ProfileKey,
+		EnvVar: ProfileEnvKey,
+		Usage:  "Name of the profile to use, if no envstore path is specified. Defaults to the active profile (see: envman profile use).",
 	}
 	flTool = cli.BoolFlag{
 		Name:   ToolKey + ", " + toolKeyShort,
 		EnvVar: ToolEnvKey,
 		Usage:  "If enabled, envman will NOT ask for user inputs.",
 	}
+	flLockTimeout = cli.DurationFlag{
+		Name:   LockTimeoutKey,
+		EnvVar: LockTimeoutEnvKey,
+		Usage:  "How long to wait for another process to release the envstore lock (for example: 10s). Defaults to the lock_timeout_in_secs config.",
+	}
 	flags = []cli.Flag{
 		flLogLevel,
 		flPath,
+		flLayer,
+		flProfile,
 		flTool,
+		flLockTimeout,
 	}
 
 	// Command flags
@@ -127,6 +228,26 @@ var (
 		Name:  ClearKey + ", " + clearKeyShort,
 		Usage: "If enabled, 'envman init' removes envstore if exist.",
 	}
+	flJournal = cli.BoolFlag{
+		Name:  JournalKey,
+		Usage: "If enabled, 'envman init' creates an append-only journal envstore: add and unset append a record instead of rewriting the envstore. Use 'envman compact' to convert it back to the classic layout.",
+	}
+	flExpansion = cli.StringFlag{
+		Name:  ExpansionKey,
+		Usage: "The default expansion of the envstore's envs: simple ($VAR and ${VAR} references), bash (bash parameter expansion operators too, like ${VAR:-default}) or template (is_template values are rendered as Go text/templates).",
+	}
+	flAllowCommands = cli.BoolFlag{
+		Name:  AllowCommandsKey,
+		Usage: "If enabled, the command value sources (value_from: command) are run during the evaluation, they are refused otherwise.",
+	}
+	flResolution = cli.StringFlag{
+		Name:  ResolutionKey,
+		Usage: "The resolution of the envstore's envs: ordered (a value can reference the preceding envs) or graph (the envs are evaluated in the order of their references, a value can reference the later envs too).",
+	}
+	flGlobal = cli.BoolFlag{
+		Name:  GlobalKey,
+		Usage: "If enabled, the profile is activated globally, instead of for the current working directory.",
+	}
 	flFormat = cli.StringFlag{
 		Name:  FormatKey,
 		Usage: fmt.Sprintf("Output format (options: %s, %s, %s).", OutputFormatRaw, OutputFormatJSON, OutputFormatEnvList),
@@ -135,4 +256,12 @@ var (
 		Name:  ExpandKey,
 		Usage: "If enabled, expanded envs will use.",
 	}
+	flStrict = cli.BoolFlag{
+		Name:  StrictKey,
+		Usage: "If enabled, referencing an undefined env var fails the expansion (unless the env or its envstore sets the strict option).",
+	}
+	flStrictWarn = cli.BoolFlag{
+		Name:  StrictWarnKey,
+		Usage: "If enabled, the references to undefined env vars are logged as warnings (unless the env or its envstore sets the strict option).",
+	}
 )
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/envstore"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// shortHashLength is the number of hex digits of the content hashes printed by the history command.
+const shortHashLength = 12
+
+type historyJSONModel struct {
+	Revisions   []envstore.Revision   `json:"revisions"`
+	Checkpoints []envstore.Checkpoint `json:"checkpoints"`
+}
+
+func historyCmd(c *cli.Context) error {
+	ensureWriteLayer()
+
+	format := c.String(FormatKey)
+	if format == "" {
+		format = OutputFormatRaw
+	} else if format != OutputFormatRaw && format != OutputFormatJSON {
+		log.Fatalf("[ENVMAN] - Invalid format: %s", format)
+	}
+
+	history, err := envstoreHistory(CurrentEnvStoreFilePath)
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to read EnvStore history: %s", err)
+	}
+
+	if format == OutputFormatJSON {
+		bytes, err := json.Marshal(history)
+		if err != nil {
+			log.Fatalf("[ENVMAN] - Failed to print EnvStore history: %s", err)
+		}
+		fmt.Println(string(bytes))
+		return nil
+	}
+
+	printHistory(os.Stdout, history)
+	return nil
+}
+
+func undoCmd(_ *cli.Context) error {
+	ensureWriteLayer()
+
+	log.Debugln("[ENVMAN] - Work path:", CurrentEnvStoreFilePath)
+
+	revision, err := UndoEnvStore(CurrentEnvStoreFilePath)
+	if err != nil {
+		log.Fatal("[ENVMAN] - Failed to undo:", err)
+	}
+
+	log.Infof("[ENVMAN] - Undone: %s (revision %d)", revision.Command, revision.ID)
+
+	return nil
+}
+
+func checkpointCmd(c *cli.Context) error {
+	ensureWriteLayer()
+
+	name := c.Args().First()
+	if name == "" {
+		log.Fatal("[ENVMAN] - No checkpoint name specified")
+	}
+
+	if err := CheckpointEnvStore(CurrentEnvStoreFilePath, name); err != nil {
+		log.Fatal("[ENVMAN] - Failed to create checkpoint:", err)
+	}
+
+	log.Infof("[ENVMAN] - Checkpoint (%s) created", name)
+
+	return nil
+}
+
+func restoreCmd(c *cli.Context) error {
+	ensureWriteLayer()
+
+	name := c.Args().First()
+	if name == "" {
+		log.Fatal("[ENVMAN] - No checkpoint name specified")
+	}
+
+	if err := RestoreEnvStore(CurrentEnvStoreFilePath, name); err != nil {
+		log.Fatal("[ENVMAN] - Failed to restore checkpoint:", err)
+	}
+
+	log.Infof("[ENVMAN] - Checkpoint (%s) restored", name)
+
+	return nil
+}
+
+// envstoreHistory returns the recorded revisions and the checkpoints of the envstore.
+func envstoreHistory(envStorePth string) (historyJSONModel, error) {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return historyJSONModel{}, err
+	}
+
+	revisions, checkpoints, err := store.History(context.Background())
+	if err != nil {
+		return historyJSONModel{}, err
+	}
+
+	history := historyJSONModel{Revisions: revisions, Checkpoints: checkpoints}
+	if history.Revisions == nil {
+		history.Revisions = []envstore.Revision{}
+	}
+	if history.Checkpoints == nil {
+		history.Checkpoints = []envstore.Checkpoint{}
+	}
+	return history, nil
+}
+
+// UndoEnvStore restores the envstore's content before its last recorded modification, and returns that revision.
+func UndoEnvStore(envStorePth string) (envstore.Revision, error) {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return envstore.Revision{}, err
+	}
+	return store.Undo(context.Background())
+}
+
+// CheckpointEnvStore saves the envstore's current content as a named checkpoint.
+func CheckpointEnvStore(envStorePth, name string) error {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return err
+	}
+	return store.Checkpoint(context.Background(), name)
+}
+
+// RestoreEnvStore replaces the envstore's content with the named checkpoint.
+func RestoreEnvStore(envStorePth, name string) error {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return err
+	}
+	return store.Restore(context.Background(), name)
+}
+
+// printHistory prints the revisions newest first (the one undo restores is on the top), then the checkpoints.
+func printHistory(w io.Writer, history historyJSONModel) {
+	if len(history.Revisions) == 0 {
+		fmt.Fprintln(w, "No revisions")
+	} else {
+		fmt.Fprintln(w, "Revisions:")
+	}
+	for i := len(history.Revisions) - 1; i >= 0; i-- {
+		revision := history.Revisions[i]
+		fmt.Fprintf(w, "  %d  %s  %s  %s\n", revision.ID, revision.Time.Format(time.RFC3339), shortHash(revision.Hash), revision.Command)
+	}
+
+	if len(history.Checkpoints) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Checkpoints:")
+	for _, checkpoint := range history.Checkpoints {
+		fmt.Fprintf(w, "  %s  %s  %s\n", checkpoint.Name, checkpoint.Time.Format(time.RFC3339), shortHash(checkpoint.Hash))
+	}
+}
+
+// shortHash shortens the content hash, the content of an envstore which did not exist is printed as "(none)".
+func shortHash(hash string) string {
+	if hash == "" {
+		return "(none)"
+	}
+	algorithm, sum, found := strings.Cut(hash, ":")
+	if !found || len(sum) <= shortHashLength {
+		return hash
+	}
+	return algorithm + ":" + sum[:shortHashLength]
+}
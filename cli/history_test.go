@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/envstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintHistory(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	var out bytes.Buffer
+	printHistory(&out, historyJSONModel{})
+	require.Equal(t, "No revisions\n", out.String())
+
+	out.Reset()
+	printHistory(&out, historyJSONModel{
+		Revisions: []envstore.Revision{
+			{ID: 1, Command: "init", Time: at},
+			{ID: 2, Command: "add TOKEN", Time: at, Hash: "sha256:0123456789abcdef0123"},
+		},
+		Checkpoints: []envstore.Checkpoint{
+			{Name: "release", Time: at, Hash: "sha256:fedcba9876543210fedc"},
+		},
+	})
+	require.Equal(t, `Revisions:
+  2  2026-10-18T12:00:00Z  sha256:0123456789ab  add TOKEN
+  1  2026-10-18T12:00:00Z  (none)  init
+Checkpoints:
+  release  2026-10-18T12:00:00Z  sha256:fedcba987654
+`, out.String())
+}
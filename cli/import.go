@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bitrise-io/envman/v2/envstore"
+	"github.com/bitrise-io/envman/v2/importer"
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+// importOptions configure how the imported entries are declared in the envstore.
+type importOptions struct {
+	// NoExpand imports every value as is
+	NoExpand bool
+	// LiteralKeys are the keys of the entries imported as is, even if they reference other env vars
+	LiteralKeys []string
+}
+
+func importCmd(c *cli.Context) error {
+	ensureWriteLayer()
+
+	pth := c.Args().First()
+	if pth == "" {
+		log.Fatal("[ENVMAN] - No file specified, use - to read the standard input")
+	}
+
+	format := c.String(FormatKey)
+	if format == "" {
+		format = importer.FormatDotenv
+	}
+
+	var content []byte
+	var err error
+	if pth == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(pth)
+	}
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to read import file: %s", err)
+	}
+
+	entries, err := importer.Parse(format, content, importer.Options{Separator: c.String(SeparatorKey), Prefix: c.String(PrefixKey)})
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to parse import file (%s): %s", pth, err)
+	}
+
+	envs := importedEnvs(entries, importOptions{NoExpand: c.Bool(NoExpandKey), LiteralKeys: c.StringSlice(LiteralKey)})
+	replace := !c.Bool(AppendKey)
+
+	if c.Bool(DryRunKey) {
+		store, err := openEnvstore(CurrentEnvStoreFilePath)
+		if err != nil {
+			log.Fatalf("[ENVMAN] - Failed to preview import: %s", err)
+		}
+		environments, err := store.ListOwn(context.Background())
+		if err != nil {
+			log.Fatalf("[ENVMAN] - Failed to preview import: %s", err)
+		}
+		if err := printImportPreview(os.Stdout, environments, envs, replace); err != nil {
+			log.Fatalf("[ENVMAN] - Failed to preview import: %s", err)
+		}
+		return nil
+	}
+
+	if err := ImportEnvs(CurrentEnvStoreFilePath, envs, replace); err != nil {
+		log.Fatalf("[ENVMAN] - Failed to import env vars: %s", err)
+	}
+
+	log.Infof("[ENVMAN] - %d env var(s) imported", len(envs))
+
+	return nil
+}
+
+// importedEnvs returns the envs declared by the imported entries: the entries referencing other env vars are expanded,
+// unless they are imported as is (opts). The sensitive entries (like the data of Kubernetes Secrets) are marked as sensitive.
+func importedEnvs(entries []importer.Entry, opts importOptions) []models.EnvironmentItemModel {
+	literalKeys := map[string]bool{}
+	for _, key := range opts.LiteralKeys {
+		literalKeys[key] = true
+	}
+
+	var envs []models.EnvironmentItemModel
+	for _, entry := range entries {
+		expand := entry.Expand && !opts.NoExpand && !literalKeys[entry.Key]
+
+		envOpts := models.EnvironmentItemOptionsModel{IsExpand: pointers.NewBoolPtr(expand)}
+		if entry.Sensitive {
+			envOpts.IsSensitive = pointers.NewBoolPtr(true)
+		}
+		value := entry.Value
+		if expand {
+			value = entry.ExpandableValue
+			if entry.Expansion != "" {
+				envOpts.Expansion = pointers.NewStringPtr(entry.Expansion)
+			}
+		}
+		envs = append(envs, models.EnvironmentItemModel{entry.Key: value, models.OptionsKey: envOpts})
+	}
+	return envs
+}
+
+// ImportEnvs adds the envs to the envstore in a single modification, each env is validated like the added ones:
+// the existing envs with the same key are replaced, unless replace is false.
+func ImportEnvs(envStorePth string, envs []models.EnvironmentItemModel, replace bool) error {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return err
+	}
+
+	return store.Import(context.Background(), envs, envstore.AddOptions{
+		Append:   !replace,
+		Validate: validateAddedEnv,
+	})
+}
+
+// printImportPreview prints what importing the envs would change: + for the added, ~ for the replaced envs.
+// The sensitive values are masked.
+func printImportPreview(w io.Writer, environments, envs []models.EnvironmentItemModel, replace bool) error {
+	if len(envs) == 0 {
+		fmt.Fprintln(w, "No env vars to import")
+		return nil
+	}
+
+	for _, newEnv := range envs {
+		key, value, err := newEnv.GetKeyValuePair()
+		if err != nil {
+			return err
+		}
+		opts, err := newEnv.GetOptions()
+		if err != nil {
+			return err
+		}
+
+		var previous *string
+		previousSensitive := false
+		for _, env := range environments {
+			envKey, envValue, err := env.GetKeyValuePair()
+			if err != nil {
+				return err
+			}
+			if envKey != key {
+				continue
+			}
+			envOpts, err := env.GetOptions()
+			if err != nil {
+				return err
+			}
+			previous = &envValue
+			previousSensitive = envOpts.IsSensitive != nil && *envOpts.IsSensitive
+		}
+
+		sensitive := opts.IsSensitive != nil && *opts.IsSensitive
+		line := fmt.Sprintf("%s=%q", key, *traceValue(value, sensitive))
+		if opts.IsExpand != nil && *opts.IsExpand {
+			line += " (expanded)"
+		}
+		if replace && previous != nil {
+			fmt.Fprintf(w, "~ %s, was %q\n", line, *traceValue(*previous, previousSensitive))
+		} else {
+			fmt.Fprintf(w, "+ %s\n", line)
+		}
+
+		environments, err = envstore.UpsertEnv(environments, newEnv, replace)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
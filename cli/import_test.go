@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/envstore"
+	"github.com/bitrise-io/envman/v2/importer"
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportEnvs(t *testing.T) {
+	tmpDir := t.TempDir()
+	envStorePth := filepath.Join(tmpDir, ".envstore.yml")
+	require.NoError(t, InitEnvStore(envStorePth, false))
+	require.NoError(t, AddEnv(envStorePth, "BRANCH", "dev", true, true, false, false))
+
+	entries, err := importer.ParseDotenv("BRANCH=main\nBIN_DIR=\"$HOME/bin\"\nPRICE=\"\\$5 for $USER\"\nRAW=$HOME\n")
+	require.NoError(t, err)
+	envs := importedEnvs(entries, importOptions{LiteralKeys: []string{"RAW"}})
+
+	var preview bytes.Buffer
+	store, err := envstore.New(envStorePth, envstore.Options{})
+	require.NoError(t, err)
+	environments, err := store.ListOwn(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, printImportPreview(&preview, environments, envs, true))
+	require.Equal(t, `~ BRANCH="main", was "dev"
++ BIN_DIR="$HOME/bin" (expanded)
++ PRICE="$$5 for $USER" (expanded)
++ RAW="$HOME"
+`, preview.String())
+
+	require.NoError(t, ImportEnvs(envStorePth, envs, true))
+
+	value, found, err := store.Get(context.Background(), "BRANCH")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "main", value)
+
+	environments, err = store.ListOwn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 4, len(environments))
+
+	for _, tc := range []struct {
+		key    string
+		value  string
+		expand bool
+	}{
+		{key: "BIN_DIR", value: "$HOME/bin", expand: true},
+		{key: "PRICE", value: "$$5 for $USER", expand: true},
+		{key: "RAW", value: "$HOME", expand: false},
+	} {
+		var env models.EnvironmentItemModel
+		for _, e := range environments {
+			if key, _, err := e.GetKeyValuePair(); err == nil && key == tc.key {
+				env = e
+			}
+		}
+		require.NotNil(t, env, tc.key)
+		_, value, err := env.GetKeyValuePair()
+		require.NoError(t, err)
+		require.Equal(t, tc.value, value)
+		opts, err := env.GetOptions()
+		require.NoError(t, err)
+		require.Equal(t, tc.expand, *opts.IsExpand, tc.key)
+	}
+
+	require.NoError(t, ImportEnvs(envStorePth, importedEnvs(entries[:1], importOptions{}), false))
+	environments, err = store.ListOwn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 5, len(environments))
+}
+
+func TestImportedEnvs_Sensitive(t *testing.T) {
+	envs := importedEnvs([]importer.Entry{
+		{Key: "API_TOKEN", Value: "s3cr3t", Sensitive: true},
+		{Key: "APP_ENV", Value: "production"},
+	}, importOptions{})
+
+	opts, err := envs[0].GetOptions()
+	require.NoError(t, err)
+	require.True(t, *opts.IsSensitive)
+	opts, err = envs[1].GetOptions()
+	require.NoError(t, err)
+	require.Nil(t, opts.IsSensitive)
+
+	var preview bytes.Buffer
+	require.NoError(t, printImportPreview(&preview, nil, envs, true))
+	require.Equal(t, `+ API_TOKEN="[REDACTED]"
++ APP_ENV="production"
+`, preview.String())
+}
@@ -1,32 +1,45 @@
 package cli
 
 import (
-	"fmt"
+	"context"
 
-	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/envman/v2/envstore"
+	"github.com/bitrise-io/envman/v2/models"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
 func initEnvStore(c *cli.Context) error {
+	ensureWriteLayer()
+
 	log.Debugln("[ENVMAN] - Work path:", CurrentEnvStoreFilePath)
-	clearEnvstore := c.Bool(ClearKey)
-	err := InitEnvStore(CurrentEnvStoreFilePath, clearEnvstore)
+	opts := envstore.InitOptions{
+		Journal: c.Bool(JournalKey),
+		Clear:   c.Bool(ClearKey),
+	}
+	if expansion, resolution := c.String(ExpansionKey), c.String(ResolutionKey); expansion != "" || resolution != "" {
+		opts.Defaults = &models.EnvstoreDefaultsModel{Expansion: expansion, Resolution: resolution}
+	}
+
+	err := initEnvStoreWithOptions(CurrentEnvStoreFilePath, opts)
 	log.Debugln("[ENVMAN] - Initialized")
 	return err
 }
 
 // InitEnvStore ...
 func InitEnvStore(envStorePth string, clearEnvstore bool) error {
-	if clearEnvstore {
-		if err := command.RemoveFile(envStorePth); err != nil {
-			return fmt.Errorf("failed to clear path: %s", err)
-		}
-	}
+	return initEnvStoreWithOptions(envStorePth, envstore.InitOptions{Clear: clearEnvstore})
+}
 
-	if err := initAtPath(envStorePth); err != nil {
-		return fmt.Errorf("failed to init at path: %s", err)
-	}
+// InitJournalEnvStore creates an empty, append-only journal envstore.
+func InitJournalEnvStore(envStorePth string, clearEnvstore bool) error {
+	return initEnvStoreWithOptions(envStorePth, envstore.InitOptions{Journal: true, Clear: clearEnvstore})
+}
 
-	return nil
+func initEnvStoreWithOptions(envStorePth string, opts envstore.InitOptions) error {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return err
+	}
+	return store.Init(context.Background(), opts)
 }
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/envstore"
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pathutil"
+	log "github.com/sirupsen/logrus"
+)
+
+// EnvstoreLayer is one envstore of an ordered list of envstores, which are evaluated as one:
+// the envs of the later layers can reference and override the envs of the earlier ones.
+type EnvstoreLayer struct {
+	Path string
+	Envs []models.EnvironmentItemModel
+	// Resolution is the default resolution of the envstore (if set)
+	Resolution string
+}
+
+// ReadEnvstoreLayers reads the envstores in order.
+func ReadEnvstoreLayers(envStorePths []string) ([]EnvstoreLayer, error) {
+	var layers []EnvstoreLayer
+	for _, pth := range envStorePths {
+		layer, err := readEnvstoreLayer(pth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read envstore layer (%s): %s", pth, err)
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+func readEnvstoreLayer(pth string) (EnvstoreLayer, error) {
+	store, err := openEnvstore(pth)
+	if err != nil {
+		return EnvstoreLayer{}, err
+	}
+
+	envs, resolution, err := store.ListWithResolution(context.Background())
+	if err != nil {
+		return EnvstoreLayer{}, err
+	}
+	return EnvstoreLayer{Path: pth, Envs: envs, Resolution: resolution}, nil
+}
+
+// layersEvaluationOptions returns the declaration options of evaluating the layers (the options set by opts are kept):
+// the layers are evaluated as one, in the graph resolution mode if any of the layers selects it,
+// and the envstore value sources are evaluated in the same initial environment.
+func layersEvaluationOptions(layers []EnvstoreLayer, envSource env.EnvironmentSource, opts env.DeclarationOptions) env.DeclarationOptions {
+	var resolutions []string
+	for _, layer := range layers {
+		resolutions = append(resolutions, layer.Resolution)
+	}
+	return envstore.LayersEvaluationOptions(resolutions, opts, envstore.Options{EnvSource: envSource, Evaluation: opts})
+}
+
+// flattenEnvstoreLayers concatenates the envs of the layers,
+// and returns the index of the layer each env is declared in.
+func flattenEnvstoreLayers(layers []EnvstoreLayer) ([]models.EnvironmentItemModel, []int) {
+	envs := []models.EnvironmentItemModel{}
+	var layerIdxs []int
+	for i, layer := range layers {
+		for _, env := range layer.Envs {
+			envs = append(envs, env)
+			layerIdxs = append(layerIdxs, i)
+		}
+	}
+	return envs, layerIdxs
+}
+
+// ReadLayeredEnvs reads the envstores and concatenates their envs in order.
+func ReadLayeredEnvs(envStorePths []string) ([]models.EnvironmentItemModel, error) {
+	layers, err := ReadEnvstoreLayers(envStorePths)
+	if err != nil {
+		return nil, err
+	}
+
+	envs, _ := flattenEnvstoreLayers(layers)
+	return envs, nil
+}
+
+// ReadAndEvaluateLayeredEnvs ...
+func ReadAndEvaluateLayeredEnvs(envStorePths []string, envSource env.EnvironmentSource) ([]string, error) {
+	return readAndEvaluateLayeredEnvs(envStorePths, envSource, env.DeclarationOptions{})
+}
+
+func readAndEvaluateLayeredEnvs(envStorePths []string, envSource env.EnvironmentSource, opts env.DeclarationOptions) ([]string, error) {
+	layers, err := ReadEnvstoreLayers(envStorePths)
+	if err != nil {
+		return nil, err
+	}
+
+	envs, _ := flattenEnvstoreLayers(layers)
+	return evaluateEnvs(envs, envSource, layersEvaluationOptions(layers, envSource, opts))
+}
+
+// splitEnvstorePathList splits the list of envstore paths (or locations) separated by the OS path list separator,
+// the path of an existing envstore is not split (even if it contains the separator).
+func splitEnvstorePathList(pathList string) []string {
+	if exists, err := pathutil.IsPathExists(pathList); err == nil && exists {
+		return []string{pathList}
+	}
+	return envstore.SplitLocationList(pathList)
+}
+
+// selectWriteLayer returns the envstore layer modified by the write commands:
+// the layer selected by its 1-based index or its path, or the only layer.
+func selectWriteLayer(envStorePths []string, layer string) (string, error) {
+	if layer == "" {
+		if len(envStorePths) == 1 {
+			return envStorePths[0], nil
+		}
+		return "", nil
+	}
+
+	if idx, err := strconv.Atoi(layer); err == nil {
+		if idx < 1 || idx > len(envStorePths) {
+			return "", fmt.Errorf("layer index (%d) out of range, %d layers are specified", idx, len(envStorePths))
+		}
+		return envStorePths[idx-1], nil
+	}
+
+	for _, pth := range envStorePths {
+		if filepath.Clean(pth) == filepath.Clean(layer) {
+			return pth, nil
+		}
+	}
+	return "", fmt.Errorf("layer (%s) is not one of the specified envstores", layer)
+}
+
+// ensureWriteLayer stops the write commands if multiple envstore layers are used without selecting one.
+func ensureWriteLayer() {
+	if CurrentEnvStoreFilePath == "" {
+		log.Fatalf("[ENVMAN] - %d envstore layers are specified, select the one to modify with --%s", len(CurrentEnvStoreFilePaths), LayerKey)
+	}
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnvSource map[string]string
+
+func (s testEnvSource) GetEnvironment() map[string]string {
+	envs := map[string]string{}
+	for key, value := range s {
+		envs[key] = value
+	}
+	return envs
+}
+
+func TestLayeredEnvs(t *testing.T) {
+	tmpDir := t.TempDir()
+	basePth := filepath.Join(tmpDir, "base.yml")
+	projectPth := filepath.Join(tmpDir, "project.yml")
+	jobPth := filepath.Join(tmpDir, "job.yml")
+
+	require.NoError(t, os.WriteFile(basePth, []byte(`envs:
+- BUILD_DIR: /tmp/build
+- CONFIGURATION: Debug
+- TOKEN: secret
+`), 0644))
+	require.NoError(t, os.WriteFile(projectPth, []byte(`envs:
+- OUTPUT_DIR: $BUILD_DIR/output
+- CONFIGURATION: Release
+`), 0644))
+	require.NoError(t, os.WriteFile(jobPth, []byte(`envs:
+- TOKEN: ""
+  opts:
+    unset: true
+`), 0644))
+
+	layerPths := []string{basePth, projectPth, jobPth}
+	envSource := testEnvSource{}
+
+	envsJSON, err := ReadLayeredEnvsJSONList(layerPths, true, false, envSource)
+	require.NoError(t, err)
+	require.Equal(t, "/tmp/build/output", envsJSON["OUTPUT_DIR"])
+	require.Equal(t, "Release", envsJSON["CONFIGURATION"])
+	_, found := envsJSON["TOKEN"]
+	require.False(t, found)
+
+	layers, err := ReadEnvstoreLayers(layerPths)
+	require.NoError(t, err)
+	envLayers, err := envLayerPaths(layers, true, false, envSource, env.DeclarationOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"BUILD_DIR":     basePth,
+		"CONFIGURATION": projectPth,
+		"OUTPUT_DIR":    projectPth,
+	}, envLayers)
+
+	_, err = ReadLayeredEnvs([]string{basePth, filepath.Join(tmpDir, "missing.yml")})
+	require.Error(t, err)
+}
+
+func TestLayeredEnvs_GraphResolution(t *testing.T) {
+	tmpDir := t.TempDir()
+	basePth := filepath.Join(tmpDir, "base.yml")
+	jobPth := filepath.Join(tmpDir, "job.yml")
+
+	require.NoError(t, os.WriteFile(basePth, []byte(`envs:
+- URL: https://$HOST/api
+`), 0644))
+	require.NoError(t, os.WriteFile(jobPth, []byte(`defaults:
+  resolution: graph
+envs:
+- HOST: example.com
+`), 0644))
+
+	layerPths := []string{basePth, jobPth}
+	envsJSON, err := ReadLayeredEnvsJSONList(layerPths, true, false, testEnvSource{})
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/api", envsJSON["URL"])
+
+	layers, err := ReadEnvstoreLayers(layerPths)
+	require.NoError(t, err)
+	envLayers, err := envLayerPaths(layers, true, false, testEnvSource{}, layersEvaluationOptions(layers, testEnvSource{}, env.DeclarationOptions{}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"URL": basePth, "HOST": jobPth}, envLayers)
+}
+
+func TestSelectWriteLayer(t *testing.T) {
+	pths := []string{"/base.yml", "/project.yml"}
+
+	layer, err := selectWriteLayer(pths, "")
+	require.NoError(t, err)
+	require.Equal(t, "", layer)
+
+	layer, err = selectWriteLayer(pths[:1], "")
+	require.NoError(t, err)
+	require.Equal(t, "/base.yml", layer)
+
+	layer, err = selectWriteLayer(pths, "2")
+	require.NoError(t, err)
+	require.Equal(t, "/project.yml", layer)
+
+	layer, err = selectWriteLayer(pths, "/base.yml")
+	require.NoError(t, err)
+	require.Equal(t, "/base.yml", layer)
+
+	_, err = selectWriteLayer(pths, "3")
+	require.EqualError(t, err, "layer index (3) out of range, 2 layers are specified")
+
+	_, err = selectWriteLayer(pths, "/job.yml")
+	require.EqualError(t, err, "layer (/job.yml) is not one of the specified envstores")
+}
@@ -21,21 +21,43 @@ func printCmd(c *cli.Context) error {
 
 	expand := c.Bool(ExpandKey)
 	sensitiveOnly := c.Bool(SensitiveOnlyKey)
+	showLayer := c.Bool(ShowLayerKey)
+	opts := strictOptions(c.Bool(StrictKey), c.Bool(StrictWarnKey))
+	opts.ValueSources.AllowCommands = c.Bool(AllowCommandsKey)
+	opts, err := withConfigLimits(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Read envs
-	envSet, err := ReadEnvsJSONList(CurrentEnvStoreFilePath, expand, sensitiveOnly, &env.DefaultEnvironmentSource{})
+	layers, err := ReadEnvstoreLayers(CurrentEnvStoreFilePaths)
+	if err != nil {
+		log.Fatal(err)
+	}
+	envs, _ := flattenEnvstoreLayers(layers)
+	opts = layersEvaluationOptions(layers, &env.DefaultEnvironmentSource{}, opts)
+
+	envSet, err := convertToEnvsJSONModel(envs, expand, sensitiveOnly, &env.DefaultEnvironmentSource{}, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	var envLayers map[string]string
+	if showLayer {
+		envLayers, err = envLayerPaths(layers, expand, sensitiveOnly, &env.DefaultEnvironmentSource{}, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// Print envs
 	switch format {
 	case OutputFormatRaw:
-		printRawEnvs(envSet)
+		printRawEnvs(envSet, envLayers)
 	case OutputFormatEnvList:
-		printEnvsList(envSet)
+		printEnvsList(envSet, envLayers)
 	case OutputFormatJSON:
-		if err := printJSONEnvs(envSet); err != nil {
+		if err := printJSONEnvs(envSet, envLayers); err != nil {
 			log.Fatalf("Failed to print env list, err: %s", err)
 		}
 	default:
@@ -47,15 +69,25 @@ func printCmd(c *cli.Context) error {
 
 // ReadEnvsJSONList ...
 func ReadEnvsJSONList(envStorePth string, expand, sensitiveOnly bool, envSource env.EnvironmentSource) (models.EnvsJSONListModel, error) {
-	envs, err := ReadEnvs(envStorePth)
+	return ReadLayeredEnvsJSONList([]string{envStorePth}, expand, sensitiveOnly, envSource)
+}
+
+// ReadLayeredEnvsJSONList ...
+func ReadLayeredEnvsJSONList(envStorePths []string, expand, sensitiveOnly bool, envSource env.EnvironmentSource) (models.EnvsJSONListModel, error) {
+	layers, err := ReadEnvstoreLayers(envStorePths)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read envs: %s", err)
 	}
 
-	return ConvertToEnvsJSONModel(envs, expand, sensitiveOnly, envSource)
+	envs, _ := flattenEnvstoreLayers(layers)
+	return convertToEnvsJSONModel(envs, expand, sensitiveOnly, envSource, layersEvaluationOptions(layers, envSource, env.DeclarationOptions{}))
 }
 
 func ConvertToEnvsJSONModel(envs []models.EnvironmentItemModel, expand, sensitiveOnly bool, envSource env.EnvironmentSource) (models.EnvsJSONListModel, error) {
+	return convertToEnvsJSONModel(envs, expand, sensitiveOnly, envSource, env.DeclarationOptions{})
+}
+
+func convertToEnvsJSONModel(envs []models.EnvironmentItemModel, expand, sensitiveOnly bool, envSource env.EnvironmentSource, opts env.DeclarationOptions) (models.EnvsJSONListModel, error) {
 	if sensitiveOnly {
 		var err error
 		envs, err = sensitiveEnvs(envs)
@@ -66,10 +98,11 @@ func ConvertToEnvsJSONModel(envs []models.EnvironmentItemModel, expand, sensitiv
 
 	var resultEnvs map[string]string
 	if expand {
-		result, err := env.GetDeclarationsSideEffects(envs, envSource)
+		result, err := env.GetDeclarationsSideEffectsWithOptions(envs, envSource, opts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to expand envs: %s", err)
 		}
+		logUndefinedReferences(result.UndefinedReferences)
 		resultEnvs = result.EvaluatedNewEnvs
 	} else {
 		resultEnvs = map[string]string{}
@@ -89,20 +122,91 @@ func ConvertToEnvsJSONModel(envs []models.EnvironmentItemModel, expand, sensitiv
 func sensitiveEnvs(envs []models.EnvironmentItemModel) ([]models.EnvironmentItemModel, error) {
 	var filtered []models.EnvironmentItemModel
 	for _, env := range envs {
-		opts, err := env.GetOptions()
+		sensitive, err := isSensitiveEnv(env)
 		if err != nil {
 			return nil, err
 		}
 
-		if opts.IsSensitive != nil && *opts.IsSensitive {
+		if sensitive {
 			filtered = append(filtered, env)
 		}
 	}
 	return filtered, nil
 }
 
-func printJSONEnvs(envList models.EnvsJSONListModel) error {
-	bytes, err := json.Marshal(envList)
+func isSensitiveEnv(env models.EnvironmentItemModel) (bool, error) {
+	opts, err := env.GetOptions()
+	if err != nil {
+		return false, err
+	}
+	return opts.IsSensitive != nil && *opts.IsSensitive, nil
+}
+
+// envLayerPaths returns the path of the layer each effective value comes from,
+// the same envs are taken into account as by ConvertToEnvsJSONModel.
+// The undefined references are not logged, ConvertToEnvsJSONModel has already reported them.
+func envLayerPaths(layers []EnvstoreLayer, expand, sensitiveOnly bool, envSource env.EnvironmentSource, opts env.DeclarationOptions) (map[string]string, error) {
+	allEnvs, allLayerIdxs := flattenEnvstoreLayers(layers)
+
+	var envs []models.EnvironmentItemModel
+	var layerIdxs []int
+	for i, e := range allEnvs {
+		if sensitiveOnly {
+			sensitive, err := isSensitiveEnv(e)
+			if err != nil {
+				return nil, err
+			}
+			if !sensitive {
+				continue
+			}
+		}
+		envs = append(envs, e)
+		layerIdxs = append(layerIdxs, allLayerIdxs[i])
+	}
+
+	envLayers := map[string]string{}
+	if expand {
+		result, err := env.GetDeclarationsSideEffectsWithOptions(envs, envSource, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand envs: %s", err)
+		}
+		for i, command := range result.CommandHistory {
+			switch command.Action {
+			case env.SetAction:
+				envLayers[command.Variable.Key] = layers[layerIdxs[result.DeclarationOrder[i]]].Path
+			case env.UnsetAction:
+				delete(envLayers, command.Variable.Key)
+			}
+		}
+	} else {
+		for i, e := range envs {
+			key, _, err := e.GetKeyValuePair()
+			if err != nil {
+				return nil, err
+			}
+			envLayers[key] = layers[layerIdxs[i]].Path
+		}
+	}
+
+	return envLayers, nil
+}
+
+type layeredEnvJSONModel struct {
+	Value string `json:"value"`
+	Layer string `json:"layer"`
+}
+
+func printJSONEnvs(envList models.EnvsJSONListModel, envLayers map[string]string) error {
+	var out interface{} = envList
+	if envLayers != nil {
+		layeredEnvList := map[string]layeredEnvJSONModel{}
+		for key, value := range envList {
+			layeredEnvList[key] = layeredEnvJSONModel{Value: value, Layer: envLayers[key]}
+		}
+		out = layeredEnvList
+	}
+
+	bytes, err := json.Marshal(out)
 	if err != nil {
 		return err
 	}
@@ -111,17 +215,24 @@ func printJSONEnvs(envList models.EnvsJSONListModel) error {
 	return nil
 }
 
-func printRawEnvs(envList models.EnvsJSONListModel) {
+func printRawEnvs(envList models.EnvsJSONListModel, envLayers map[string]string) {
 	fmt.Println()
 	for key, value := range envList {
-		fmt.Printf("%s: %s\n", key, value)
+		if envLayers != nil {
+			fmt.Printf("%s: %s (layer: %s)\n", key, value, envLayers[key])
+		} else {
+			fmt.Printf("%s: %s\n", key, value)
+		}
 	}
 	fmt.Println()
 }
 
-func printEnvsList(envList models.EnvsJSONListModel) {
+func printEnvsList(envList models.EnvsJSONListModel, envLayers map[string]string) {
 	fmt.Println()
 	for key, value := range envList {
+		if envLayers != nil {
+			fmt.Printf("# layer: %s\n", envLayers[key])
+		}
 		fmt.Printf("export %s=%q\n", key, value)
 	}
 	fmt.Println()
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/bitrise-io/envman/v2/envman"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+func profileName(c *cli.Context) string {
+	if len(c.Args()) != 1 {
+		log.Fatalf("[ENVMAN] - Usage: %s %s PROFILE", c.App.Name, c.Command.FullName())
+	}
+	return c.Args().First()
+}
+
+func profileWorkDir(c *cli.Context) string {
+	if c.Bool(GlobalKey) {
+		return ""
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to get working directory: %s", err)
+	}
+	return workDir
+}
+
+func createProfileCmd(c *cli.Context) error {
+	name := profileName(c)
+	if err := CreateProfile(name, c.Bool(JournalKey)); err != nil {
+		log.Fatalf("[ENVMAN] - Failed to create profile: %s", err)
+	}
+	log.Infof("[ENVMAN] - Profile (%s) created", name)
+
+	if c.Bool(UseKey) {
+		if err := envman.SetActiveProfile(name, profileWorkDir(c)); err != nil {
+			log.Fatalf("[ENVMAN] - Failed to use profile: %s", err)
+		}
+	}
+	return nil
+}
+
+func listProfilesCmd(_ *cli.Context) error {
+	names, err := envman.ListProfiles()
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to list profiles: %s", err)
+	}
+
+	for _, name := range names {
+		marker := " "
+		if name == CurrentProfile {
+			marker = "*"
+		}
+		fmt.Printf("%s %s\n", marker, name)
+	}
+	return nil
+}
+
+func useProfileCmd(c *cli.Context) error {
+	name := ""
+	if !c.Bool(ResetKey) {
+		name = profileName(c)
+	}
+
+	if err := envman.SetActiveProfile(name, profileWorkDir(c)); err != nil {
+		log.Fatalf("[ENVMAN] - Failed to use profile: %s", err)
+	}
+	return nil
+}
+
+func deleteProfileCmd(c *cli.Context) error {
+	name := profileName(c)
+	if err := envman.DeleteProfile(name); err != nil {
+		log.Fatalf("[ENVMAN] - Failed to delete profile: %s", err)
+	}
+	log.Infof("[ENVMAN] - Profile (%s) deleted", name)
+	return nil
+}
+
+func copyProfileCmd(c *cli.Context) error {
+	if len(c.Args()) != 2 {
+		log.Fatalf("[ENVMAN] - Usage: %s %s SOURCE_PROFILE DESTINATION_PROFILE", c.App.Name, c.Command.FullName())
+	}
+
+	source, destination := c.Args().Get(0), c.Args().Get(1)
+	store, err := openEnvstore(envman.GetProfileEnvstorePath(source))
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to copy profile: %s", err)
+	}
+	if err := store.Locked(context.Background(), func() error {
+		return envman.CopyProfile(source, destination)
+	}); err != nil {
+		log.Fatalf("[ENVMAN] - Failed to copy profile: %s", err)
+	}
+	log.Infof("[ENVMAN] - Profile (%s) copied to (%s)", source, destination)
+	return nil
+}
+
+// currentProfileCmd prints the active profile, it is intentionally lightweight, so that it can be used in a shell prompt.
+func currentProfileCmd(_ *cli.Context) error {
+	if CurrentProfile != "" {
+		fmt.Println(CurrentProfile)
+	}
+	return nil
+}
+
+// CreateProfile creates a profile with an empty envstore.
+func CreateProfile(name string, journal bool) error {
+	if exists, err := envman.IsProfileExists(name); err != nil {
+		return err
+	} else if exists {
+		return errors.New("profile already exists: " + name)
+	}
+
+	if err := envman.EnsureProfilesDirExists(); err != nil {
+		return err
+	}
+
+	envstorePth := envman.GetProfileEnvstorePath(name)
+	if journal {
+		return InitJournalEnvStore(envstorePth, false)
+	}
+	return InitEnvStore(envstorePth, false)
+}
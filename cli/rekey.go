@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bitrise-io/envman/v2/encryption"
+	"github.com/bitrise-io/envman/v2/envman"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+func rekey(c *cli.Context) error {
+	ensureWriteLayer()
+
+	log.Debugln("[ENVMAN] - Work path:", CurrentEnvStoreFilePath)
+
+	rotateKey := !c.Bool(KeepKeyKey)
+	recipient, err := RekeyEnvStore(CurrentEnvStoreFilePath, rotateKey, c.StringSlice(AddRecipientKey), c.StringSlice(RemoveRecipientKey))
+	if err != nil {
+		log.Fatal("[ENVMAN] - Failed to rekey EnvStore:", err)
+	}
+
+	log.Info("[ENVMAN] - EnvStore re-encrypted")
+	log.Infof("[ENVMAN] - Your recipient: %s", recipient)
+
+	return nil
+}
+
+// RekeyEnvStore decrypts the envstore's sensitive values and encrypts them again
+// for the (optionally rotated) primary encryption identity and the envstore's recipients.
+// Returns the recipient of the primary encryption identity.
+func RekeyEnvStore(envStorePth string, rotateKey bool, addRecipients, removeRecipients []string) (encryption.Recipient, error) {
+	store, err := openEnvstore(envStorePth)
+	if err != nil {
+		return encryption.Recipient{}, err
+	}
+
+	identities, err := envman.GetEncryptionIdentities()
+	if err != nil {
+		return encryption.Recipient{}, fmt.Errorf("failed to read encryption key: %s", err)
+	}
+
+	if rotateKey || len(identities) == 0 {
+		if envman.IsEncryptionKeyFromEnv() {
+			return encryption.Recipient{}, fmt.Errorf("the encryption key is set by %s, envman can't rotate it", envman.EncryptionKeyEnvKey)
+		}
+
+		newIdentity, err := encryption.GenerateIdentity()
+		if err != nil {
+			return encryption.Recipient{}, err
+		}
+
+		// The previous identities are kept for decryption, the envstore (and other envstores) are still encrypted for them.
+		if len(identities) > 0 {
+			removeRecipients = append(removeRecipients, identities[0].Recipient().String())
+		}
+		identities = append([]encryption.Identity{newIdentity}, identities...)
+		if err := envman.SaveEncryptionIdentities(identities); err != nil {
+			return encryption.Recipient{}, fmt.Errorf("failed to save encryption key: %s", err)
+		}
+	}
+
+	if err := store.Reencrypt(context.Background(), addRecipients, removeRecipients); err != nil {
+		return encryption.Recipient{}, err
+	}
+	return identities[0].Recipient(), nil
+}
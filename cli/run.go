@@ -19,13 +19,18 @@ type CommandModel struct {
 }
 
 func run(c *cli.Context) error {
-	if len(c.Args()) == 0 {
+	args, opts := parseRunFlags(c.Args())
+	if len(args) == 0 {
 		log.Fatal("[ENVMAN] - No command specified")
 	}
+	opts, err := withConfigLimits(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	cmd, err := createCommand(CurrentEnvStoreFilePath, c.Args())
+	cmd, err := createCommand(CurrentEnvStoreFilePaths, args, opts)
 	if err != nil {
-		log.Errorf("command failed: %s", err)
+		log.Fatalf("command failed: %s", err)
 	}
 	cmd.SetStdin(os.Stdin)
 	cmd.SetStdout(os.Stdout)
@@ -41,12 +46,43 @@ func run(c *cli.Context) error {
 	return nil
 }
 
-func createCommand(envStorePth string, args []string) (*command.Model, error) {
+// parseRunFlags splits the flags of the run command from the command to run:
+// flag parsing is disabled for the run command, so the flags of the command are passed through.
+func parseRunFlags(args []string) ([]string, env.DeclarationOptions) {
+	var strict, strictWarn, allowCommands, validate bool
+	options := func() env.DeclarationOptions {
+		opts := strictOptions(strict, strictWarn)
+		opts.ValueSources.AllowCommands = allowCommands
+		opts.Validate = validate
+		return opts
+	}
+
+	for len(args) > 0 {
+		switch args[0] {
+		case "--" + StrictKey:
+			strict = true
+		case "--" + StrictWarnKey:
+			strictWarn = true
+		case "--" + AllowCommandsKey:
+			allowCommands = true
+		case "--" + ValidateKey:
+			validate = true
+		case "--":
+			return args[1:], options()
+		default:
+			return args, options()
+		}
+		args = args[1:]
+	}
+	return args, options()
+}
+
+func createCommand(envStorePths []string, args []string, opts env.DeclarationOptions) (*command.Model, error) {
 	if len(args) == 0 {
 		return nil, fmt.Errorf("no command specified")
 	}
 
-	cmdEnvs, err := ReadAndEvaluateEnvs(envStorePth, &env.DefaultEnvironmentSource{})
+	cmdEnvs, err := readAndEvaluateLayeredEnvs(envStorePths, &env.DefaultEnvironmentSource{}, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load EnvStore: %s", err)
 	}
@@ -27,7 +27,7 @@ func TestCommandEnvs(t *testing.T) {
 
 		envs := []models.EnvironmentItemModel{env1, env2}
 
-		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{})
+		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{}, env.DeclarationOptions{})
 		require.Equal(t, nil, err)
 
 		env1Found := false
@@ -69,7 +69,7 @@ func TestCommandEnvs(t *testing.T) {
 
 		envs := []models.EnvironmentItemModel{env1, env2}
 
-		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{})
+		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{}, env.DeclarationOptions{})
 		require.Equal(t, nil, err)
 
 		env1Found := false
@@ -113,7 +113,7 @@ func TestCommandEnvs(t *testing.T) {
 
 		envs := []models.EnvironmentItemModel{env1, env2}
 
-		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{})
+		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{}, env.DeclarationOptions{})
 		require.Equal(t, nil, err)
 
 		env1Found := false
@@ -157,7 +157,7 @@ func TestCommandEnvs(t *testing.T) {
 
 		envs := []models.EnvironmentItemModel{env1, env2}
 
-		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{})
+		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{}, env.DeclarationOptions{})
 		require.Equal(t, nil, err)
 
 		env1Found := false
@@ -203,7 +203,7 @@ func TestCommandEnvs(t *testing.T) {
 
 		envs := []models.EnvironmentItemModel{env1, env2, env3}
 
-		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{})
+		sessionEnvs, err := evaluateEnvs(envs, &env.DefaultEnvironmentSource{}, env.DeclarationOptions{})
 		require.Equal(t, nil, err)
 
 		env3Found := false
@@ -243,7 +243,7 @@ func TestCommandEnvs(t *testing.T) {
 		}
 
 		// when
-		envs, err := evaluateEnvs(testEnvs, &env.DefaultEnvironmentSource{})
+		envs, err := evaluateEnvs(testEnvs, &env.DefaultEnvironmentSource{}, env.DeclarationOptions{})
 		envFmt := "%s=%s" // note: if this format mismatches elements of `envs`, test can be a false positive!
 		unset := fmt.Sprintf(envFmt, key, val)
 
@@ -253,3 +253,25 @@ func TestCommandEnvs(t *testing.T) {
 
 	}
 }
+
+func TestParseRunFlags(t *testing.T) {
+	args, opts := parseRunFlags([]string{"--strict", "go", "test", "--strict"})
+	require.Equal(t, []string{"go", "test", "--strict"}, args)
+	require.Equal(t, env.DeclarationOptions{Strict: models.StrictError}, opts)
+
+	args, opts = parseRunFlags([]string{"--strict-warn", "--", "--strict"})
+	require.Equal(t, []string{"--strict"}, args)
+	require.Equal(t, env.DeclarationOptions{Strict: models.StrictWarn}, opts)
+
+	args, opts = parseRunFlags([]string{"--allow-commands", "--strict", "env"})
+	require.Equal(t, []string{"env"}, args)
+	require.Equal(t, env.DeclarationOptions{Strict: models.StrictError, ValueSources: env.ValueSourceOptions{AllowCommands: true}}, opts)
+
+	args, opts = parseRunFlags([]string{"--validate", "env"})
+	require.Equal(t, []string{"env"}, args)
+	require.Equal(t, env.DeclarationOptions{Validate: true}, opts)
+
+	args, opts = parseRunFlags([]string{"env"})
+	require.Equal(t, []string{"env"}, args)
+	require.Equal(t, env.DeclarationOptions{}, opts)
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unifiedDiffContext is the number of unchanged lines around the changes in a hunk.
+const unifiedDiffContext = 3
+
+type diffLine struct {
+	// op is ' ' for the unchanged, '-' for the removed and '+' for the added lines
+	op   byte
+	text string
+	// oldLine and newLine are the 0-based line numbers of the line in the old and the new lines
+	oldLine, newLine int
+}
+
+// unifiedDiff returns the unified patch transforming the old lines to the new lines, empty if they are equal.
+func unifiedDiff(oldName, newName string, oldLines, newLines []string) string {
+	script := diffLines(oldLines, newLines)
+
+	var hunks [][]diffLine
+	for i := 0; i < len(script); i++ {
+		if script[i].op == ' ' {
+			continue
+		}
+
+		// the changes separated by at most two contexts of unchanged lines are in the same hunk
+		first, last := i, i
+		for j := i + 1; j < len(script) && j-last-1 <= 2*unifiedDiffContext; j++ {
+			if script[j].op != ' ' {
+				last = j
+			}
+		}
+		hunks = append(hunks, script[max(first-unifiedDiffContext, 0):min(last+unifiedDiffContext+1, len(script))])
+		i = last
+	}
+
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var patch strings.Builder
+	fmt.Fprintf(&patch, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks {
+		oldStart, oldCount, newStart, newCount := hunkRange(hunk)
+		fmt.Fprintf(&patch, "@@ -%s +%s @@\n", formatRange(oldStart, oldCount), formatRange(newStart, newCount))
+		for _, line := range hunk {
+			fmt.Fprintf(&patch, "%c%s\n", line.op, line.text)
+		}
+	}
+	return patch.String()
+}
+
+// diffLines returns the edit script of the longest common subsequence of the lines.
+func diffLines(oldLines, newLines []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var script []diffLine
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			script = append(script, diffLine{op: ' ', text: oldLines[i], oldLine: i, newLine: j})
+			i++
+			j++
+		case j == len(newLines) || (i < len(oldLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, diffLine{op: '-', text: oldLines[i], oldLine: i, newLine: j})
+			i++
+		default:
+			script = append(script, diffLine{op: '+', text: newLines[j], oldLine: i, newLine: j})
+			j++
+		}
+	}
+	return script
+}
+
+// hunkRange returns the 1-based start lines and the line counts of the hunk in the old and the new lines.
+func hunkRange(hunk []diffLine) (int, int, int, int) {
+	oldCount, newCount := 0, 0
+	for _, line := range hunk {
+		if line.op != '+' {
+			oldCount++
+		}
+		if line.op != '-' {
+			newCount++
+		}
+	}
+
+	oldStart, newStart := hunk[0].oldLine+1, hunk[0].newLine+1
+	// an empty range starts at the line before it
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	return oldStart, oldCount, newStart, newCount
+}
+
+func formatRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
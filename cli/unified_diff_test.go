@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	require.Equal(t, "", unifiedDiff("a", "b", []string{"A=1", "B=2"}, []string{"A=1", "B=2"}))
+
+	require.Equal(t, `--- a
++++ b
+@@ -1,2 +1,2 @@
+-A=1
++A=2
+ B=2
+`, unifiedDiff("a", "b", []string{"A=1", "B=2"}, []string{"A=2", "B=2"}))
+
+	require.Equal(t, `--- a
++++ b
+@@ -0,0 +1 @@
++A=1
+`, unifiedDiff("a", "b", nil, []string{"A=1"}))
+
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf("K%d=%d", i, i))
+	}
+	changed := append([]string{}, lines...)
+	changed[1] = "K1=changed"
+	changed[18] = "K18=changed"
+	require.Equal(t, `--- a
++++ b
+@@ -1,5 +1,5 @@
+ K0=0
+-K1=1
++K1=changed
+ K2=2
+ K3=3
+ K4=4
+@@ -16,5 +16,5 @@
+ K15=15
+ K16=16
+ K17=17
+-K18=18
++K18=changed
+ K19=19
+`, unifiedDiff("a", "b", lines, changed))
+}
@@ -1,35 +1,35 @@
 package cli
 
 import (
+	"context"
+
+	"github.com/bitrise-io/envman/v2/envstore"
 	"github.com/bitrise-io/envman/v2/models"
-	"github.com/bitrise-io/go-utils/pointers"
 	"github.com/urfave/cli"
 )
 
 func unset(c *cli.Context) error {
-	key := c.String(KeyKey)
-	// Load envs, or create if not exist
-	environments, err := ReadEnvsOrCreateEmptyList(CurrentEnvStoreFilePath)
+	ensureWriteLayer()
+	return UnsetEnv(CurrentEnvStoreFilePath, c.String(KeyKey))
+}
+
+// UnsetEnv ...
+func UnsetEnv(envStorePth string, key string) error {
+	store, err := openEnvstore(envStorePth)
 	if err != nil {
 		return err
 	}
 
-	// Add or update envlist
-	newEnv := models.EnvironmentItemModel{
-		key: "",
-		models.OptionsKey: models.EnvironmentItemOptionsModel{
-			Unset: pointers.NewBoolPtr(true),
-		},
-	}
-
-	if err := newEnv.NormalizeValidateFillDefaults(); err != nil {
-		return err
-	}
+	return store.Unset(context.Background(), key, envstore.AddOptions{Validate: validateUnsetEnv})
+}
 
-	newEnvSlice, err := UpdateOrAddToEnvlist(environments, newEnv, true)
+// validateUnsetEnv decides whether the unset env replaces the existing envs with the same key (while the envstore is locked).
+func validateUnsetEnv(environments []models.EnvironmentItemModel, key, value string, opts envstore.AddOptions) (string, envstore.AddOptions, error) {
+	replace, err := resolveReplace(environments, key, !opts.Append)
 	if err != nil {
-		return err
+		return "", envstore.AddOptions{}, err
 	}
 
-	return WriteEnvMapToFile(CurrentEnvStoreFilePath, newEnvSlice)
+	opts.Append = !replace
+	return value, opts, nil
 }
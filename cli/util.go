@@ -1,22 +1,34 @@
 package cli
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/envman"
+	"github.com/bitrise-io/envman/v2/envstore"
 	"github.com/bitrise-io/envman/v2/models"
-	"github.com/bitrise-io/go-utils/fileutil"
-	"github.com/bitrise-io/go-utils/pathutil"
 	"github.com/bitrise-io/goinp/goinp"
-	"gopkg.in/yaml.v2"
+	log "github.com/sirupsen/logrus"
 )
 
 var (
-	// CurrentEnvStoreFilePath ...
+	// CurrentEnvStoreFilePath is the envstore (layer) modified by the write commands,
+	// empty if multiple layers are used without selecting one
 	CurrentEnvStoreFilePath string
 
+	// CurrentEnvStoreFilePaths are the envstore layers, evaluated in order by the read commands
+	CurrentEnvStoreFilePaths []string
+
+	// CurrentProfile is the name of the profile, whose envstore is used (if any)
+	CurrentProfile string
+
 	// ToolMode ...
 	ToolMode bool
+
+	// LockTimeout overrides the envstore lock timeout of the envman configs, if set
+	LockTimeout time.Duration
 )
 
 // -------------------
@@ -29,165 +41,53 @@ func UpdateOrAddToEnvlist(oldEnvSlice []models.EnvironmentItemModel, newEnv mode
 		return []models.EnvironmentItemModel{}, err
 	}
 
-	var newEnvs []models.EnvironmentItemModel
-	exist := false
-
-	if replace {
-		match := 0
-		for _, env := range oldEnvSlice {
-			key, _, err := env.GetKeyValuePair()
-			if err != nil {
-				return []models.EnvironmentItemModel{}, err
-			}
+	replace, err = resolveReplace(oldEnvSlice, newKey, replace)
+	if err != nil {
+		return []models.EnvironmentItemModel{}, err
+	}
 
-			if key == newKey {
-				match = match + 1
-			}
-		}
-		if match > 1 {
-			if ToolMode {
-				return []models.EnvironmentItemModel{}, errors.New("More then one env exist with key '" + newKey + "'")
-			}
-			msg := "   More then one env exist with key '" + newKey + "' replace all/append ['replace/append'] ?"
-			answer, err := goinp.AskForString(msg)
-			if err != nil {
-				return []models.EnvironmentItemModel{}, err
-			}
+	return envstore.UpsertEnv(oldEnvSlice, newEnv, replace)
+}
 
-			switch answer {
-			case "replace":
-				break
-			case "append":
-				replace = false
-			default:
-				return []models.EnvironmentItemModel{}, errors.New("Failed to parse answer: '" + answer + "' use ['replace/append']!")
-			}
-		}
+// resolveReplace decides whether a new env should replace the existing envs with the same key:
+// if more than one env exists with the key, the user is asked (unless ToolMode is on).
+func resolveReplace(oldEnvSlice []models.EnvironmentItemModel, newKey string, replace bool) (bool, error) {
+	if !replace {
+		return false, nil
 	}
 
+	match := 0
 	for _, env := range oldEnvSlice {
 		key, _, err := env.GetKeyValuePair()
 		if err != nil {
-			return []models.EnvironmentItemModel{}, err
+			return false, err
 		}
 
-		if replace && key == newKey {
-			exist = true
-			newEnvs = append(newEnvs, newEnv)
-		} else {
-			newEnvs = append(newEnvs, env)
+		if key == newKey {
+			match = match + 1
 		}
 	}
-
-	if !exist {
-		newEnvs = append(newEnvs, newEnv)
+	if match <= 1 {
+		return true, nil
 	}
 
-	return newEnvs, nil
-}
-
-func removeDefaults(env *models.EnvironmentItemModel) error {
-	opts, err := env.GetOptions()
+	if ToolMode {
+		return false, errors.New("More then one env exist with key '" + newKey + "'")
+	}
+	msg := "   More then one env exist with key '" + newKey + "' replace all/append ['replace/append'] ?"
+	answer, err := goinp.AskForString(msg)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if opts.Title != nil && *opts.Title == "" {
-		opts.Title = nil
-	}
-	if opts.Description != nil && *opts.Description == "" {
-		opts.Description = nil
-	}
-	if opts.Category != nil && *opts.Category == "" {
-		opts.Category = nil
-	}
-	if opts.Summary != nil && *opts.Summary == "" {
-		opts.Summary = nil
-	}
-	if opts.IsRequired != nil && *opts.IsRequired == models.DefaultIsRequired {
-		opts.IsRequired = nil
-	}
-	if opts.IsDontChangeValue != nil && *opts.IsDontChangeValue == models.DefaultIsDontChangeValue {
-		opts.IsDontChangeValue = nil
-	}
-	if opts.IsTemplate != nil && *opts.IsTemplate == models.DefaultIsTemplate {
-		opts.IsTemplate = nil
+	switch answer {
+	case "replace":
+		return true, nil
+	case "append":
+		return false, nil
+	default:
+		return false, errors.New("Failed to parse answer: '" + answer + "' use ['replace/append']!")
 	}
-	if opts.IsExpand != nil && *opts.IsExpand == models.DefaultIsExpand {
-		opts.IsExpand = nil
-	}
-	if opts.IsSensitive != nil && *opts.IsSensitive == models.DefaultIsSensitive {
-		opts.IsSensitive = nil
-	}
-	if opts.SkipIfEmpty != nil && *opts.SkipIfEmpty == models.DefaultSkipIfEmpty {
-		opts.SkipIfEmpty = nil
-	}
-	if opts.Unset != nil && *opts.Unset == models.DefaultUnset {
-		opts.Unset = nil
-	}
-
-	(*env)[models.OptionsKey] = opts
-	return nil
-}
-
-func generateFormattedYMLForEnvModels(envs []models.EnvironmentItemModel) (models.EnvsSerializeModel, error) {
-	envMapSlice := []models.EnvironmentItemModel{}
-	for _, env := range envs {
-		err := removeDefaults(&env)
-		if err != nil {
-			return models.EnvsSerializeModel{}, err
-		}
-
-		hasOptions := false
-		opts, err := env.GetOptions()
-		if err != nil {
-			return models.EnvsSerializeModel{}, err
-		}
-
-		if opts.Title != nil {
-			hasOptions = true
-		}
-		if opts.Description != nil {
-			hasOptions = true
-		}
-		if opts.Summary != nil {
-			hasOptions = true
-		}
-		if len(opts.ValueOptions) > 0 {
-			hasOptions = true
-		}
-		if opts.IsRequired != nil {
-			hasOptions = true
-		}
-		if opts.IsDontChangeValue != nil {
-			hasOptions = true
-		}
-		if opts.IsTemplate != nil {
-			hasOptions = true
-		}
-		if opts.IsExpand != nil {
-			hasOptions = true
-		}
-		if opts.IsSensitive != nil {
-			hasOptions = true
-		}
-		if opts.SkipIfEmpty != nil {
-			hasOptions = true
-		}
-		if opts.Unset != nil {
-			hasOptions = true
-		}
-
-		if !hasOptions {
-			delete(env, models.OptionsKey)
-		}
-
-		envMapSlice = append(envMapSlice, env)
-	}
-
-	return models.EnvsSerializeModel{
-		Envs: envMapSlice,
-	}, nil
 }
 
 // -------------------
@@ -195,63 +95,46 @@ func generateFormattedYMLForEnvModels(envs []models.EnvironmentItemModel) (model
 
 // WriteEnvMapToFile ...
 func WriteEnvMapToFile(pth string, envs []models.EnvironmentItemModel) error {
-	if pth == "" {
-		return errors.New("no path provided")
-	}
-
-	envYML, err := generateFormattedYMLForEnvModels(envs)
-	if err != nil {
-		return err
-	}
-	bytes, err := yaml.Marshal(envYML)
+	store, err := openEnvstore(pth)
 	if err != nil {
 		return err
 	}
-	return fileutil.WriteBytesToFile(pth, bytes)
+	return store.Write(context.Background(), envs)
 }
 
-func initAtPath(pth string) error {
-	if exist, err := pathutil.IsPathExists(pth); err != nil {
-		return err
-	} else if !exist {
-		if err := WriteEnvMapToFile(pth, []models.EnvironmentItemModel{}); err != nil {
-			return err
-		}
-	} else {
-		return errors.New("Path already exist: " + pth)
-	}
-	return nil
+// ParseEnvsYML parses the envstore and resolves its includes (relative to the current working directory).
+func ParseEnvsYML(bytes []byte) ([]models.EnvironmentItemModel, error) {
+	return envstore.ParseYML(bytes, ".")
 }
 
-// ParseEnvsYML ...
-func ParseEnvsYML(bytes []byte) ([]models.EnvironmentItemModel, error) {
-	var envsYML models.EnvsSerializeModel
-	if err := yaml.Unmarshal(bytes, &envsYML); err != nil {
-		return []models.EnvironmentItemModel{}, err
-	}
-	for _, env := range envsYML.Envs {
-		if err := env.NormalizeValidateFillDefaults(); err != nil {
-			return []models.EnvironmentItemModel{}, err
-		}
-	}
-	return envsYML.Envs, nil
+// ParseJournal replays the records of a journal envstore.
+func ParseJournal(content []byte) ([]models.EnvironmentItemModel, error) {
+	return envstore.ParseJournal(content)
 }
 
-// ReadEnvs ...
+// ReadEnvs reads the envs declared by the envstore itself, as they are stored: without the envs of its includes,
+// and without applying its defaults. The envs can be modified and written back with WriteEnvMapToFile,
+// the envstore keeps its includes. Use ReadAndEvaluateEnvs to evaluate the envstore.
 func ReadEnvs(pth string) ([]models.EnvironmentItemModel, error) {
-	bytes, err := fileutil.ReadBytesFromFile(pth)
+	store, err := openEnvstore(pth)
 	if err != nil {
 		return []models.EnvironmentItemModel{}, err
 	}
 
-	return ParseEnvsYML(bytes)
+	envs, err := store.ListOwn(context.Background())
+	if err != nil {
+		return []models.EnvironmentItemModel{}, err
+	}
+	return envs, nil
 }
 
-func evaluateEnvs(newEnvs []models.EnvironmentItemModel, envSource env.EnvironmentSource) ([]string, error) {
-	result, err := env.GetDeclarationsSideEffects(newEnvs, envSource)
+func evaluateEnvs(newEnvs []models.EnvironmentItemModel, envSource env.EnvironmentSource, opts env.DeclarationOptions) ([]string, error) {
+	result, err := env.GetDeclarationsSideEffectsWithOptions(newEnvs, envSource, opts)
 	if err != nil {
 		return nil, err
 	}
+	logUndefinedReferences(result.UndefinedReferences)
+
 	var envs []string
 	for key, value := range result.ResultEnvironment {
 		envs = append(envs, key+"="+value)
@@ -261,11 +144,36 @@ func evaluateEnvs(newEnvs []models.EnvironmentItemModel, envSource env.Environme
 
 // ReadAndEvaluateEnvs ...
 func ReadAndEvaluateEnvs(envStorePth string, envSource env.EnvironmentSource) ([]string, error) {
-	envs, err := ReadEnvs(envStorePth)
+	return ReadAndEvaluateLayeredEnvs([]string{envStorePth}, envSource)
+}
+
+// withConfigLimits sets the env var size limits of the envman configs on the value source options.
+func withConfigLimits(opts env.DeclarationOptions) (env.DeclarationOptions, error) {
+	configs, err := envman.GetConfigs()
 	if err != nil {
-		return nil, err
+		return env.DeclarationOptions{}, err
+	}
+	opts.ValueSources.ValueBytesLimit = configs.EnvBytesLimitInKB * 1024
+	opts.ValueSources.ListBytesLimit = configs.EnvListBytesLimitInKB * 1024
+	return opts, nil
+}
+
+// strictOptions returns the declaration options selected by the strict flags.
+func strictOptions(strict, strictWarn bool) env.DeclarationOptions {
+	switch {
+	case strict:
+		return env.DeclarationOptions{Strict: models.StrictError}
+	case strictWarn:
+		return env.DeclarationOptions{Strict: models.StrictWarn}
+	default:
+		return env.DeclarationOptions{}
+	}
+}
+
+func logUndefinedReferences(references []env.UndefinedReference) {
+	for _, reference := range references {
+		log.Warnf("[ENVMAN] - %s", reference)
 	}
-	return evaluateEnvs(envs, envSource)
 }
 
 // ReadEnvsOrCreateEmptyList ...
@@ -273,10 +181,24 @@ func ReadEnvsOrCreateEmptyList(envStorePth string) ([]models.EnvironmentItemMode
 	envModels, err := ReadEnvs(envStorePth)
 	if err != nil {
 		if err.Error() == "No environment variable list found" {
-			err = initAtPath(envStorePth)
+			err = InitEnvStore(envStorePth, false)
 			return []models.EnvironmentItemModel{}, err
 		}
 		return []models.EnvironmentItemModel{}, err
 	}
 	return envModels, nil
 }
+
+// openEnvstore returns the envstore at pth, configured by the envman configs and the global flags.
+func openEnvstore(pth string) (*envstore.Envstore, error) {
+	configs, err := envman.GetConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := LockTimeout
+	if timeout <= 0 {
+		timeout = time.Duration(configs.LockTimeoutInSecs) * time.Second
+	}
+	return envstore.New(pth, envstore.Options{LockTimeout: timeout, HistoryLimit: configs.HistoryLimit})
+}
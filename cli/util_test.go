@@ -1,10 +1,11 @@
 package cli
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/bitrise-io/envman/v2/models"
-	"github.com/bitrise-io/go-utils/pointers"
 	"github.com/stretchr/testify/require"
 )
 
@@ -65,42 +66,44 @@ func TestUpdateOrAddToEnvlist(t *testing.T) {
 	require.Equal(t, 1, env2Cnt)
 }
 
-func TestRemoveDefaults(t *testing.T) {
-	// Filled env
-	env := models.EnvironmentItemModel{
-		"test_key": "test_value",
-		models.OptionsKey: models.EnvironmentItemOptionsModel{
-			Title:      pointers.NewStringPtr("test_title"),
-			IsTemplate: pointers.NewBoolPtr(!models.DefaultIsTemplate),
-
-			Description:       pointers.NewStringPtr(""),
-			Summary:           pointers.NewStringPtr(""),
-			ValueOptions:      []string{},
-			IsRequired:        pointers.NewBoolPtr(models.DefaultIsRequired),
-			IsDontChangeValue: pointers.NewBoolPtr(models.DefaultIsDontChangeValue),
-			IsExpand:          pointers.NewBoolPtr(models.DefaultIsExpand),
-			IsSensitive:       pointers.NewBoolPtr(models.DefaultIsSensitive),
-			SkipIfEmpty:       pointers.NewBoolPtr(models.DefaultSkipIfEmpty),
-		},
-	}
-
-	require.Equal(t, nil, removeDefaults(&env))
-
-	opts, err := env.GetOptions()
-	require.Equal(t, nil, err)
-
-	require.NotEqual(t, (*string)(nil), opts.Title)
-	require.Equal(t, "test_title", *opts.Title)
-	require.NotEqual(t, (*bool)(nil), opts.IsTemplate)
-	require.Equal(t, !models.DefaultIsTemplate, *opts.IsTemplate)
-
-	require.Equal(t, (*string)(nil), opts.Description)
-	require.Equal(t, (*string)(nil), opts.Summary)
-	require.Equal(t, 0, len(opts.ValueOptions))
-	require.Equal(t, (*bool)(nil), opts.IsRequired)
-	require.Equal(t, (*bool)(nil), opts.IsDontChangeValue)
-	require.Equal(t, (*bool)(nil), opts.IsExpand)
-	require.Equal(t, (*bool)(nil), opts.IsSensitive)
-	require.Equal(t, (*bool)(nil), opts.SkipIfEmpty)
-
+func TestReadEnvs_WriteEnvMapToFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "base.yml"), []byte("envs:\n- BASE: base\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "value.txt"), []byte("own"), 0644))
+	envStorePth := filepath.Join(tmpDir, ".envstore.yml")
+	require.NoError(t, os.WriteFile(envStorePth, []byte(`include:
+- base.yml
+defaults:
+  expansion: bash
+envs:
+- OWN: $BASE
+  opts:
+    value_from:
+      file: value.txt
+`), 0644))
+
+	envs, err := ReadEnvs(envStorePth)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(envs))
+	envs = append(envs, models.EnvironmentItemModel{"ADDED": "added"})
+	require.NoError(t, WriteEnvMapToFile(envStorePth, envs))
+
+	content, err := os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	require.Equal(t, `format_version: 2
+include:
+- base.yml
+defaults:
+  expansion: bash
+envs:
+- OWN: $BASE
+  opts:
+    value_from:
+      file: value.txt
+- ADDED: added
+`, string(content))
+
+	evaluated, err := ReadAndEvaluateEnvs(envStorePth, testEnvSource{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"BASE=base", "OWN=own", "ADDED=added"}, evaluated)
 }
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bitrise-io/envman/v2/env"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+type violationJSONModel struct {
+	Key        string `json:"key"`
+	Index      int    `json:"index"`
+	Layer      string `json:"layer"`
+	Constraint string `json:"constraint"`
+	Reason     string `json:"reason"`
+}
+
+// validationJSONModel is the result of the validate command.
+type validationJSONModel struct {
+	Valid      bool                 `json:"valid"`
+	Violations []violationJSONModel `json:"violations"`
+}
+
+func validate(c *cli.Context) error {
+	format := c.String(FormatKey)
+	if format == "" {
+		format = OutputFormatRaw
+	} else if format != OutputFormatRaw && format != OutputFormatJSON {
+		log.Fatalf("[ENVMAN] - Invalid format: %s", format)
+	}
+
+	layers, err := ReadEnvstoreLayers(CurrentEnvStoreFilePaths)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts, err := withConfigLimits(env.DeclarationOptions{ValueSources: env.ValueSourceOptions{AllowCommands: c.Bool(AllowCommandsKey)}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	validation, err := validateEnvs(layers, &env.DefaultEnvironmentSource{}, opts)
+	if err != nil {
+		log.Fatalf("[ENVMAN] - Failed to validate envstore: %s", err)
+	}
+
+	if format == OutputFormatJSON {
+		bytes, err := json.Marshal(validation)
+		if err != nil {
+			log.Fatalf("[ENVMAN] - Failed to print validation result: %s", err)
+		}
+		fmt.Println(string(bytes))
+	} else {
+		printValidation(os.Stdout, validation)
+	}
+
+	if !validation.Valid {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// validateEnvs evaluates the envstore layers, and returns the violations of the declared constraints,
+// the types and the value constraint rules.
+func validateEnvs(layers []EnvstoreLayer, envSource env.EnvironmentSource, opts env.DeclarationOptions) (validationJSONModel, error) {
+	envs, layerIdxs := flattenEnvstoreLayers(layers)
+	opts = layersEvaluationOptions(layers, envSource, opts)
+	opts.Validate = true
+
+	var violations []env.ConstraintViolation
+	_, err := env.GetDeclarationsSideEffectsWithOptions(envs, envSource, opts)
+	var violationsErr env.ConstraintViolationsError
+	if errors.As(err, &violationsErr) {
+		violations = violationsErr.Violations
+	} else if err != nil {
+		return validationJSONModel{}, err
+	}
+
+	validation := validationJSONModel{Valid: len(violations) == 0, Violations: []violationJSONModel{}}
+	for _, violation := range violations {
+		validation.Violations = append(validation.Violations, violationJSONModel{
+			Key:        violation.Key,
+			Index:      violation.Index,
+			Layer:      layers[layerIdxs[violation.Index]].Path,
+			Constraint: violation.Constraint,
+			Reason:     violation.Reason,
+		})
+	}
+	return validation, nil
+}
+
+func printValidation(w io.Writer, validation validationJSONModel) {
+	if validation.Valid {
+		fmt.Fprintln(w, "No constraint violations")
+		return
+	}
+
+	fmt.Fprintf(w, "%d constraint violation(s):\n", len(validation.Violations))
+	for _, violation := range validation.Violations {
+		fmt.Fprintf(w, "- %s: %s: %s (index %d, layer: %s)\n", violation.Key, violation.Constraint, violation.Reason, violation.Index, violation.Layer)
+	}
+}
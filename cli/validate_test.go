@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEnvs(t *testing.T) {
+	tmpDir := t.TempDir()
+	basePth := filepath.Join(tmpDir, "base.yml")
+	jobPth := filepath.Join(tmpDir, "job.yml")
+
+	require.NoError(t, os.WriteFile(basePth, []byte(`envs:
+- API_TOKEN: $TOKEN
+  opts:
+    is_required: true
+- CONFIGURATION: release
+  opts:
+    value_options:
+    - debug
+    - release
+- PLATFORM: ios
+  opts:
+    is_dont_change_value: true
+`), 0644))
+	require.NoError(t, os.WriteFile(jobPth, []byte(`envs:
+- CONFIGURATION: Release
+- PLATFORM: android
+`), 0644))
+
+	layers, err := ReadEnvstoreLayers([]string{basePth, jobPth})
+	require.NoError(t, err)
+
+	validation, err := validateEnvs(layers, testEnvSource{}, env.DeclarationOptions{})
+	require.NoError(t, err)
+	require.False(t, validation.Valid)
+
+	var out bytes.Buffer
+	printValidation(&out, validation)
+	require.Equal(t, `3 constraint violation(s):
+- API_TOKEN: is_required: value is empty (index 0, layer: `+basePth+`)
+- CONFIGURATION: value_options: value is not one of: debug, release (index 3, layer: `+jobPth+`)
+- PLATFORM: is_dont_change_value: changes the value declared at index 2 (index 4, layer: `+jobPth+`)
+`, out.String())
+
+	validationJSON, err := json.Marshal(validation)
+	require.NoError(t, err)
+	require.Contains(t, string(validationJSON), `{"key":"API_TOKEN","index":0,"layer":"`+basePth+`","constraint":"is_required","reason":"value is empty"}`)
+
+	validation, err = validateEnvs(layers[:1], testEnvSource{"TOKEN": "secret"}, env.DeclarationOptions{})
+	require.NoError(t, err)
+	require.True(t, validation.Valid)
+
+	out.Reset()
+	printValidation(&out, validation)
+	require.Equal(t, "No constraint violations\n", out.String())
+}
+
+func TestValidateEnvs_ValueRules(t *testing.T) {
+	envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+	require.NoError(t, os.WriteFile(envStorePth, []byte(`envs:
+- PORT: http
+  opts:
+    type: int
+- VERSION: 1.2
+  opts:
+    pattern: ^v\d+
+- RETRIES: "12"
+  opts:
+    max: 5
+- CONFIGURATION: Release
+  opts:
+    value_options:
+    - debug
+    - release
+`), 0644))
+
+	layers, err := ReadEnvstoreLayers([]string{envStorePth})
+	require.NoError(t, err)
+
+	validation, err := validateEnvs(layers, testEnvSource{}, env.DeclarationOptions{})
+	require.NoError(t, err)
+	require.False(t, validation.Valid)
+
+	var out bytes.Buffer
+	printValidation(&out, validation)
+	require.Equal(t, `4 constraint violation(s):
+- PORT: type: value is not an integer (index 0, layer: `+envStorePth+`)
+- VERSION: pattern: value doesn't match ^v\d+ (index 1, layer: `+envStorePth+`)
+- RETRIES: max: value is greater than 5 (index 2, layer: `+envStorePth+`)
+- CONFIGURATION: value_options: value is not one of: debug, release (index 3, layer: `+envStorePth+`)
+`, out.String())
+}
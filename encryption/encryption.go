@@ -0,0 +1,307 @@
+// Package encryption encrypts env values for one or more recipients.
+//
+// Every value is encrypted with a random data key (AES-256-GCM), the data key is then wrapped
+// for each recipient with a key derived (HKDF-SHA256) from an X25519 key exchange between
+// an ephemeral key and the recipient's public key.
+// Any identity (private key) matching one of the recipients can decrypt the value.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	identityPrefix       = "ENVMAN-IDENTITY-"
+	recipientPrefix      = "envman-recipient-"
+	encryptedValuePrefix = "envman:encrypted:v1:"
+
+	wrapKeyInfo = "envman encryption v1"
+)
+
+// ErrNoMatchingIdentity is returned by Decrypt if none of the identities is a recipient of the value.
+var ErrNoMatchingIdentity = errors.New("no matching identity found for the encrypted value")
+
+var encoding = base64.RawURLEncoding
+
+// Identity is a private key, used to decrypt values.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// Recipient is a public key, values are encrypted for recipients.
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// GenerateIdentity creates a new random identity.
+func GenerateIdentity() (Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{key: key}, nil
+}
+
+// ParseIdentity parses an identity, encoded by Identity.String.
+func ParseIdentity(encoded string) (Identity, error) {
+	encoded = strings.TrimSpace(encoded)
+	if !strings.HasPrefix(encoded, identityPrefix) {
+		return Identity{}, fmt.Errorf("invalid identity: missing %s prefix", identityPrefix)
+	}
+	keyBytes, err := encoding.DecodeString(strings.TrimPrefix(encoded, identityPrefix))
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid identity: %s", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(keyBytes)
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid identity: %s", err)
+	}
+	return Identity{key: key}, nil
+}
+
+// ParseIdentities parses identities listed one per line, empty lines and # comments are ignored.
+func ParseIdentities(content string) ([]Identity, error) {
+	var identities []Identity
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
+
+func (i Identity) String() string {
+	return identityPrefix + encoding.EncodeToString(i.key.Bytes())
+}
+
+// Recipient returns the public key belonging to the identity.
+func (i Identity) Recipient() Recipient {
+	return Recipient{key: i.key.PublicKey()}
+}
+
+// ParseRecipient parses a recipient, encoded by Recipient.String.
+func ParseRecipient(encoded string) (Recipient, error) {
+	encoded = strings.TrimSpace(encoded)
+	if !strings.HasPrefix(encoded, recipientPrefix) {
+		return Recipient{}, fmt.Errorf("invalid recipient: missing %s prefix", recipientPrefix)
+	}
+	keyBytes, err := encoding.DecodeString(strings.TrimPrefix(encoded, recipientPrefix))
+	if err != nil {
+		return Recipient{}, fmt.Errorf("invalid recipient: %s", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(keyBytes)
+	if err != nil {
+		return Recipient{}, fmt.Errorf("invalid recipient: %s", err)
+	}
+	return Recipient{key: key}, nil
+}
+
+func (r Recipient) String() string {
+	return recipientPrefix + encoding.EncodeToString(r.key.Bytes())
+}
+
+func (r Recipient) id() string {
+	sum := sha256.Sum256(r.key.Bytes())
+	return hex.EncodeToString(sum[:8])
+}
+
+type wrappedKey struct {
+	RecipientID  string `json:"recipient_id"`
+	EphemeralKey string `json:"ephemeral_key"`
+	WrappedKey   string `json:"wrapped_key"`
+}
+
+type encryptedValue struct {
+	Recipients []wrappedKey `json:"recipients"`
+	Nonce      string       `json:"nonce"`
+	Ciphertext string       `json:"ciphertext"`
+}
+
+// IsEncrypted returns true if the value was created by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedValuePrefix)
+}
+
+// Encrypt encrypts the value, so that any of the recipients can decrypt it.
+func Encrypt(plaintext string, recipients []Recipient) (string, error) {
+	if len(recipients) == 0 {
+		return "", errors.New("no recipients specified")
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	value := encryptedValue{
+		Nonce:      encoding.EncodeToString(nonce),
+		Ciphertext: encoding.EncodeToString(aead.Seal(nil, nonce, []byte(plaintext), nil)),
+	}
+
+	for _, recipient := range recipients {
+		wrapped, err := wrapKey(dataKey, recipient)
+		if err != nil {
+			return "", err
+		}
+		value.Recipients = append(value.Recipients, wrapped)
+	}
+
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return encryptedValuePrefix + encoding.EncodeToString(valueBytes), nil
+}
+
+// Decrypt decrypts a value created by Encrypt, with the first identity which is a recipient of the value.
+func Decrypt(encrypted string, identities []Identity) (string, error) {
+	if !IsEncrypted(encrypted) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	valueBytes, err := encoding.DecodeString(strings.TrimPrefix(encrypted, encryptedValuePrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %s", err)
+	}
+	var value encryptedValue
+	if err := json.Unmarshal(valueBytes, &value); err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %s", err)
+	}
+
+	for _, identity := range identities {
+		recipientID := identity.Recipient().id()
+		for _, wrapped := range value.Recipients {
+			if wrapped.RecipientID != recipientID {
+				continue
+			}
+
+			dataKey, err := unwrapKey(wrapped, identity)
+			if err != nil {
+				return "", err
+			}
+			return decryptWithDataKey(value, dataKey)
+		}
+	}
+
+	return "", ErrNoMatchingIdentity
+}
+
+func decryptWithDataKey(value encryptedValue, dataKey []byte) (string, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := encoding.DecodeString(value.Nonce)
+	if err != nil {
+		return "", fmt.Errorf("invalid nonce: %s", err)
+	}
+	ciphertext, err := encoding.DecodeString(value.Ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %s", err)
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %s", err)
+	}
+	return string(plaintext), nil
+}
+
+func wrapKey(dataKey []byte, recipient Recipient) (wrappedKey, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return wrappedKey{}, err
+	}
+
+	shared, err := ephemeral.ECDH(recipient.key)
+	if err != nil {
+		return wrappedKey{}, err
+	}
+	aead, err := newWrapAEAD(shared, ephemeral.PublicKey(), recipient.key)
+	if err != nil {
+		return wrappedKey{}, err
+	}
+
+	// every wrap key is used only once, so a zero nonce is safe
+	nonce := make([]byte, aead.NonceSize())
+	return wrappedKey{
+		RecipientID:  recipient.id(),
+		EphemeralKey: encoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		WrappedKey:   encoding.EncodeToString(aead.Seal(nil, nonce, dataKey, nil)),
+	}, nil
+}
+
+func unwrapKey(wrapped wrappedKey, identity Identity) ([]byte, error) {
+	ephemeralBytes, err := encoding.DecodeString(wrapped.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %s", err)
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %s", err)
+	}
+	wrappedBytes, err := encoding.DecodeString(wrapped.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %s", err)
+	}
+
+	shared, err := identity.key.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newWrapAEAD(shared, ephemeral, identity.key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	dataKey, err := aead.Open(nil, nonce, wrappedBytes, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %s", err)
+	}
+	return dataKey, nil
+}
+
+// newWrapAEAD derives the key wrapping cipher from the X25519 shared secret.
+func newWrapAEAD(shared []byte, ephemeral, recipient *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(ephemeral.Bytes(), recipient.Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, salt, wrapKeyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
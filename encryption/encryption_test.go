@@ -0,0 +1,62 @@
+package encryption
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	alice, err := GenerateIdentity()
+	require.NoError(t, err)
+	bob, err := GenerateIdentity()
+	require.NoError(t, err)
+	eve, err := GenerateIdentity()
+	require.NoError(t, err)
+
+	encrypted, err := Encrypt("top secret", []Recipient{alice.Recipient(), bob.Recipient()})
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(encrypted))
+	require.NotContains(t, encrypted, "top secret")
+
+	for _, identity := range []Identity{alice, bob} {
+		decrypted, err := Decrypt(encrypted, []Identity{eve, identity})
+		require.NoError(t, err)
+		require.Equal(t, "top secret", decrypted)
+	}
+
+	_, err = Decrypt(encrypted, []Identity{eve})
+	require.Equal(t, ErrNoMatchingIdentity, err)
+
+	// every encryption uses a new data key and nonce
+	encryptedAgain, err := Encrypt("top secret", []Recipient{alice.Recipient()})
+	require.NoError(t, err)
+	require.NotEqual(t, encrypted, encryptedAgain)
+
+	_, err = Encrypt("top secret", nil)
+	require.EqualError(t, err, "no recipients specified")
+}
+
+func TestParseIdentityAndRecipient(t *testing.T) {
+	identity, err := GenerateIdentity()
+	require.NoError(t, err)
+
+	parsedIdentity, err := ParseIdentity(identity.String())
+	require.NoError(t, err)
+	require.Equal(t, identity.String(), parsedIdentity.String())
+
+	parsedRecipient, err := ParseRecipient(identity.Recipient().String())
+	require.NoError(t, err)
+	require.Equal(t, identity.Recipient().String(), parsedRecipient.String())
+
+	_, err = ParseIdentity(identity.Recipient().String())
+	require.EqualError(t, err, "invalid identity: missing ENVMAN-IDENTITY- prefix")
+
+	identities, err := ParseIdentities("# my key\n" + identity.String() + "\n\n")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(identities))
+
+	_, err = ParseIdentities("ENVMAN-IDENTITY-!!")
+	require.True(t, strings.HasPrefix(err.Error(), "line 1: invalid identity"))
+}
@@ -0,0 +1,494 @@
+package env
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// expandBash expands the references of the value like bash does, with the parameter expansion operators:
+//   - ${VAR:-word}, ${VAR-word}: word if VAR is unset or empty (only unset without the colon)
+//   - ${VAR:=word}, ${VAR=word}: like :- and -, but VAR is also assigned for the rest of the value
+//   - ${VAR:+word}, ${VAR+word}: word if VAR is set and not empty (only set without the colon)
+//   - ${VAR:?word}, ${VAR?word}: error with the message word if VAR is unset or empty (only unset without the colon)
+//   - ${VAR#pattern}, ${VAR##pattern}: removes the shortest (longest) prefix matching the pattern
+//   - ${VAR%pattern}, ${VAR%%pattern}: removes the shortest (longest) suffix matching the pattern
+//   - ${VAR/pattern/repl}, ${VAR//pattern/repl}: replaces the first (every) match of the pattern,
+//     ${VAR/#pattern/repl} and ${VAR/%pattern/repl} anchor the match to the start (end) of the value
+//   - ${VAR:offset}, ${VAR:offset:length}: substring, negative values count from the end
+//   - ${#VAR}: length of the value
+//
+// Patterns support *, ? and [...], like bash patterns. The assignment of := is not a declaration:
+// the assigned value is only visible within the expanded value.
+// Command substitution and arithmetic expansion are not supported, $( is kept as is.
+// Unlike with the simple expansion, $$ is an escaped (literal) dollar sign.
+// The names of the referenced undefined env vars are returned too, except the ones the operator handles
+// (the default, alternative and error operators).
+func expandBash(value string, envs map[string]string) (string, []string, error) {
+	e := bashExpander{envs: envs}
+	expanded, err := e.expand(value)
+	if err != nil {
+		return "", nil, err
+	}
+	return expanded, e.undefined, nil
+}
+
+type bashExpander struct {
+	envs      map[string]string
+	assigned  map[string]string
+	undefined []string
+}
+
+func (e *bashExpander) lookup(name string) (string, bool) {
+	if value, ok := e.assigned[name]; ok {
+		return value, true
+	}
+	value, ok := e.envs[name]
+	return value, ok
+}
+
+// reference returns the value of the referenced env var, and records it if it's undefined.
+func (e *bashExpander) reference(name string) string {
+	value, ok := e.lookup(name)
+	if !ok {
+		e.undefined = appendUnique(e.undefined, name)
+	}
+	return value
+}
+
+func (e *bashExpander) assign(name, value string) {
+	if e.assigned == nil {
+		e.assigned = map[string]string{}
+	}
+	e.assigned[name] = value
+}
+
+func (e *bashExpander) expand(s string) (string, error) {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+
+		if s[i+1] == '$' {
+			buf.WriteByte('$')
+			i++
+			continue
+		}
+
+		if s[i+1] == '{' {
+			end, err := closingBrace(s, i+2)
+			if err != nil {
+				return "", err
+			}
+			expanded, err := e.expandParameter(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(expanded)
+			i = end
+			continue
+		}
+
+		name := shellName(s[i+1:])
+		if name == "" {
+			// not a reference, like os.Expand the dollar is kept
+			buf.WriteByte(s[i])
+			continue
+		}
+		buf.WriteString(e.reference(name))
+		i += len(name)
+	}
+	return buf.String(), nil
+}
+
+// closingBrace returns the index of the brace closing the reference starting at start, nested references are skipped.
+func closingBrace(s string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '$':
+			i++
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		}
+	}
+	return 0, fmt.Errorf("bad substitution: missing closing brace: %s", s[start-2:])
+}
+
+// shellName returns the name at the start of s: a special parameter, a positional parameter or a variable name.
+func shellName(s string) string {
+	if s == "" {
+		return ""
+	}
+	if strings.IndexByte("*#$@!?-", s[0]) >= 0 || isDigit(s[0]) {
+		return s[:1]
+	}
+	i := 0
+	for i < len(s) && (s[i] == '_' || isDigit(s[i]) || isAlpha(s[i])) {
+		i++
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func (e *bashExpander) expandParameter(parameter string) (string, error) {
+	if len(parameter) > 1 && parameter[0] == '#' {
+		name := shellName(parameter[1:])
+		if name == "" || len(name)+1 != len(parameter) {
+			return "", fmt.Errorf("bad substitution: ${%s}", parameter)
+		}
+		value := e.reference(name)
+		return strconv.Itoa(utf8.RuneCountInString(value)), nil
+	}
+
+	name := shellName(parameter)
+	if name == "" {
+		return "", fmt.Errorf("bad substitution: ${%s}", parameter)
+	}
+	value, isSet := e.lookup(name)
+	operation := parameter[len(name):]
+	if !isSet && !handlesUnset(operation) {
+		e.undefined = appendUnique(e.undefined, name)
+	}
+	if operation == "" {
+		return value, nil
+	}
+
+	switch {
+	case strings.HasPrefix(operation, ":-"), strings.HasPrefix(operation, "-"):
+		if useWord(operation, value, isSet) {
+			return e.expand(operationWord(operation))
+		}
+		return value, nil
+	case strings.HasPrefix(operation, ":="), strings.HasPrefix(operation, "="):
+		if useWord(operation, value, isSet) {
+			word, err := e.expand(operationWord(operation))
+			if err != nil {
+				return "", err
+			}
+			e.assign(name, word)
+			return word, nil
+		}
+		return value, nil
+	case strings.HasPrefix(operation, ":+"), strings.HasPrefix(operation, "+"):
+		if useWord(operation, value, isSet) {
+			return "", nil
+		}
+		return e.expand(operationWord(operation))
+	case strings.HasPrefix(operation, ":?"), strings.HasPrefix(operation, "?"):
+		if useWord(operation, value, isSet) {
+			message, err := e.expand(operationWord(operation))
+			if err != nil {
+				return "", err
+			}
+			if message == "" {
+				message = "parameter null or not set"
+			}
+			return "", fmt.Errorf("%s: %s", name, message)
+		}
+		return value, nil
+	case strings.HasPrefix(operation, ":"):
+		return substring(value, operation[1:])
+	case strings.HasPrefix(operation, "#"), strings.HasPrefix(operation, "%"):
+		return e.removeAffix(value, operation)
+	case strings.HasPrefix(operation, "/"):
+		return e.replace(value, operation[1:])
+	default:
+		return "", fmt.Errorf("bad substitution: ${%s}", parameter)
+	}
+}
+
+// handlesUnset reports whether the operation has a result for an unset env var, instead of its value:
+// the default, assignment, alternative and error operators.
+func handlesUnset(operation string) bool {
+	operator := strings.TrimPrefix(operation, ":")
+	return operator != "" && strings.IndexByte("-=+?", operator[0]) >= 0
+}
+
+// useWord reports whether the word of the :-, :=, :+ or :? (and the colonless) operation applies:
+// the colon variants treat an empty value as unset.
+func useWord(operation, value string, isSet bool) bool {
+	if strings.HasPrefix(operation, ":") {
+		return value == ""
+	}
+	return !isSet
+}
+
+func operationWord(operation string) string {
+	if strings.HasPrefix(operation, ":") {
+		return operation[2:]
+	}
+	return operation[1:]
+}
+
+func substring(value, spec string) (string, error) {
+	offsetSpec, lengthSpec, hasLength := strings.Cut(spec, ":")
+
+	runes := []rune(value)
+	offset, err := substringInt(offsetSpec)
+	if err != nil {
+		return "", err
+	}
+	if offset < 0 {
+		offset += len(runes)
+		if offset < 0 {
+			// like bash, an offset before the start of the value results in an empty string
+			return "", nil
+		}
+	}
+	if offset > len(runes) {
+		return "", nil
+	}
+
+	end := len(runes)
+	if hasLength {
+		length, err := substringInt(lengthSpec)
+		if err != nil {
+			return "", err
+		}
+		if length < 0 {
+			end = len(runes) + length
+			if end < offset {
+				return "", fmt.Errorf("%s: substring expression < 0", lengthSpec)
+			}
+		} else if offset+length < end {
+			end = offset + length
+		}
+	}
+	return string(runes[offset:end]), nil
+}
+
+// substringInt parses an offset or length of a substring expansion, an integer optionally wrapped in parentheses.
+func substringInt(spec string) (int, error) {
+	trimmed := strings.TrimSpace(spec)
+	if strings.HasPrefix(trimmed, "(") && strings.HasSuffix(trimmed, ")") {
+		trimmed = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+	}
+	if trimmed == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid substring offset or length (%s), only integers are supported", spec)
+	}
+	return i, nil
+}
+
+func (e *bashExpander) removeAffix(value, operation string) (string, error) {
+	prefix := operation[0] == '#'
+	longest := len(operation) > 1 && operation[1] == operation[0]
+	pattern := operation[1:]
+	if longest {
+		pattern = operation[2:]
+	}
+
+	re, err := e.compilePattern(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	// the candidate prefixes (suffixes) are tried from the shortest to the longest, or in reverse
+	for n := 0; n <= len(value); n++ {
+		length := n
+		if longest {
+			length = len(value) - n
+		}
+		if prefix {
+			if utf8.RuneStart(valueByte(value, length)) && re.MatchString(value[:length]) {
+				return value[length:], nil
+			}
+		} else {
+			start := len(value) - length
+			if utf8.RuneStart(valueByte(value, start)) && re.MatchString(value[start:]) {
+				return value[:start], nil
+			}
+		}
+	}
+	return value, nil
+}
+
+// valueByte returns the byte of the value at i, for checking rune boundaries, the end of the value is a boundary.
+func valueByte(value string, i int) byte {
+	if i >= len(value) {
+		return 0
+	}
+	return value[i]
+}
+
+func (e *bashExpander) replace(value, spec string) (string, error) {
+	all, anchorStart, anchorEnd := false, false, false
+	switch {
+	case strings.HasPrefix(spec, "/"):
+		all, spec = true, spec[1:]
+	case strings.HasPrefix(spec, "#"):
+		anchorStart, spec = true, spec[1:]
+	case strings.HasPrefix(spec, "%"):
+		anchorEnd, spec = true, spec[1:]
+	}
+
+	pattern, replacement := splitUnescaped(spec, '/')
+	re, err := e.compilePattern(pattern)
+	if err != nil {
+		return "", err
+	}
+	if replacement, err = e.expand(replacement); err != nil {
+		return "", err
+	}
+	if pattern == "" {
+		switch {
+		case anchorStart:
+			return replacement + value, nil
+		case anchorEnd:
+			return value + replacement, nil
+		default:
+			return value, nil
+		}
+	}
+
+	var buf strings.Builder
+	i := 0
+	for i < len(value) {
+		if anchorStart && i > 0 {
+			break
+		}
+
+		if end := longestMatch(re, value, i, anchorEnd); end > i {
+			buf.WriteString(replacement)
+			i = end
+			if !all {
+				break
+			}
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(value[i:])
+		buf.WriteString(value[i : i+size])
+		i += size
+	}
+	buf.WriteString(value[i:])
+	return buf.String(), nil
+}
+
+// longestMatch returns the end of the longest match of the pattern starting at start, or -1 if there is no match.
+func longestMatch(re *regexp.Regexp, value string, start int, anchorEnd bool) int {
+	for end := len(value); end >= start; end-- {
+		if anchorEnd && end != len(value) {
+			break
+		}
+		if utf8.RuneStart(valueByte(value, end)) && re.MatchString(value[start:end]) {
+			return end
+		}
+	}
+	return -1
+}
+
+// splitUnescaped splits s at the first unescaped sep, the second part is empty if s doesn't contain sep.
+func splitUnescaped(s string, sep byte) (string, string) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// compilePattern expands the references of the bash pattern and converts it to an anchored regexp.
+func (e *bashExpander) compilePattern(pattern string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString(`^(?s:`)
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			buf.WriteString(`.*`)
+		case '?':
+			buf.WriteString(`.`)
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			} else {
+				buf.WriteString(`\\`)
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				buf.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '$':
+			reference, length, err := e.patternReference(pattern[i:])
+			if err != nil {
+				return nil, err
+			}
+			buf.WriteString(regexp.QuoteMeta(reference))
+			i += length - 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	buf.WriteString(`)$`)
+
+	re, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern (%s): %s", pattern, err)
+	}
+	return re, nil
+}
+
+// patternReference expands the reference at the start of s (starting with $), and returns its length in s.
+func (e *bashExpander) patternReference(s string) (string, int, error) {
+	if strings.HasPrefix(s, "$$") {
+		return "$", 2, nil
+	}
+	if strings.HasPrefix(s, "${") {
+		end, err := closingBrace(s, 2)
+		if err != nil {
+			return "", 0, err
+		}
+		value, err := e.expandParameter(s[2:end])
+		return value, end + 1, err
+	}
+
+	name := shellName(s[1:])
+	if name == "" {
+		return "$", 1, nil
+	}
+	return e.reference(name), len(name) + 1, nil
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
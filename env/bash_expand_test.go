@@ -0,0 +1,107 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandBash(t *testing.T) {
+	envs := map[string]string{
+		"VERSION":   "v1.2.3",
+		"PTH":       "/usr/local/bin/envman",
+		"EMPTY":     "",
+		"GREETING":  "hello world",
+		"FILE":      "archive.tar.gz",
+		"UNICODE":   "árvíztűrő",
+		"BUILD_DIR": "/bitrise/src",
+	}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "$VERSION ${VERSION}", want: "v1.2.3 v1.2.3"},
+		{value: "$ $( $1", want: "$ $( "},
+		{value: "$$VERSION $$$VERSION $${VERSION}", want: "$VERSION $v1.2.3 ${VERSION}"},
+		{value: "${UNDEFINED:-/tmp/build}", want: "/tmp/build"},
+		{value: "${EMPTY:-default}", want: "default"},
+		{value: "${EMPTY-default}", want: ""},
+		{value: "${UNDEFINED-default}", want: "default"},
+		{value: "${BUILD_DIR:-/tmp/build}", want: "/bitrise/src"},
+		{value: "${UNDEFINED:-$BUILD_DIR/out}", want: "/bitrise/src/out"},
+		{value: "${UNDEFINED:-${EMPTY:-nested}}", want: "nested"},
+		{value: "${UNDEFINED:=assigned} $UNDEFINED", want: "assigned assigned"},
+		{value: "${EMPTY=assigned}", want: ""},
+		{value: "${VERSION:+set}", want: "set"},
+		{value: "${EMPTY:+set}", want: ""},
+		{value: "${EMPTY+set}", want: "set"},
+		{value: "${VERSION:?missing}", want: "v1.2.3"},
+		{value: "${VERSION#v}", want: "1.2.3"},
+		{value: "${PTH#*/}", want: "usr/local/bin/envman"},
+		{value: "${PTH##*/}", want: "envman"},
+		{value: "${FILE%.*}", want: "archive.tar"},
+		{value: "${FILE%%.*}", want: "archive"},
+		{value: "${FILE%.[!g]z}", want: "archive.tar.gz"},
+		{value: "${FILE%.[!t]z}", want: "archive.tar"},
+		{value: "${VERSION#$EMPTY}", want: "v1.2.3"},
+		{value: "${GREETING/o/0}", want: "hell0 world"},
+		{value: "${GREETING//o/0}", want: "hell0 w0rld"},
+		{value: "${GREETING//o}", want: "hell wrld"},
+		{value: "${GREETING/#hello/bye}", want: "bye world"},
+		{value: "${GREETING/#world/bye}", want: "hello world"},
+		{value: "${GREETING/%world/there}", want: "hello there"},
+		{value: "${GREETING/l*o/_}", want: "he_rld"},
+		{value: "${GREETING/\\ /_}", want: "hello_world"},
+		{value: "${GREETING:6}", want: "world"},
+		{value: "${GREETING:0:5}", want: "hello"},
+		{value: "${GREETING: -5}", want: "world"},
+		{value: "${GREETING:(-5):3}", want: "wor"},
+		{value: "${GREETING:1:-1}", want: "ello worl"},
+		{value: "${GREETING:20}", want: ""},
+		{value: "${UNICODE:1:3}", want: "rví"},
+		{value: "${#UNICODE}", want: "9"},
+		{value: "${#UNDEFINED}", want: "0"},
+		{value: "${UNICODE%ő}", want: "árvíztűr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, _, err := expandBash(tt.value, envs)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandBash_Errors(t *testing.T) {
+	envs := map[string]string{"EMPTY": "", "GREETING": "hello"}
+
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{value: "${UNDEFINED:?is required}", wantErr: "UNDEFINED: is required"},
+		{value: "${EMPTY:?}", wantErr: "EMPTY: parameter null or not set"},
+		{value: "${UNDEFINED?}", wantErr: "UNDEFINED: parameter null or not set"},
+		{value: "${GREETING", wantErr: "bad substitution: missing closing brace: ${GREETING"},
+		{value: "${}", wantErr: "bad substitution: ${}"},
+		{value: "${GREETING^^}", wantErr: "bad substitution: ${GREETING^^}"},
+		{value: "${#GREETING:1}", wantErr: "bad substitution: ${#GREETING:1}"},
+		{value: "${GREETING:a}", wantErr: "invalid substring offset or length (a), only integers are supported"},
+		{value: "${GREETING:3:-4}", wantErr: "-4: substring expression < 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, _, err := expandBash(tt.value, envs)
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestExpandBash_UndefinedReferences(t *testing.T) {
+	envs := map[string]string{"EMPTY": "", "GREETING": "hello"}
+
+	_, undefined, err := expandBash("$GREETING $EMPTY $$ESCAPED $TYPO ${TYPO} ${#LENGTH} ${GREETING#$PREFIX} ${DEFAULT:-x} ${ALT:+x} ${ASSIGN:=x}", envs)
+	require.NoError(t, err)
+	require.Equal(t, []string{"TYPO", "LENGTH", "PREFIX"}, undefined)
+}
@@ -0,0 +1,237 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+const (
+	// ConstraintRequired is violated if the final value of a required env var is empty (or unset)
+	ConstraintRequired = "is_required"
+	// ConstraintValueOptions is violated if a (non-empty) value is not one of the value_options declared by the declaration,
+	// or by a preceding declaration of the env var
+	ConstraintValueOptions = "value_options"
+	// ConstraintDontChangeValue is violated if a later declaration changes the value declared with is_dont_change_value
+	ConstraintDontChangeValue = "is_dont_change_value"
+	// ConstraintType is violated if a value doesn't match the type of the env var
+	ConstraintType = "type"
+)
+
+const (
+	// RulePattern is violated if the value doesn't match the pattern (regular expression)
+	RulePattern = "pattern"
+	// RuleMinLength is violated if the value is shorter than min_length
+	RuleMinLength = "min_length"
+	// RuleMaxLength is violated if the value is longer than max_length
+	RuleMaxLength = "max_length"
+	// RuleMin is violated if the value is not a number, or it's less than min
+	RuleMin = "min"
+	// RuleMax is violated if the value is not a number, or it's greater than max
+	RuleMax = "max"
+)
+
+// EnvVarRuleError is returned if a value violates a value constraint rule (like pattern) of the env var.
+type EnvVarRuleError struct {
+	Key    string
+	Rule   string
+	Reason string
+}
+
+// NewEnvVarRuleError ...
+func NewEnvVarRuleError(key, rule, reason string) error {
+	return EnvVarRuleError{
+		Key:    key,
+		Rule:   rule,
+		Reason: reason,
+	}
+}
+
+func (err EnvVarRuleError) Error() string {
+	return fmt.Sprintf("env var (%s) value violates %s: %s", err.Key, err.Rule, err.Reason)
+}
+
+// CheckValueRules checks the value of the env var (key) against the value constraint rules of the options
+// (pattern, min_length, max_length, min and max), and returns an EnvVarRuleError for the first violated rule.
+// Empty values are only checked by min_length (use is_required for the env vars which must be set).
+func CheckValueRules(key, value string, options models.EnvironmentItemOptionsModel) error {
+	length := utf8.RuneCountInString(value)
+	if options.MinLength != nil && length < *options.MinLength {
+		return NewEnvVarRuleError(key, RuleMinLength, fmt.Sprintf("length (%d) is less than %d", length, *options.MinLength))
+	}
+	if value == "" {
+		return nil
+	}
+	if options.MaxLength != nil && length > *options.MaxLength {
+		return NewEnvVarRuleError(key, RuleMaxLength, fmt.Sprintf("length (%d) is greater than %d", length, *options.MaxLength))
+	}
+
+	if options.Pattern != nil {
+		pattern, err := regexp.Compile(*options.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern of env var (%s): %s", key, err)
+		}
+		if !pattern.MatchString(value) {
+			return NewEnvVarRuleError(key, RulePattern, fmt.Sprintf("value doesn't match %s", *options.Pattern))
+		}
+	}
+
+	if options.Min == nil && options.Max == nil {
+		return nil
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		rule := RuleMin
+		if options.Min == nil {
+			rule = RuleMax
+		}
+		return NewEnvVarRuleError(key, rule, "value is not a number")
+	}
+	if options.Min != nil && number < *options.Min {
+		return NewEnvVarRuleError(key, RuleMin, fmt.Sprintf("value is less than %v", *options.Min))
+	}
+	if options.Max != nil && number > *options.Max {
+		return NewEnvVarRuleError(key, RuleMax, fmt.Sprintf("value is greater than %v", *options.Max))
+	}
+	return nil
+}
+
+// ConstraintViolation is a declared constraint, which an evaluated value violates.
+// The violating value is not part of the violation, it may be sensitive.
+type ConstraintViolation struct {
+	Key string
+	// Index is the index of the violating declaration in newEnvs
+	Index      int
+	Constraint string
+	Reason     string
+}
+
+func (v ConstraintViolation) Error() string {
+	return fmt.Sprintf("env var (%s) violates %s: %s", v.Key, v.Constraint, v.Reason)
+}
+
+// ConstraintViolationsError is returned by GetDeclarationsSideEffectsWithOptions if DeclarationOptions.Validate is set,
+// and the evaluated values violate the declared constraints, the types or the value constraint rules of the envs.
+type ConstraintViolationsError struct {
+	Violations []ConstraintViolation
+}
+
+func (e ConstraintViolationsError) Error() string {
+	var lines []string
+	for _, violation := range e.Violations {
+		lines = append(lines, "- "+violation.Error())
+	}
+	return fmt.Sprintf("%d constraint violation(s):\n%s", len(e.Violations), strings.Join(lines, "\n"))
+}
+
+// valueViolation returns the violation of the declaration (index) if err is an EnvVarRuleError
+// (its Constraint is the rule, like RulePattern) or an EnvVarTypeError (ConstraintType).
+func valueViolation(err error, index int) (ConstraintViolation, bool) {
+	var ruleErr EnvVarRuleError
+	if errors.As(err, &ruleErr) {
+		return ConstraintViolation{Key: ruleErr.Key, Index: index, Constraint: ruleErr.Rule, Reason: ruleErr.Reason}, true
+	}
+	var typeErr EnvVarTypeError
+	if errors.As(err, &typeErr) {
+		return ConstraintViolation{Key: typeErr.Key, Index: index, Constraint: ConstraintType, Reason: typeErr.Reason}, true
+	}
+	return ConstraintViolation{}, false
+}
+
+// CheckConstraints returns the violations of the constraints declared by the options of newEnvs
+// (is_required, value_options and is_dont_change_value), by the result of their evaluation.
+// The violations are sorted by the index of the violating declaration.
+func CheckConstraints(newEnvs []models.EnvironmentItemModel, result DeclarationSideEffects) ([]ConstraintViolation, error) {
+	var violations []ConstraintViolation
+	// requiredBy is the last declaration of the env vars with is_required
+	requiredBy := map[string]int{}
+	// fixedBy is the declaration of the env vars with is_dont_change_value, and its value
+	type fixedValue struct {
+		index int
+		value string
+	}
+	fixedBy := map[string]fixedValue{}
+	// valueOptions are the last declared value_options of the env vars
+	valueOptions := map[string][]string{}
+
+	for position, command := range result.CommandHistory {
+		idx := result.DeclarationOrder[position]
+		options, err := newEnvs[idx].GetOptions()
+		if err != nil {
+			return nil, err
+		}
+		key, value := command.Variable.Key, command.Variable.Value
+
+		if options.IsRequired != nil && *options.IsRequired {
+			requiredBy[key] = idx
+		}
+
+		if command.Action == SkipAction {
+			continue
+		}
+
+		if fixed, ok := fixedBy[key]; ok && (command.Action == UnsetAction || value != fixed.value) {
+			violations = append(violations, ConstraintViolation{
+				Key:        key,
+				Index:      idx,
+				Constraint: ConstraintDontChangeValue,
+				Reason:     fmt.Sprintf("changes the value declared at index %d", fixed.index),
+			})
+		}
+
+		if command.Action != SetAction {
+			continue
+		}
+
+		if len(options.ValueOptions) > 0 {
+			valueOptions[key] = options.ValueOptions
+		}
+		// the value_options of the enum type are checked (and reported) by the type check
+		isEnum := options.Type != nil && *options.Type == models.TypeEnum
+		if allowed := valueOptions[key]; !isEnum && len(allowed) > 0 && value != "" && !containsString(allowed, value) {
+			violations = append(violations, ConstraintViolation{
+				Key:        key,
+				Index:      idx,
+				Constraint: ConstraintValueOptions,
+				Reason:     "value is not one of: " + strings.Join(allowed, ", "),
+			})
+		}
+
+		if options.IsDontChangeValue != nil && *options.IsDontChangeValue {
+			if _, ok := fixedBy[key]; !ok {
+				fixedBy[key] = fixedValue{index: idx, value: value}
+			}
+		}
+	}
+
+	for key, idx := range requiredBy {
+		if result.ResultEnvironment[key] == "" {
+			violations = append(violations, ConstraintViolation{
+				Key:        key,
+				Index:      idx,
+				Constraint: ConstraintRequired,
+				Reason:     "value is empty",
+			})
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Index < violations[j].Index
+	})
+	return violations, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConstraints(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"TOKEN": "", "opts": map[string]interface{}{"is_required": true, "skip_if_empty": true}},
+		models.EnvironmentItemModel{"MODE": "debug", "opts": map[string]interface{}{"value_options": []interface{}{"debug", "release"}}},
+		models.EnvironmentItemModel{"MODE": "profile"},
+		models.EnvironmentItemModel{"MODE": ""},
+		models.EnvironmentItemModel{"PLATFORM": "ios", "opts": map[string]interface{}{"is_dont_change_value": true}},
+		models.EnvironmentItemModel{"PLATFORM": "ios"},
+		models.EnvironmentItemModel{"PLATFORM": "", "opts": map[string]interface{}{"unset": true}},
+		models.EnvironmentItemModel{"NAME": "$USER", "opts": map[string]interface{}{"is_required": true}},
+	)
+
+	result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{"USER": "john"})
+	require.NoError(t, err)
+
+	violations, err := CheckConstraints(newEnvs, result)
+	require.NoError(t, err)
+	require.Equal(t, []ConstraintViolation{
+		{Key: "TOKEN", Index: 0, Constraint: ConstraintRequired, Reason: "value is empty"},
+		{Key: "MODE", Index: 2, Constraint: ConstraintValueOptions, Reason: "value is not one of: debug, release"},
+		{Key: "PLATFORM", Index: 6, Constraint: ConstraintDontChangeValue, Reason: "changes the value declared at index 4"},
+	}, violations)
+
+	result, err = GetDeclarationsSideEffects(newEnvs, TestEnvSource{"TOKEN": "secret", "USER": "john"})
+	require.NoError(t, err)
+	violations, err = CheckConstraints(newEnvs, result)
+	require.NoError(t, err)
+	require.Len(t, violations, 2)
+}
+
+func TestGetDeclarationsSideEffectsWithOptions_Validate(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"TOKEN": "$SECRET", "opts": map[string]interface{}{"is_required": true}},
+	)
+
+	_, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{})
+	require.NoError(t, err)
+
+	_, err = GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{Validate: true})
+	var violationsErr ConstraintViolationsError
+	require.True(t, errors.As(err, &violationsErr))
+	require.EqualError(t, err, "1 constraint violation(s):\n- env var (TOKEN) violates is_required: value is empty")
+
+	_, err = GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{"SECRET": "secret"}, DeclarationOptions{Validate: true})
+	require.NoError(t, err)
+}
+
+func TestCheckValueRules(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	floatPtr := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name    string
+		value   string
+		options models.EnvironmentItemOptionsModel
+		wantErr error
+	}{
+		{name: "no rules", value: "any"},
+		{name: "pattern", value: "v1.2.3", options: models.EnvironmentItemOptionsModel{Pattern: pointers.NewStringPtr(`^v\d+\.\d+\.\d+$`)}},
+		{name: "pattern mismatch", value: "1.2", options: models.EnvironmentItemOptionsModel{Pattern: pointers.NewStringPtr(`^v\d+`)},
+			wantErr: EnvVarRuleError{Key: "KEY", Rule: RulePattern, Reason: `value doesn't match ^v\d+`}},
+		{name: "empty value skips pattern", value: "", options: models.EnvironmentItemOptionsModel{Pattern: pointers.NewStringPtr(`^v\d+`)}},
+		{name: "min length", value: "ab", options: models.EnvironmentItemOptionsModel{MinLength: intPtr(3)},
+			wantErr: EnvVarRuleError{Key: "KEY", Rule: RuleMinLength, Reason: "length (2) is less than 3"}},
+		{name: "empty value checked by min length", value: "", options: models.EnvironmentItemOptionsModel{MinLength: intPtr(1)},
+			wantErr: EnvVarRuleError{Key: "KEY", Rule: RuleMinLength, Reason: "length (0) is less than 1"}},
+		{name: "max length counts characters", value: "ééé", options: models.EnvironmentItemOptionsModel{MaxLength: intPtr(3)}},
+		{name: "max length", value: "abcd", options: models.EnvironmentItemOptionsModel{MaxLength: intPtr(3)},
+			wantErr: EnvVarRuleError{Key: "KEY", Rule: RuleMaxLength, Reason: "length (4) is greater than 3"}},
+		{name: "range", value: "8080", options: models.EnvironmentItemOptionsModel{Min: floatPtr(1), Max: floatPtr(65535)}},
+		{name: "below min", value: "0", options: models.EnvironmentItemOptionsModel{Min: floatPtr(1), Max: floatPtr(65535)},
+			wantErr: EnvVarRuleError{Key: "KEY", Rule: RuleMin, Reason: "value is less than 1"}},
+		{name: "above max", value: "1.5", options: models.EnvironmentItemOptionsModel{Max: floatPtr(1)},
+			wantErr: EnvVarRuleError{Key: "KEY", Rule: RuleMax, Reason: "value is greater than 1"}},
+		{name: "not a number", value: "many", options: models.EnvironmentItemOptionsModel{Max: floatPtr(1)},
+			wantErr: EnvVarRuleError{Key: "KEY", Rule: RuleMax, Reason: "value is not a number"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckValueRules("KEY", tt.value, tt.options)
+			require.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestGetDeclarationsSideEffects_ValueRules(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"PORT": "$PORT_BASE", "opts": map[string]interface{}{"min": 1024, "max": 65535}},
+	)
+
+	result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{"PORT_BASE": "8080"})
+	require.NoError(t, err)
+	require.Equal(t, "8080", result.EvaluatedNewEnvs["PORT"])
+
+	_, err = GetDeclarationsSideEffects(newEnvs, TestEnvSource{"PORT_BASE": "80"})
+	var ruleErr EnvVarRuleError
+	require.True(t, errors.As(err, &ruleErr))
+	require.Equal(t, EnvVarRuleError{Key: "PORT", Rule: RuleMin, Reason: "value is less than 1024"}, ruleErr)
+}
+
+func TestGetDeclarationsSideEffects_ValidateValueRules(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"PORT": "$PORT_BASE", "opts": map[string]interface{}{"min": 1024}},
+		models.EnvironmentItemModel{"DEBUG": "maybe", "opts": map[string]interface{}{"type": "bool"}},
+		models.EnvironmentItemModel{"TOKEN": "", "opts": map[string]interface{}{"is_required": true}},
+	)
+
+	_, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{"PORT_BASE": "80"})
+	require.EqualError(t, err, "failed to parse new environment variable (PORT): env var (PORT) value violates min: value is less than 1024")
+
+	_, err = GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{"PORT_BASE": "80"}, DeclarationOptions{Validate: true})
+	var violationsErr ConstraintViolationsError
+	require.True(t, errors.As(err, &violationsErr))
+	require.Equal(t, []ConstraintViolation{
+		{Key: "PORT", Index: 0, Constraint: RuleMin, Reason: "value is less than 1024"},
+		{Key: "DEBUG", Index: 1, Constraint: ConstraintType, Reason: "value is not a boolean"},
+		{Key: "TOKEN", Index: 2, Constraint: ConstraintRequired, Reason: "value is empty"},
+	}, violationsErr.Violations)
+}
+
+func TestGetDeclarationsSideEffects_ValidateSensitiveValues(t *testing.T) {
+	const secret = "hunter2-secret"
+	var newEnvs []models.EnvironmentItemModel
+	for i, valueType := range models.Types {
+		if valueType == models.TypeString || valueType == models.TypePath {
+			continue
+		}
+		opts := map[string]interface{}{"type": valueType, "is_sensitive": true}
+		if valueType == models.TypeEnum {
+			opts["value_options"] = []interface{}{"a", "b"}
+		}
+		newEnvs = append(newEnvs, models.EnvironmentItemModel{fmt.Sprintf("KEY_%d", i): secret, "opts": opts})
+	}
+	newEnvs = graphTestEnvs(t, newEnvs...)
+
+	for _, newEnv := range newEnvs {
+		_, err := GetDeclarationsSideEffects([]models.EnvironmentItemModel{newEnv}, TestEnvSource{})
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), secret)
+	}
+
+	_, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{Validate: true})
+	var violationsErr ConstraintViolationsError
+	require.True(t, errors.As(err, &violationsErr))
+	require.NotEmpty(t, violationsErr.Violations)
+	require.NotContains(t, violationsErr.Error(), secret)
+}
+
+func TestGetDeclarationsSideEffects_ValidateEnum(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"MODE": "Release", "opts": map[string]interface{}{"type": "enum", "value_options": []interface{}{"debug", "release"}}},
+	)
+
+	_, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{Validate: true})
+	var violationsErr ConstraintViolationsError
+	require.True(t, errors.As(err, &violationsErr))
+	require.Equal(t, []ConstraintViolation{
+		{Key: "MODE", Index: 0, Constraint: ConstraintType, Reason: "value is not one of: debug, release"},
+	}, violationsErr.Violations)
+}
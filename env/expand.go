@@ -3,6 +3,7 @@ package env
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/bitrise-io/envman/v2/models"
@@ -39,11 +40,37 @@ type DeclarationSideEffects struct {
 	// CommandHistory is an ordered list of commands: when performed in sequence,
 	// will result in a environment that contains the declared env vars
 	CommandHistory []Command
+	// DeclarationOrder is the index (in newEnvs) of the declaration of each command in CommandHistory,
+	// it's the order the declarations were evaluated in
+	DeclarationOrder []int
 	// ResultEnvironment is returned for reference,
 	// it will equal the environment after performing the commands
 	ResultEnvironment map[string]string
 	// EvaluatedNewEnvs is the set of envs resulted after evaluating newEnvs with envSource
 	EvaluatedNewEnvs map[string]string
+	// UndefinedReferences are the undefined references of the envs in the warn strict mode, for the caller to report
+	UndefinedReferences []UndefinedReference
+	// Provenance holds the declarations touching each declared env var (set, skip and unset), in evaluation order
+	Provenance map[string][]ProvenanceEvent
+}
+
+// DeclarationOptions configure GetDeclarationsSideEffectsWithOptions.
+type DeclarationOptions struct {
+	// Strict is the strict mode (models.StrictOff, models.StrictWarn or models.StrictError)
+	// of the envs which don't set the strict option
+	Strict string
+	// Resolution selects the order the declarations are evaluated in:
+	// models.ResolutionOrdered (the default) or models.ResolutionGraph
+	Resolution string
+	// ValueSources configure the resolution of the value sources
+	ValueSources ValueSourceOptions
+	// Validate checks the declared constraints (see CheckConstraints) after the evaluation,
+	// the violations are returned as a ConstraintViolationsError
+	Validate bool
+	// DollarEscape makes $$ an escaped (literal) dollar sign in the simple expansion, like in the bash expansion.
+	// Without it the simple expansion keeps the os.Expand behaviour, where $$ expands to an empty string.
+	// The envstores of models.DollarEscapeFormatVersion are evaluated with it.
+	DollarEscape bool
 }
 
 // EnvironmentSource implementations can return an initial environment
@@ -104,17 +131,100 @@ func SplitEnv(env string) (key string, value string) {
 //  - Additional Step inputs envs (BITRISE_STEP_SOURCE_DIR; BitriseTestDeployDirEnvKey ("BITRISE_TEST_DEPLOY_DIR"), PWD)
 //  - Input envs
 func GetDeclarationsSideEffects(newEnvs []models.EnvironmentItemModel, envSource EnvironmentSource) (DeclarationSideEffects, error) {
+	return GetDeclarationsSideEffectsWithOptions(newEnvs, envSource, DeclarationOptions{})
+}
+
+// GetDeclarationsSideEffectsWithOptions is GetDeclarationsSideEffects configured by opts.
+// In the error strict mode every undefined reference is collected, and an UndefinedReferencesError is returned.
+// In the graph resolution mode the declarations are evaluated in the order of their references (see graphOrder),
+// a reference cycle is returned as a ReferenceCycleError.
+func GetDeclarationsSideEffectsWithOptions(newEnvs []models.EnvironmentItemModel, envSource EnvironmentSource, opts DeclarationOptions) (DeclarationSideEffects, error) {
+	if opts.Strict != "" {
+		if err := models.ValidateStrict(opts.Strict); err != nil {
+			return DeclarationSideEffects{}, err
+		}
+	}
+
 	envs := envSource.GetEnvironment()
-	commandHistory := make([]Command, len(newEnvs))
+	initialEnvs := make(map[string]string, len(envs))
+	for key, value := range envs {
+		initialEnvs[key] = value
+	}
+
+	order := make([]int, len(newEnvs))
+	for i := range newEnvs {
+		order[i] = i
+	}
+	var bindings []map[string]int
+	switch opts.Resolution {
+	case "", models.ResolutionOrdered:
+	case models.ResolutionGraph:
+		declarations := make([]staticDeclaration, len(newEnvs))
+		for i, env := range newEnvs {
+			declaration, err := parseStaticDeclaration(env)
+			if err != nil {
+				return DeclarationSideEffects{}, declarationError(env, err)
+			}
+			declarations[i] = declaration
+		}
+
+		var err error
+		if order, bindings, err = graphOrder(declarations); err != nil {
+			return DeclarationSideEffects{}, err
+		}
+	default:
+		return DeclarationSideEffects{}, models.ValidateResolution(opts.Resolution)
+	}
+
+	commands := make([]Command, len(newEnvs))
+	commandHistory := make([]Command, 0, len(newEnvs))
 	evaluatedNewEnvs := make(map[string]string, len(newEnvs))
+	var undefinedWarnings, undefinedErrors []UndefinedReference
+	// violations are the value constraint rule and type violations (if opts.Validate is set)
+	var violations []ConstraintViolation
+	provenance := newProvenanceTracker(initialEnvs)
 
-	for i, env := range newEnvs {
-		command, err := getDeclarationCommand(env, envs)
-		if err != nil {
-			return DeclarationSideEffects{}, fmt.Errorf("failed to parse new environment variable (%s): %s", env, err)
+	for _, i := range order {
+		env := newEnvs[i]
+		scope := envs
+		if bindings != nil {
+			scope = graphScope(bindings[i], commands, initialEnvs)
+		}
+
+		command, undefinedNames, err := getDeclarationCommand(env, scope, opts)
+		if violation, ok := valueViolation(err, i); ok && opts.Validate {
+			violations = append(violations, violation)
+		} else if err != nil {
+			return DeclarationSideEffects{}, declarationError(env, err)
+		}
+
+		commands[i] = command
+		commandHistory = append(commandHistory, command)
+
+		var declarationBindings map[string]int
+		if bindings != nil {
+			declarationBindings = bindings[i]
+		}
+		if err := provenance.record(i, env, command, declarationBindings, commands); err != nil {
+			return DeclarationSideEffects{}, declarationError(env, err)
 		}
 
-		commandHistory[i] = command
+		if len(undefinedNames) > 0 {
+			strict, err := strictMode(env, opts)
+			if err != nil {
+				return DeclarationSideEffects{}, err
+			}
+
+			for _, name := range undefinedNames {
+				reference := UndefinedReference{Key: command.Variable.Key, Index: i, Reference: name}
+				switch strict {
+				case models.StrictWarn:
+					undefinedWarnings = append(undefinedWarnings, reference)
+				case models.StrictError:
+					undefinedErrors = append(undefinedErrors, reference)
+				}
+			}
+		}
 
 		switch command.Action {
 		case SetAction:
@@ -129,43 +239,106 @@ func GetDeclarationsSideEffects(newEnvs []models.EnvironmentItemModel, envSource
 		}
 	}
 
-	return DeclarationSideEffects{
-		CommandHistory:    commandHistory,
-		ResultEnvironment: envs,
-		EvaluatedNewEnvs:  evaluatedNewEnvs,
-	}, nil
+	if len(undefinedErrors) > 0 {
+		return DeclarationSideEffects{}, UndefinedReferencesError{References: undefinedErrors}
+	}
+
+	if opts.ValueSources.ListBytesLimit > 0 {
+		size, err := resolvedValuesSize(newEnvs, commands)
+		if err != nil {
+			return DeclarationSideEffects{}, err
+		}
+		if size > opts.ValueSources.ListBytesLimit {
+			return DeclarationSideEffects{}, NewEnvVarListTooLargeError(float64(size)/1024, float64(opts.ValueSources.ListBytesLimit)/1024)
+		}
+	}
+
+	result := DeclarationSideEffects{
+		CommandHistory:      commandHistory,
+		DeclarationOrder:    order,
+		ResultEnvironment:   envs,
+		EvaluatedNewEnvs:    evaluatedNewEnvs,
+		UndefinedReferences: undefinedWarnings,
+		Provenance:          provenance.provenance,
+	}
+
+	if opts.Validate {
+		constraintViolations, err := CheckConstraints(newEnvs, result)
+		if err != nil {
+			return DeclarationSideEffects{}, err
+		}
+		violations = append(violations, constraintViolations...)
+		if len(violations) > 0 {
+			sort.SliceStable(violations, func(i, j int) bool {
+				return violations[i].Index < violations[j].Index
+			})
+			return DeclarationSideEffects{}, ConstraintViolationsError{Violations: violations}
+		}
+	}
+
+	return result, nil
 }
 
-// getDeclarationCommand maps a variable to be declared (env) to an expanded env key and value.
+// declarationError wraps the error of evaluating a declaration (env), naming its key.
+func declarationError(env models.EnvironmentItemModel, err error) error {
+	key, _, keyErr := env.GetKeyValuePair()
+	if keyErr != nil {
+		return fmt.Errorf("failed to parse new environment variable: %w", err)
+	}
+	return fmt.Errorf("failed to parse new environment variable (%s): %w", key, err)
+}
+
+// getDeclarationCommand maps a variable to be declared (env) to an expanded env key and value,
+// and returns the names of the undefined env vars referenced by the value.
+// The value of a value source is resolved (by opts.ValueSources), and it's not expanded.
+// The evaluated value is finalized by finalizeValue, if it violates the type or a value constraint rule of the env,
+// the command is returned with the error, so the evaluation can go on when validating.
 // The current process environment is not changed.
-func getDeclarationCommand(env models.EnvironmentItemModel, envs map[string]string) (Command, error) {
+func getDeclarationCommand(env models.EnvironmentItemModel, envs map[string]string, opts DeclarationOptions) (Command, []string, error) {
 	envKey, envValue, err := env.GetKeyValuePair()
 	if err != nil {
-		return Command{}, fmt.Errorf("failed to get new environment variable name and value: %s", err)
+		return Command{}, nil, fmt.Errorf("failed to get new environment variable name and value: %s", err)
 	}
 
 	options, err := env.GetOptions()
 	if err != nil {
-		return Command{}, fmt.Errorf("failed to get new environment options: %s", err)
+		return Command{}, nil, fmt.Errorf("failed to get new environment options: %s", err)
 	}
 
 	if options.Unset != nil && *options.Unset {
 		return Command{
 			Action:   UnsetAction,
 			Variable: Variable{Key: envKey},
-		}, nil
+		}, nil, nil
+	}
+
+	if options.ValueFrom != nil {
+		envValue, err = resolveValueSource(envKey, *options.ValueFrom, envs, opts.ValueSources)
+		if err != nil {
+			return Command{}, nil, fmt.Errorf("failed to resolve value of env var (%s): %s", envKey, err)
+		}
 	}
 
 	if options.SkipIfEmpty != nil && *options.SkipIfEmpty && envValue == "" {
 		return Command{
 			Action:   SkipAction,
 			Variable: Variable{Key: envKey},
-		}, nil
+		}, nil, nil
 	}
 
+	if options.ValueFrom != nil {
+		envValue, err = finalizeValue(envKey, envValue, options, envs)
+		return Command{
+			Action:   SetAction,
+			Variable: Variable{Key: envKey, Value: envValue},
+		}, nil, err
+	}
+
+	var undefined []string
 	mappingFuncFactory := func(envs map[string]string) func(string) string {
 		return func(key string) string {
 			if _, ok := envs[key]; !ok {
+				undefined = appendUnique(undefined, key)
 				return ""
 			}
 
@@ -173,15 +346,72 @@ func getDeclarationCommand(env models.EnvironmentItemModel, envs map[string]stri
 		}
 	}
 
-	if options.IsExpand != nil && *options.IsExpand {
-		envValue = os.Expand(envValue, mappingFuncFactory(envs))
+	if isRenderedTemplate(options) {
+		envValue, err = renderTemplate(envKey, envValue, envs)
+		if err != nil {
+			return Command{}, nil, fmt.Errorf("failed to render template of env var (%s): %s", envKey, err)
+		}
+	} else if options.IsExpand != nil && *options.IsExpand {
+		expansion := models.ExpansionSimple
+		if options.Expansion != nil {
+			expansion = *options.Expansion
+		}
+
+		switch expansion {
+		case models.ExpansionSimple, models.ExpansionTemplate:
+			if opts.DollarEscape {
+				envValue = expandSimple(envValue, mappingFuncFactory(envs))
+			} else {
+				envValue = os.Expand(envValue, mappingFuncFactory(envs))
+			}
+		case models.ExpansionBash:
+			envValue, undefined, err = expandBash(envValue, envs)
+			if err != nil {
+				return Command{}, nil, fmt.Errorf("failed to expand env var (%s): %s", envKey, err)
+			}
+		default:
+			return Command{}, nil, fmt.Errorf("invalid expansion (%s) of env var (%s)", expansion, envKey)
+		}
 	}
 
+	envValue, err = finalizeValue(envKey, envValue, options, envs)
+
 	return Command{
 		Action: SetAction,
 		Variable: Variable{
 			Key:   envKey,
 			Value: envValue,
 		},
-	}, nil
+	}, undefined, err
+}
+
+// finalizeValue applies the transforms and the list operation on the evaluated value of the env var (key),
+// then validates and normalizes the result by its type, and checks its value constraint rules.
+// The value is returned with the EnvVarTypeError and EnvVarRuleError too (not normalized if its type is invalid).
+func finalizeValue(key, value string, options models.EnvironmentItemOptionsModel, envs map[string]string) (string, error) {
+	value, err := applyTransforms(key, value, options.Transforms, envs)
+	if err != nil {
+		return "", err
+	}
+	if options.Operation != nil {
+		if err := models.ValidateOperation(*options.Operation); err != nil {
+			return "", fmt.Errorf("invalid list operation of env var (%s): %s", key, err)
+		}
+		value = applyListOperation(envs[key], value, options)
+	}
+	normalized, err := NormalizeValue(key, value, options)
+	if err != nil {
+		return value, err
+	}
+	return normalized, CheckValueRules(key, normalized, options)
+}
+
+// expandSimple expands the $VAR and ${VAR} references of the value like os.Expand,
+// except that $$ is an escaped (literal) dollar sign.
+func expandSimple(value string, mapping func(string) string) string {
+	parts := strings.Split(value, "$$")
+	for i, part := range parts {
+		parts[i] = os.Expand(part, mapping)
+	}
+	return strings.Join(parts, "$")
 }
@@ -1,6 +1,7 @@
 package env
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -137,3 +138,98 @@ func TestSplitEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDeclarationsSideEffects_BashExpansionError(t *testing.T) {
+	newEnv := models.EnvironmentItemModel{"BUILD_DIR": "${SOURCE_DIR:?is required}", "opts": map[string]interface{}{"expansion": "bash"}}
+	require.NoError(t, newEnv.FillMissingDefaults())
+
+	_, err := GetDeclarationsSideEffects([]models.EnvironmentItemModel{newEnv}, TestEnvSource{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to expand env var (BUILD_DIR): SOURCE_DIR: is required")
+}
+
+func TestGetDeclarationsSideEffectsWithOptions_DollarEscape(t *testing.T) {
+	newEnvs := []models.EnvironmentItemModel{
+		{"CURRENCY_USER": "john"},
+		{"PRICE": "$$5 for $CURRENCY_USER"},
+		{"REFERENCE": "$${CURRENCY_USER} is $CURRENCY_USER, $$$CURRENCY_USER costs $$$$"},
+		{"TEMPLATE": "$$5 for $CURRENCY_USER", "opts": map[string]interface{}{"expansion": "template"}},
+		{"NOT_EXPANDED": "$$5 for $CURRENCY_USER", "opts": map[string]interface{}{"is_expand": false}},
+	}
+	for _, newEnv := range newEnvs {
+		require.NoError(t, newEnv.FillMissingDefaults())
+	}
+
+	t.Log("$$ expands to an empty string by default, like with os.Expand")
+	{
+		result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"CURRENCY_USER": "john",
+			"PRICE":         "5 for john",
+			"REFERENCE":     "{CURRENCY_USER} is john, john costs ",
+			"TEMPLATE":      "5 for john",
+			"NOT_EXPANDED":  "$$5 for $CURRENCY_USER",
+		}, result.EvaluatedNewEnvs)
+	}
+
+	t.Log("$$ is an escaped dollar sign with DollarEscape")
+	{
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{DollarEscape: true})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"CURRENCY_USER": "john",
+			"PRICE":         "$5 for john",
+			"REFERENCE":     "${CURRENCY_USER} is john, $john costs $$",
+			"TEMPLATE":      "$5 for john",
+			"NOT_EXPANDED":  "$$5 for $CURRENCY_USER",
+		}, result.EvaluatedNewEnvs)
+	}
+}
+
+func TestGetDeclarationsSideEffectsWithOptions_Strict(t *testing.T) {
+	newEnvs := []models.EnvironmentItemModel{
+		{"SOURCE_DIR": "/bitrise/src"},
+		{"BUILD_DIR": "$BITRISE_SOURCE_DRI/build"},
+		{"OUTPUT_DIR": "$OUTPUT_ROOT/$BUILD_DIR", "opts": map[string]interface{}{"strict": "error"}},
+		{"LOG_DIR": "$LOG_ROOT", "opts": map[string]interface{}{"strict": "off"}},
+		{"CACHE_DIR": "$CACHE_ROOT", "opts": map[string]interface{}{"strict": "warn"}},
+	}
+	for _, newEnv := range newEnvs {
+		require.NoError(t, newEnv.FillMissingDefaults())
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		_, err := GetDeclarationsSideEffects(newEnvs[:2], TestEnvSource{})
+		require.NoError(t, err)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{Strict: models.StrictError})
+
+		var undefinedErr UndefinedReferencesError
+		require.True(t, errors.As(err, &undefinedErr))
+		require.Equal(t, []UndefinedReference{
+			{Key: "BUILD_DIR", Index: 1, Reference: "BITRISE_SOURCE_DRI"},
+			{Key: "OUTPUT_DIR", Index: 2, Reference: "OUTPUT_ROOT"},
+		}, undefinedErr.References)
+		require.EqualError(t, err, `2 undefined env var reference(s):
+- BUILD_DIR (index 1) references undefined env var: BITRISE_SOURCE_DRI
+- OUTPUT_DIR (index 2) references undefined env var: OUTPUT_ROOT`)
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		result, err := GetDeclarationsSideEffectsWithOptions(append(newEnvs[:2:2], newEnvs[3:]...), TestEnvSource{}, DeclarationOptions{Strict: models.StrictWarn})
+		require.NoError(t, err)
+		require.Equal(t, []UndefinedReference{
+			{Key: "BUILD_DIR", Index: 1, Reference: "BITRISE_SOURCE_DRI"},
+			{Key: "CACHE_DIR", Index: 3, Reference: "CACHE_ROOT"},
+		}, result.UndefinedReferences)
+		require.Equal(t, "/build", result.EvaluatedNewEnvs["BUILD_DIR"])
+	})
+
+	t.Run("invalid strict mode", func(t *testing.T) {
+		_, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{Strict: "on"})
+		require.EqualError(t, err, "unknown strict mode (on), supported strict modes: off, warn, error")
+	})
+}
@@ -0,0 +1,240 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// ReferenceCycleError is returned by GetDeclarationsSideEffectsWithOptions in the graph resolution mode,
+// if the declarations reference each other in a cycle.
+type ReferenceCycleError struct {
+	// Keys is the path of the cycle, the first key is repeated at the end
+	Keys []string
+}
+
+func (e ReferenceCycleError) Error() string {
+	return "env var reference cycle: " + strings.Join(e.Keys, " -> ")
+}
+
+// staticDeclaration is what can be known about a declaration without evaluating it.
+type staticDeclaration struct {
+	key string
+	// effective is false for the declarations skipped by skip_if_empty, they don't change the environment
+	effective bool
+	// references are the names of the env vars, which the value may reference
+	references []string
+}
+
+func parseStaticDeclaration(env models.EnvironmentItemModel) (staticDeclaration, error) {
+	key, value, err := env.GetKeyValuePair()
+	if err != nil {
+		return staticDeclaration{}, fmt.Errorf("failed to get new environment variable name and value: %s", err)
+	}
+	options, err := env.GetOptions()
+	if err != nil {
+		return staticDeclaration{}, fmt.Errorf("failed to get new environment options: %s", err)
+	}
+
+	declaration := staticDeclaration{key: key, effective: true}
+	switch {
+	case options.Unset != nil && *options.Unset:
+	case options.SkipIfEmpty != nil && *options.SkipIfEmpty && value == "" && options.ValueFrom == nil:
+		declaration.effective = false
+	default:
+		declaration.references = valueReferences(key, value, options)
+	}
+	return declaration, nil
+}
+
+// valueReferences returns the names of the env vars, which the value of a set env var (key) may reference.
+// A list operation references the current value of the env var itself.
+func valueReferences(key, value string, options models.EnvironmentItemOptionsModel) []string {
+	var names []string
+	switch {
+	case options.ValueFrom != nil:
+	case isRenderedTemplate(options):
+		names = templateReferences(value)
+	case options.IsExpand != nil && *options.IsExpand:
+		names = scanReferences(value)
+	}
+	if options.Operation != nil {
+		names = appendUnique(names, key)
+	}
+	return names
+}
+
+// scanReferences returns the names referenced by the value ($NAME, ${NAME...} and ${#NAME}),
+// including the references nested into the operators of the bash expansion.
+// It may return names, which are not expanded (like the reference in the default of a set env var).
+func scanReferences(value string) []string {
+	var names []string
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 >= len(value) {
+			continue
+		}
+		rest := value[i+1:]
+		switch {
+		case rest[0] == '$':
+			i++
+			continue
+		case rest[0] == '{':
+			rest = strings.TrimPrefix(rest[1:], "#")
+		}
+		if name := shellName(rest); name != "" {
+			names = appendUnique(names, name)
+		}
+	}
+	return names
+}
+
+// graphOrder returns the order the declarations are evaluated in the graph resolution mode,
+// and the declaration (index) each reference of a declaration is bound to (-1 means the initial environment).
+//
+// A reference is bound to the last preceding declaration of the env var (like in the ordered mode),
+// or if there is no such declaration, to the last declaration of the env var (its final value).
+// A self-reference without a preceding declaration is bound to the initial environment.
+// The declarations of the same env var keep their order, so the later declarations still override the earlier ones.
+func graphOrder(declarations []staticDeclaration) ([]int, []map[string]int, error) {
+	declarationsOfKey := map[string][]int{}
+	for i, declaration := range declarations {
+		if declaration.effective {
+			declarationsOfKey[declaration.key] = append(declarationsOfKey[declaration.key], i)
+		}
+	}
+
+	bindings := make([]map[string]int, len(declarations))
+	dependencies := make([][]int, len(declarations))
+	for i, declaration := range declarations {
+		bindings[i] = map[string]int{}
+		for _, name := range declaration.references {
+			bound := bindReference(declarationsOfKey[name], i, name == declaration.key)
+			bindings[i][name] = bound
+			if bound >= 0 {
+				dependencies[i] = appendUniqueIndex(dependencies[i], bound)
+			}
+		}
+	}
+	for _, idxs := range declarationsOfKey {
+		for j := 1; j < len(idxs); j++ {
+			dependencies[idxs[j]] = appendUniqueIndex(dependencies[idxs[j]], idxs[j-1])
+		}
+	}
+
+	order, err := topologicalOrder(declarations, dependencies)
+	if err != nil {
+		return nil, nil, err
+	}
+	return order, bindings, nil
+}
+
+// graphScope returns the environment a declaration is evaluated in: the initial environment,
+// with the references of the declaration set to the values they are bound to.
+func graphScope(bindings map[string]int, commands []Command, initialEnvs map[string]string) map[string]string {
+	scope := make(map[string]string, len(initialEnvs)+len(bindings))
+	for name, value := range initialEnvs {
+		scope[name] = value
+	}
+	for name, bound := range bindings {
+		if bound < 0 {
+			continue
+		}
+		if commands[bound].Action == SetAction {
+			scope[name] = commands[bound].Variable.Value
+		} else {
+			delete(scope, name)
+		}
+	}
+	return scope
+}
+
+func bindReference(declarationIdxs []int, referencingIdx int, selfReference bool) int {
+	bound := -1
+	for _, idx := range declarationIdxs {
+		if idx < referencingIdx {
+			bound = idx
+		}
+	}
+	if bound >= 0 || selfReference || len(declarationIdxs) == 0 {
+		return bound
+	}
+	return declarationIdxs[len(declarationIdxs)-1]
+}
+
+// topologicalOrder orders the declarations after their dependencies, independent declarations keep their order.
+func topologicalOrder(declarations []staticDeclaration, dependencies [][]int) ([]int, error) {
+	dependents := make([][]int, len(declarations))
+	pending := make([]int, len(declarations))
+	for i, deps := range dependencies {
+		pending[i] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], i)
+		}
+	}
+
+	done := make([]bool, len(declarations))
+	order := make([]int, 0, len(declarations))
+	for len(order) < len(declarations) {
+		next := -1
+		for i := range declarations {
+			if !done[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return nil, referenceCycle(declarations, dependencies, done)
+		}
+
+		done[next] = true
+		order = append(order, next)
+		for _, dependent := range dependents[next] {
+			pending[dependent]--
+		}
+	}
+	return order, nil
+}
+
+// referenceCycle finds a cycle among the declarations, which could not be ordered.
+func referenceCycle(declarations []staticDeclaration, dependencies [][]int, done []bool) error {
+	start := -1
+	for i := range declarations {
+		if !done[i] {
+			start = i
+			break
+		}
+	}
+
+	// every not ordered declaration depends on a not ordered declaration, so following them leads into a cycle
+	visitedAt := map[int]int{}
+	var path []int
+	for current := start; ; {
+		if at, visited := visitedAt[current]; visited {
+			cycle := append(path[at:], current)
+			keys := make([]string, 0, len(cycle))
+			for _, idx := range cycle {
+				keys = append(keys, declarations[idx].key)
+			}
+			return ReferenceCycleError{Keys: keys}
+		}
+		visitedAt[current] = len(path)
+		path = append(path, current)
+
+		for _, dep := range dependencies[current] {
+			if !done[dep] {
+				current = dep
+				break
+			}
+		}
+	}
+}
+
+func appendUniqueIndex(idxs []int, idx int) []int {
+	for _, i := range idxs {
+		if i == idx {
+			return idxs
+		}
+	}
+	return append(idxs, idx)
+}
@@ -0,0 +1,142 @@
+package env
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func graphTestEnvs(t *testing.T, newEnvs ...models.EnvironmentItemModel) []models.EnvironmentItemModel {
+	for _, newEnv := range newEnvs {
+		require.NoError(t, newEnv.FillMissingDefaults())
+	}
+	return newEnvs
+}
+
+func TestGetDeclarationsSideEffectsWithOptions_GraphResolution(t *testing.T) {
+	graph := DeclarationOptions{Resolution: models.ResolutionGraph}
+
+	t.Run("forward reference", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"BUILD_DIR": "$SOURCE_DIR/build"},
+			models.EnvironmentItemModel{"SOURCE_DIR": "/bitrise/src"},
+		)
+
+		result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{})
+		require.NoError(t, err)
+		require.Equal(t, "/build", result.EvaluatedNewEnvs["BUILD_DIR"])
+		require.Equal(t, []int{0, 1}, result.DeclarationOrder)
+
+		result, err = GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, graph)
+		require.NoError(t, err)
+		require.Equal(t, "/bitrise/src/build", result.EvaluatedNewEnvs["BUILD_DIR"])
+		require.Equal(t, []int{1, 0}, result.DeclarationOrder)
+		require.Equal(t, "SOURCE_DIR", result.CommandHistory[0].Variable.Key)
+		require.Equal(t, "BUILD_DIR", result.CommandHistory[1].Variable.Key)
+	})
+
+	t.Run("later override", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"URL": "https://$HOST/api"},
+			models.EnvironmentItemModel{"HOST": "localhost"},
+			models.EnvironmentItemModel{"HOST": "example.com"},
+		)
+
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, graph)
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/api", result.EvaluatedNewEnvs["URL"])
+		require.Equal(t, "example.com", result.EvaluatedNewEnvs["HOST"])
+		require.Equal(t, []int{1, 2, 0}, result.DeclarationOrder)
+	})
+
+	t.Run("preceding declaration and self-reference", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"PATH": "$TOOLS_DIR:$PATH"},
+			models.EnvironmentItemModel{"NAME": "first"},
+			models.EnvironmentItemModel{"GREETING": "hello $NAME"},
+			models.EnvironmentItemModel{"NAME": "second"},
+			models.EnvironmentItemModel{"TOOLS_DIR": "/tools"},
+		)
+
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{"PATH": "/bin"}, graph)
+		require.NoError(t, err)
+		require.Equal(t, "/tools:/bin", result.EvaluatedNewEnvs["PATH"])
+		require.Equal(t, "hello first", result.EvaluatedNewEnvs["GREETING"])
+		require.Equal(t, "second", result.EvaluatedNewEnvs["NAME"])
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"MESSAGE": "token: $TOKEN"},
+			models.EnvironmentItemModel{"TOKEN": "secret"},
+			models.EnvironmentItemModel{"TOKEN": "", "opts": map[string]interface{}{"unset": true}},
+		)
+
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{"TOKEN": "initial"}, graph)
+		require.NoError(t, err)
+		require.Equal(t, "token: ", result.EvaluatedNewEnvs["MESSAGE"])
+		_, ok := result.ResultEnvironment["TOKEN"]
+		require.False(t, ok)
+	})
+
+	t.Run("skipped declaration", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"MESSAGE": "branch: $BRANCH"},
+			models.EnvironmentItemModel{"BRANCH": "main"},
+			models.EnvironmentItemModel{"BRANCH": "", "opts": map[string]interface{}{"skip_if_empty": true}},
+		)
+
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, graph)
+		require.NoError(t, err)
+		require.Equal(t, "branch: main", result.EvaluatedNewEnvs["MESSAGE"])
+	})
+
+	t.Run("strict mode reports the declaration index", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"B": "$A $MISSING"},
+			models.EnvironmentItemModel{"A": "a"},
+		)
+
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{Resolution: models.ResolutionGraph, Strict: models.StrictWarn})
+		require.NoError(t, err)
+		require.Equal(t, []UndefinedReference{{Key: "B", Index: 0, Reference: "MISSING"}}, result.UndefinedReferences)
+	})
+
+	t.Run("template", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"SLUG": `{{ getenv "NAME" | lower }}`, "opts": map[string]interface{}{"is_template": true, "expansion": "template"}},
+			models.EnvironmentItemModel{"NAME": "App"},
+		)
+
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, graph)
+		require.NoError(t, err)
+		require.Equal(t, "app", result.EvaluatedNewEnvs["SLUG"])
+	})
+
+	t.Run("reference cycle", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"INDEPENDENT": "value"},
+			models.EnvironmentItemModel{"A": "$B"},
+			models.EnvironmentItemModel{"B": "${C:-default}"},
+			models.EnvironmentItemModel{"C": "$A"},
+		)
+
+		_, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, graph)
+		var cycleErr ReferenceCycleError
+		require.True(t, errors.As(err, &cycleErr))
+		require.Equal(t, []string{"A", "B", "C", "A"}, cycleErr.Keys)
+		require.EqualError(t, err, "env var reference cycle: A -> B -> C -> A")
+	})
+
+	t.Run("invalid resolution", func(t *testing.T) {
+		_, err := GetDeclarationsSideEffectsWithOptions(nil, TestEnvSource{}, DeclarationOptions{Resolution: "random"})
+		require.EqualError(t, err, "unknown resolution (random), supported resolutions: ordered, graph")
+	})
+}
+
+func TestScanReferences(t *testing.T) {
+	require.Equal(t, []string{"A", "B", "C", "D", "1"}, scanReferences("$A ${B} ${#C} ${D:-$A} $$E $1 $"))
+	require.Equal(t, []string(nil), scanReferences("no references"))
+}
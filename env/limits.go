@@ -0,0 +1,41 @@
+package env
+
+import "fmt"
+
+// EnvVarValueTooLargeError is returned if a value is larger than the configured limit.
+type EnvVarValueTooLargeError struct {
+	Key           string
+	ValueSizeInKB float64
+	MaxSizeInKB   float64
+}
+
+// NewEnvVarValueTooLargeError ...
+func NewEnvVarValueTooLargeError(key string, valueSizeInKB, maxSizeInKB float64) error {
+	return EnvVarValueTooLargeError{
+		Key:           key,
+		ValueSizeInKB: valueSizeInKB,
+		MaxSizeInKB:   maxSizeInKB,
+	}
+}
+
+func (err EnvVarValueTooLargeError) Error() string {
+	return fmt.Sprintf("env var (%s) value is too large (%#v KB), max allowed size: %#v KB", err.Key, err.ValueSizeInKB, err.MaxSizeInKB)
+}
+
+// EnvVarListTooLargeError is returned if the env var list is larger than the configured limit.
+type EnvVarListTooLargeError struct {
+	EnvListSizeInKB float64
+	MaxSizeInKB     float64
+}
+
+// NewEnvVarListTooLargeError ...
+func NewEnvVarListTooLargeError(envListSizeInKB, maxSizeInKB float64) EnvVarListTooLargeError {
+	return EnvVarListTooLargeError{
+		EnvListSizeInKB: envListSizeInKB,
+		MaxSizeInKB:     maxSizeInKB,
+	}
+}
+
+func (e EnvVarListTooLargeError) Error() string {
+	return fmt.Sprintf("env var list is too large (%#v KB), max allowed size: %#v KB", e.EnvListSizeInKB, e.MaxSizeInKB)
+}
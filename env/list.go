@@ -0,0 +1,64 @@
+package env
+
+import (
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// applyListOperation applies the list operation of the options with the items on the current list value.
+// Empty items are dropped (so an undefined list doesn't get a leading separator).
+func applyListOperation(current, items string, options models.EnvironmentItemOptionsModel) string {
+	separator := models.DefaultListSeparator
+	if options.Separator != nil {
+		separator = *options.Separator
+	}
+
+	currentItems := splitList(current, separator)
+	operationItems := splitList(items, separator)
+
+	var result []string
+	switch *options.Operation {
+	case models.OperationPrepend:
+		result = append(operationItems, currentItems...)
+	case models.OperationAppend:
+		result = append(currentItems, operationItems...)
+	case models.OperationRemove:
+		removed := map[string]bool{}
+		for _, item := range operationItems {
+			removed[item] = true
+		}
+		for _, item := range currentItems {
+			if !removed[item] {
+				result = append(result, item)
+			}
+		}
+	}
+
+	if options.Dedupe != nil && *options.Dedupe {
+		result = dedupeList(result)
+	}
+	return strings.Join(result, separator)
+}
+
+func splitList(value, separator string) []string {
+	var items []string
+	for _, item := range strings.Split(value, separator) {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func dedupeList(items []string) []string {
+	seen := map[string]bool{}
+	var deduped []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			deduped = append(deduped, item)
+		}
+	}
+	return deduped
+}
@@ -0,0 +1,76 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyListOperation(t *testing.T) {
+	tests := []struct {
+		name      string
+		current   string
+		items     string
+		operation string
+		separator *string
+		dedupe    bool
+		want      string
+	}{
+		{name: "prepend", current: "/usr/bin:/bin", items: "/tools", operation: "prepend", want: "/tools:/usr/bin:/bin"},
+		{name: "prepend to undefined", current: "", items: "/tools", operation: "prepend", want: "/tools"},
+		{name: "append multiple items", current: "/usr/bin", items: "/a:/b", operation: "append", want: "/usr/bin:/a:/b"},
+		{name: "remove", current: "/a:/b:/a:/c", items: "/a", operation: "remove", want: "/b:/c"},
+		{name: "remove the last item", current: "/a", items: "/a", operation: "remove", want: ""},
+		{name: "prepend with dedupe moves the item", current: "/usr/bin:/tools", items: "/tools", operation: "prepend", dedupe: true, want: "/tools:/usr/bin"},
+		{name: "append with dedupe keeps the first item", current: "/tools:/usr/bin", items: "/tools", operation: "append", dedupe: true, want: "/tools:/usr/bin"},
+		{name: "custom separator", current: "a,b", items: "c", operation: "append", separator: pointers.NewStringPtr(","), want: "a,b,c"},
+		{name: "empty items are dropped", current: ":/usr/bin::", items: "/tools:", operation: "append", want: "/usr/bin:/tools"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := models.EnvironmentItemOptionsModel{
+				Operation: pointers.NewStringPtr(tt.operation),
+				Separator: tt.separator,
+				Dedupe:    pointers.NewBoolPtr(tt.dedupe),
+			}
+			require.Equal(t, tt.want, applyListOperation(tt.current, tt.items, options))
+		})
+	}
+}
+
+func TestGetDeclarationsSideEffects_ListOperations(t *testing.T) {
+	t.Run("initial environment", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"PATH": "$HOME/.tools/bin", "opts": map[string]interface{}{"operation": "prepend", "dedupe": true}},
+			models.EnvironmentItemModel{"PATH": "/usr/local/bin", "opts": map[string]interface{}{"operation": "remove"}},
+		)
+
+		result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{"HOME": "/home/user", "PATH": "/usr/local/bin:/usr/bin:/home/user/.tools/bin"})
+		require.NoError(t, err)
+		require.Equal(t, "/home/user/.tools/bin:/usr/bin", result.ResultEnvironment["PATH"])
+	})
+
+	t.Run("graph resolution", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"BUILD_PATH": "/bin"},
+			models.EnvironmentItemModel{"BUILD_PATH": "$TOOLS_DIR", "opts": map[string]interface{}{"operation": "prepend"}},
+			models.EnvironmentItemModel{"TOOLS_DIR": "/tools"},
+		)
+
+		result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{Resolution: models.ResolutionGraph})
+		require.NoError(t, err)
+		require.Equal(t, "/tools:/bin", result.ResultEnvironment["BUILD_PATH"])
+		require.Equal(t, []int{0, 2, 1}, result.DeclarationOrder)
+	})
+
+	t.Run("invalid operation", func(t *testing.T) {
+		newEnvs := graphTestEnvs(t,
+			models.EnvironmentItemModel{"PATH": "/tools", "opts": map[string]interface{}{"operation": "insert"}},
+		)
+
+		_, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{})
+		require.ErrorContains(t, err, "invalid list operation of env var (PATH): unknown operation (insert), supported operations: prepend, append, remove")
+	})
+}
@@ -0,0 +1,121 @@
+package env
+
+import (
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// ReferenceSource tells where the value of a referenced env var comes from.
+type ReferenceSource string
+
+const (
+	// ReferenceFromEnvironment means that the value comes from the initial environment (EnvironmentSource)
+	ReferenceFromEnvironment ReferenceSource = "environment"
+	// ReferenceFromDeclaration means that the value comes from a declaration of newEnvs
+	ReferenceFromDeclaration ReferenceSource = "declaration"
+	// ReferenceUndefined means that the referenced env var is not defined (or it's unset)
+	ReferenceUndefined ReferenceSource = "undefined"
+)
+
+// ReferenceProvenance is an env var referenced by a declaration's value.
+type ReferenceProvenance struct {
+	Name   string
+	Source ReferenceSource
+	// Index is the index (in newEnvs) of the declaration the value comes from, -1 if Source is not ReferenceFromDeclaration
+	Index int
+}
+
+// ProvenanceEvent is a declaration, which touched an env var.
+type ProvenanceEvent struct {
+	Action Action
+	// Index is the index of the declaration in newEnvs
+	Index int
+	// Value is the evaluated value of a SetAction
+	Value string
+	// ValueSource is the source the value of a SetAction is resolved from (if any)
+	ValueSource *models.ValueSourceModel
+	// Sensitive is true if the declaration is sensitive, or its value is expanded from a sensitive declaration's value
+	Sensitive bool
+	// References are the env vars referenced by the expanded (or rendered) value of a SetAction
+	// (the names in the operators of the bash expansion and in the branches of a template are included, even if they are not used)
+	References []ReferenceProvenance
+}
+
+// provenanceTracker records the provenance events of the declarations, in the order they are evaluated in.
+type provenanceTracker struct {
+	initialEnvs map[string]string
+	// declaredBy is the index of the declaration setting the current value of the env vars (ordered resolution),
+	// -1 if the env var is unset by a declaration
+	declaredBy map[string]int
+	// sensitive tells if the value of the declarations (by index) is sensitive
+	sensitive  map[int]bool
+	provenance map[string][]ProvenanceEvent
+}
+
+func newProvenanceTracker(initialEnvs map[string]string) *provenanceTracker {
+	return &provenanceTracker{
+		initialEnvs: initialEnvs,
+		declaredBy:  map[string]int{},
+		sensitive:   map[int]bool{},
+		provenance:  map[string][]ProvenanceEvent{},
+	}
+}
+
+// record records the declaration (idx) evaluated to command.
+// bindings are the bindings of the declaration's references in the graph resolution mode, nil otherwise.
+func (t *provenanceTracker) record(idx int, env models.EnvironmentItemModel, command Command, bindings map[string]int, commands []Command) error {
+	key, value, err := env.GetKeyValuePair()
+	if err != nil {
+		return err
+	}
+	options, err := env.GetOptions()
+	if err != nil {
+		return err
+	}
+
+	event := ProvenanceEvent{
+		Action:    command.Action,
+		Index:     idx,
+		Value:     command.Variable.Value,
+		Sensitive: options.IsSensitive != nil && *options.IsSensitive,
+	}
+	if command.Action == SetAction {
+		event.ValueSource = options.ValueFrom
+		for _, name := range valueReferences(key, value, options) {
+			reference := t.reference(name, bindings, commands)
+			if reference.Source == ReferenceFromDeclaration && t.sensitive[reference.Index] {
+				event.Sensitive = true
+			}
+			event.References = append(event.References, reference)
+		}
+	}
+	t.sensitive[idx] = event.Sensitive
+
+	switch command.Action {
+	case SetAction:
+		t.declaredBy[key] = idx
+	case UnsetAction:
+		t.declaredBy[key] = -1
+	}
+	t.provenance[key] = append(t.provenance[key], event)
+	return nil
+}
+
+func (t *provenanceTracker) reference(name string, bindings map[string]int, commands []Command) ReferenceProvenance {
+	declaredBy, declared := t.declaredBy[name]
+	if bound, ok := bindings[name]; ok {
+		declaredBy, declared = bound, bound >= 0
+		if declared && commands[bound].Action != SetAction {
+			declaredBy = -1
+		}
+	}
+
+	switch {
+	case declared && declaredBy >= 0:
+		return ReferenceProvenance{Name: name, Source: ReferenceFromDeclaration, Index: declaredBy}
+	case !declared:
+		if _, ok := t.initialEnvs[name]; ok {
+			return ReferenceProvenance{Name: name, Source: ReferenceFromEnvironment, Index: -1}
+		}
+	}
+	return ReferenceProvenance{Name: name, Source: ReferenceUndefined, Index: -1}
+}
@@ -0,0 +1,62 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDeclarationsSideEffects_Provenance(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"TOKEN": "secret", "opts": map[string]interface{}{"is_sensitive": true}},
+		models.EnvironmentItemModel{"URL": "https://$HOST/$PATH_PREFIX"},
+		models.EnvironmentItemModel{"AUTH_URL": "$URL?token=$TOKEN"},
+		models.EnvironmentItemModel{"URL": "", "opts": map[string]interface{}{"skip_if_empty": true}},
+		models.EnvironmentItemModel{"TOKEN": "", "opts": map[string]interface{}{"unset": true}},
+		models.EnvironmentItemModel{"LITERAL": "$TOKEN", "opts": map[string]interface{}{"is_expand": false}},
+	)
+
+	result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{"HOST": "example.com"})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]ProvenanceEvent{
+		"TOKEN": {
+			{Action: SetAction, Index: 0, Value: "secret", Sensitive: true},
+			{Action: UnsetAction, Index: 4},
+		},
+		"URL": {
+			{Action: SetAction, Index: 1, Value: "https://example.com/", References: []ReferenceProvenance{
+				{Name: "HOST", Source: ReferenceFromEnvironment, Index: -1},
+				{Name: "PATH_PREFIX", Source: ReferenceUndefined, Index: -1},
+			}},
+			{Action: SkipAction, Index: 3},
+		},
+		"AUTH_URL": {
+			{Action: SetAction, Index: 2, Value: "https://example.com/?token=secret", Sensitive: true, References: []ReferenceProvenance{
+				{Name: "URL", Source: ReferenceFromDeclaration, Index: 1},
+				{Name: "TOKEN", Source: ReferenceFromDeclaration, Index: 0},
+			}},
+		},
+		"LITERAL": {
+			{Action: SetAction, Index: 5, Value: "$TOKEN"},
+		},
+	}, result.Provenance)
+}
+
+func TestGetDeclarationsSideEffects_ProvenanceGraphResolution(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"MESSAGE": "$GREETING $NAME"},
+		models.EnvironmentItemModel{"NAME": "john"},
+		models.EnvironmentItemModel{"GREETING": ""},
+		models.EnvironmentItemModel{"GREETING": "", "opts": map[string]interface{}{"unset": true}},
+	)
+
+	result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{"GREETING": "hi"}, DeclarationOptions{Resolution: models.ResolutionGraph})
+	require.NoError(t, err)
+	require.Equal(t, []ProvenanceEvent{
+		{Action: SetAction, Index: 0, Value: " john", References: []ReferenceProvenance{
+			{Name: "GREETING", Source: ReferenceUndefined, Index: -1},
+			{Name: "NAME", Source: ReferenceFromDeclaration, Index: 1},
+		}},
+	}, result.Provenance["MESSAGE"])
+}
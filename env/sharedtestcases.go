@@ -197,4 +197,111 @@ var EnvmanSharedTestCases = []struct {
 			{Action: SetAction, Variable: Variable{Key: "simulator_device", Value: "iPhone top secret"}},
 		},
 	},
+	{
+		Name: "Bash expansion operators are not supported by default",
+		Envs: []models.EnvironmentItemModel{
+			{"VERSION": "v1.2.3", "opts": map[string]interface{}{}},
+			{"BUILD_VERSION": "${VERSION#v}", "opts": map[string]interface{}{}},
+		},
+		Want: []Command{
+			{Action: SetAction, Variable: Variable{Key: "VERSION", Value: "v1.2.3"}},
+			{Action: SetAction, Variable: Variable{Key: "BUILD_VERSION", Value: ""}},
+		},
+	},
+	{
+		Name: "Bash expansion",
+		Envs: []models.EnvironmentItemModel{
+			{"VERSION": "v1.2.3", "opts": map[string]interface{}{}},
+			{"BUILD_VERSION": "${VERSION#v}", "opts": map[string]interface{}{"expansion": "bash"}},
+			{"BUILD_DIR": "${ENVMAN_UNDEFINED_BUILD_DIR:-/tmp/build}", "opts": map[string]interface{}{"expansion": "bash"}},
+			{"ARTIFACT": "${BUILD_DIR}/app-${BUILD_VERSION//./_}.zip", "opts": map[string]interface{}{"expansion": "bash"}},
+			{"LITERAL": "${VERSION:-v0}", "opts": map[string]interface{}{"is_expand": false, "expansion": "bash"}},
+		},
+		Want: []Command{
+			{Action: SetAction, Variable: Variable{Key: "VERSION", Value: "v1.2.3"}},
+			{Action: SetAction, Variable: Variable{Key: "BUILD_VERSION", Value: "1.2.3"}},
+			{Action: SetAction, Variable: Variable{Key: "BUILD_DIR", Value: "/tmp/build"}},
+			{Action: SetAction, Variable: Variable{Key: "ARTIFACT", Value: "/tmp/build/app-1_2_3.zip"}},
+			{Action: SetAction, Variable: Variable{Key: "LITERAL", Value: "${VERSION:-v0}"}},
+		},
+	},
+	{
+		Name: "Escaped dollar sign",
+		Envs: []models.EnvironmentItemModel{
+			{"CURRENCY_USER": "john", "opts": map[string]interface{}{}},
+			{"PRICE": "$$5 for $CURRENCY_USER", "opts": map[string]interface{}{"expansion": "bash"}},
+			{"REFERENCE": "$${CURRENCY_USER} is $CURRENCY_USER, $$$CURRENCY_USER costs $$$$", "opts": map[string]interface{}{"expansion": "bash"}},
+			{"NOT_EXPANDED": "$$5 for $CURRENCY_USER", "opts": map[string]interface{}{"is_expand": false, "expansion": "bash"}},
+		},
+		Want: []Command{
+			{Action: SetAction, Variable: Variable{Key: "CURRENCY_USER", Value: "john"}},
+			{Action: SetAction, Variable: Variable{Key: "PRICE", Value: "$5 for john"}},
+			{Action: SetAction, Variable: Variable{Key: "REFERENCE", Value: "${CURRENCY_USER} is john, $john costs $$"}},
+			{Action: SetAction, Variable: Variable{Key: "NOT_EXPANDED", Value: "$$5 for $CURRENCY_USER"}},
+		},
+	},
+	{
+		Name: "Escaped dollar sign, bash expansion",
+		Envs: []models.EnvironmentItemModel{
+			{"CURRENCY_USER": "john", "opts": map[string]interface{}{}},
+			{"PRICE": "$$5 for ${CURRENCY_USER:-nobody}", "opts": map[string]interface{}{"expansion": "bash"}},
+			{"DEFAULT_PRICE": "${ENVMAN_UNDEFINED_PRICE:-$$5}", "opts": map[string]interface{}{"expansion": "bash"}},
+			{"AMOUNT": "${PRICE%%$$*}${PRICE#$$}", "opts": map[string]interface{}{"expansion": "bash"}},
+		},
+		Want: []Command{
+			{Action: SetAction, Variable: Variable{Key: "CURRENCY_USER", Value: "john"}},
+			{Action: SetAction, Variable: Variable{Key: "PRICE", Value: "$5 for john"}},
+			{Action: SetAction, Variable: Variable{Key: "DEFAULT_PRICE", Value: "$5"}},
+			{Action: SetAction, Variable: Variable{Key: "AMOUNT", Value: "5 for john"}},
+		},
+	},
+	{
+		Name: "Template",
+		Envs: []models.EnvironmentItemModel{
+			{"APP_NAME": " My App", "opts": map[string]interface{}{}},
+			{"APP_SLUG": `{{ .APP_NAME | trim | lower }}`, "opts": map[string]interface{}{"is_template": true, "expansion": "template"}},
+			{"APP_TITLE": `{{ getenv "APP_SLUG" | upper }}: {{ .ENVMAN_UNDEFINED_TITLE | default "untitled" }}`, "opts": map[string]interface{}{"is_template": true, "expansion": "template"}},
+			{"APP_FLAVOR": `{{ if isset "ENVMAN_UNDEFINED_FLAVOR" }}custom{{ else }}default{{ end }}`, "opts": map[string]interface{}{"is_template": true, "expansion": "template"}},
+			{"APP_TAGS": `{{ split "," "a,b" | join "+" }} $APP_SLUG`, "opts": map[string]interface{}{"is_template": true, "expansion": "template"}},
+		},
+		Want: []Command{
+			{Action: SetAction, Variable: Variable{Key: "APP_NAME", Value: " My App"}},
+			{Action: SetAction, Variable: Variable{Key: "APP_SLUG", Value: "my app"}},
+			{Action: SetAction, Variable: Variable{Key: "APP_TITLE", Value: "MY APP: untitled"}},
+			{Action: SetAction, Variable: Variable{Key: "APP_FLAVOR", Value: "default"}},
+			{Action: SetAction, Variable: Variable{Key: "APP_TAGS", Value: "a+b $APP_SLUG"}},
+		},
+	},
+	{
+		Name: "Template without the template expansion",
+		Envs: []models.EnvironmentItemModel{
+			{"APP_NAME": "app", "opts": map[string]interface{}{}},
+			{"APP_SLUG": `{{ .APP_NAME }}-$APP_NAME`, "opts": map[string]interface{}{"is_template": true}},
+			{"APP_LABEL": `{{ .APP_NAME }}-$APP_NAME`, "opts": map[string]interface{}{"is_template": true, "is_expand": false}},
+		},
+		Want: []Command{
+			{Action: SetAction, Variable: Variable{Key: "APP_NAME", Value: "app"}},
+			{Action: SetAction, Variable: Variable{Key: "APP_SLUG", Value: "{{ .APP_NAME }}-app"}},
+			{Action: SetAction, Variable: Variable{Key: "APP_LABEL", Value: "{{ .APP_NAME }}-$APP_NAME"}},
+		},
+	},
+	{
+		Name: "List operations",
+		Envs: []models.EnvironmentItemModel{
+			{"TOOLS_PATH": "/usr/bin:/bin", "opts": map[string]interface{}{}},
+			{"TOOLS_PATH": "/opt/tools/bin", "opts": map[string]interface{}{"operation": "prepend"}},
+			{"TOOLS_PATH": "/usr/local/bin:/bin", "opts": map[string]interface{}{"operation": "append", "dedupe": true}},
+			{"TOOLS_PATH": "/usr/bin", "opts": map[string]interface{}{"operation": "remove"}},
+			{"TOOLS_FLAGS": "-v", "opts": map[string]interface{}{"operation": "append", "separator": " "}},
+			{"TOOLS_FLAGS": "-x -v", "opts": map[string]interface{}{"operation": "append", "separator": " "}},
+		},
+		Want: []Command{
+			{Action: SetAction, Variable: Variable{Key: "TOOLS_PATH", Value: "/usr/bin:/bin"}},
+			{Action: SetAction, Variable: Variable{Key: "TOOLS_PATH", Value: "/opt/tools/bin:/usr/bin:/bin"}},
+			{Action: SetAction, Variable: Variable{Key: "TOOLS_PATH", Value: "/opt/tools/bin:/usr/bin:/bin:/usr/local/bin"}},
+			{Action: SetAction, Variable: Variable{Key: "TOOLS_PATH", Value: "/opt/tools/bin:/bin:/usr/local/bin"}},
+			{Action: SetAction, Variable: Variable{Key: "TOOLS_FLAGS", Value: "-v"}},
+			{Action: SetAction, Variable: Variable{Key: "TOOLS_FLAGS", Value: "-v -x -v"}},
+		},
+	},
 }
@@ -0,0 +1,51 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// UndefinedReference is a reference to an undefined env var in the value of a declared env.
+type UndefinedReference struct {
+	// Key is the key of the declared env
+	Key string
+	// Index is the position of the declaration in the list of new envs (0-based)
+	Index int
+	// Reference is the name of the undefined env var
+	Reference string
+}
+
+func (r UndefinedReference) String() string {
+	return fmt.Sprintf("%s (index %d) references undefined env var: %s", r.Key, r.Index, r.Reference)
+}
+
+// UndefinedReferencesError is returned by GetDeclarationsSideEffectsWithOptions,
+// if envs in the error strict mode reference undefined env vars.
+type UndefinedReferencesError struct {
+	References []UndefinedReference
+}
+
+func (e UndefinedReferencesError) Error() string {
+	lines := make([]string, 0, len(e.References))
+	for _, reference := range e.References {
+		lines = append(lines, "- "+reference.String())
+	}
+	return fmt.Sprintf("%d undefined env var reference(s):\n%s", len(e.References), strings.Join(lines, "\n"))
+}
+
+// strictMode returns the strict mode of the env: its strict option, or the default of opts.
+func strictMode(env models.EnvironmentItemModel, opts DeclarationOptions) (string, error) {
+	options, err := env.GetOptions()
+	if err != nil {
+		return "", err
+	}
+	if options.Strict != nil {
+		return *options.Strict, nil
+	}
+	if opts.Strict != "" {
+		return opts.Strict, nil
+	}
+	return models.StrictOff, nil
+}
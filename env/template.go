@@ -0,0 +1,149 @@
+package env
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// isRenderedTemplate returns whether the value is rendered as a template: the IsTemplate values are only rendered
+// with the template expansion, otherwise they are expanded as before (tools like bitrise render them on their own).
+func isRenderedTemplate(options models.EnvironmentItemOptionsModel) bool {
+	return options.IsTemplate != nil && *options.IsTemplate &&
+		options.Expansion != nil && *options.Expansion == models.ExpansionTemplate
+}
+
+// templateFuncs returns the functions available in the templates, envs is the environment evaluated so far.
+func templateFuncs(envs map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"getenv": func(name string) string {
+			return envs[name]
+		},
+		"isset": func(name string) bool {
+			_, ok := envs[name]
+			return ok
+		},
+		"default": func(defaultValue, value string) string {
+			if value == "" {
+				return defaultValue
+			}
+			return value
+		},
+		"required": func(message, value string) (string, error) {
+			if value == "" {
+				return "", errors.New(message)
+			}
+			return value, nil
+		},
+		"trim":  strings.TrimSpace,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"join": func(separator string, values []string) string {
+			return strings.Join(values, separator)
+		},
+		"split": func(separator, value string) []string {
+			return strings.Split(value, separator)
+		},
+		"base64": func(value string) string {
+			return base64.StdEncoding.EncodeToString([]byte(value))
+		},
+		"base64decode": func(value string) (string, error) {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			return string(decoded), err
+		},
+		"sha256": func(value string) string {
+			sum := sha256.Sum256([]byte(value))
+			return hex.EncodeToString(sum[:])
+		},
+	}
+}
+
+// parseTemplate parses the value of the env var (key) as a text/template.
+func parseTemplate(key, value string, envs map[string]string) (*template.Template, error) {
+	return template.New(key).Option("missingkey=zero").Funcs(templateFuncs(envs)).Parse(value)
+}
+
+// renderTemplate renders the value of the env var (key) as a text/template,
+// with the environment evaluated so far as data: {{ .NAME }} is the value of the env var NAME (empty if it's not set).
+func renderTemplate(key, value string, envs map[string]string) (string, error) {
+	tmpl, err := parseTemplate(key, value, envs)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, envs); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+// templateReferences returns the names of the env vars referenced by the template: the {{ .NAME }} fields,
+// and the names passed to getenv and isset as string constants.
+// Invalid templates don't reference any env var, their evaluation fails anyway.
+func templateReferences(value string) []string {
+	tmpl, err := parseTemplate("", value, nil)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, child := range node.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.TemplateNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(node.Args) == 2 {
+				identifier, isIdentifier := node.Args[0].(*parse.IdentifierNode)
+				name, isString := node.Args[1].(*parse.StringNode)
+				if isIdentifier && isString && (identifier.Ident == "getenv" || identifier.Ident == "isset") {
+					names = appendUnique(names, name.Text)
+				}
+			}
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			names = appendUnique(names, node.Ident[0])
+		case *parse.ChainNode:
+			walk(node.Node)
+		}
+	}
+	walk(tmpl.Tree.Root)
+	return names
+}
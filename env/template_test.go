@@ -0,0 +1,52 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	envs := map[string]string{"TOKEN": "secret", "EMPTY": ""}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: `{{ .TOKEN }}`, want: "secret"},
+		{template: `{{ .UNDEFINED }}`, want: ""},
+		{template: `{{ isset "EMPTY" }} {{ isset "UNDEFINED" }}`, want: "true false"},
+		{template: `{{ if .EMPTY }}set{{ else }}empty{{ end }}`, want: "empty"},
+		{template: `{{ .EMPTY | default "fallback" }}`, want: "fallback"},
+		{template: `{{ .TOKEN | base64 }}`, want: "c2VjcmV0"},
+		{template: `{{ "c2VjcmV0" | base64decode }}`, want: "secret"},
+		{template: `{{ .TOKEN | sha256 }}`, want: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"},
+		{template: `{{ range split ":" "a:b" }}[{{ . }}]{{ end }}`, want: "[a][b]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := renderTemplate("KEY", tt.template, envs)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderTemplate_Errors(t *testing.T) {
+	newEnvs := []models.EnvironmentItemModel{
+		{"URL": `{{ .HOST | required "HOST is required" }}`, "opts": map[string]interface{}{"is_template": true, "expansion": "template"}},
+	}
+	_, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `failed to render template of env var (URL): template: URL:1:11: executing "URL" at <required "HOST is required">: error calling required: HOST is required`)
+
+	_, err = renderTemplate("URL", `{{ .HOST `, nil)
+	require.EqualError(t, err, "template: URL:1: unclosed action")
+}
+
+func TestTemplateReferences(t *testing.T) {
+	// the fields within with and range blocks are not necessarily env vars, but they are taken into account
+	require.Equal(t, []string{"A", "B", "C", "D", "E", "Inner"}, templateReferences(`{{ .A }}{{ if isset "B" }}{{ getenv "C" | upper }}{{ else }}{{ (.D).Field }}{{ end }}{{ with .E }}{{ .Inner }}{{ end }}`))
+	require.Equal(t, []string(nil), templateReferences(`{{ .A `))
+}
@@ -0,0 +1,154 @@
+package env
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// applyTransforms applies the transforms on the evaluated value of the env var (key), in order.
+// envs is the environment evaluated so far.
+func applyTransforms(key, value string, transforms []models.TransformModel, envs map[string]string) (string, error) {
+	for _, transform := range transforms {
+		var err error
+		value, err = applyTransform(value, transform, envs)
+		if err != nil {
+			return "", fmt.Errorf("transform (%s) of env var (%s) failed: %s", transform.Name, key, err)
+		}
+	}
+	return value, nil
+}
+
+func applyTransform(value string, transform models.TransformModel, envs map[string]string) (string, error) {
+	switch transform.Name {
+	case models.TransformTrim:
+		return strings.TrimSpace(value), nil
+	case models.TransformTrimNewline:
+		for strings.HasSuffix(value, "\n") {
+			value = strings.TrimSuffix(strings.TrimSuffix(value, "\n"), "\r")
+		}
+		return value, nil
+	case models.TransformBase64Encode:
+		return base64.StdEncoding.EncodeToString([]byte(value)), nil
+	case models.TransformBase64Decode:
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", err
+		}
+		return string(decoded), nil
+	case models.TransformUpper:
+		return strings.ToUpper(value), nil
+	case models.TransformLower:
+		return strings.ToLower(value), nil
+	case models.TransformJSONPath:
+		return extractJSONPath(value, transform.Path)
+	case models.TransformReplace:
+		if transform.Old == "" {
+			return "", fmt.Errorf("transform (%s) requires old", transform.Name)
+		}
+		return strings.ReplaceAll(value, transform.Old, transform.New), nil
+	case models.TransformPath:
+		return normalizePath(value, envs["HOME"]), nil
+	default:
+		return "", fmt.Errorf("unknown transform, supported transforms: %s", strings.Join(models.Transforms, ", "))
+	}
+}
+
+// extractJSONPath returns the field at the path (like .data.items[0].token) of the JSON value.
+// A string field is returned as is, any other field is returned JSON encoded.
+func extractJSONPath(value, path string) (string, error) {
+	var current interface{}
+	if err := json.Unmarshal([]byte(value), &current); err != nil {
+		return "", fmt.Errorf("value is not valid JSON: %s", err)
+	}
+
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	for _, segment := range segments {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			field, ok := node[segment]
+			if !ok {
+				return "", fmt.Errorf("field (%s) not found in path (%s)", segment, path)
+			}
+			current = field
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return "", fmt.Errorf("field (%s) of a list in path (%s)", segment, path)
+			}
+			if index < 0 || index >= len(node) {
+				return "", fmt.Errorf("index (%d) out of range in path (%s)", index, path)
+			}
+			current = node[index]
+		default:
+			return "", fmt.Errorf("field (%s) of a non-object value in path (%s)", segment, path)
+		}
+	}
+
+	if str, ok := current.(string); ok {
+		return str, nil
+	}
+	bytes, err := json.Marshal(current)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// parseJSONPath splits the path (like .data.items[0].token) to its field names and list indexes.
+func parseJSONPath(path string) ([]string, error) {
+	var segments []string
+	for _, part := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if part == "" {
+			if path == "." {
+				break
+			}
+			return nil, fmt.Errorf("invalid path (%s)", path)
+		}
+
+		field := part
+		if bracket := strings.Index(part, "["); bracket >= 0 {
+			field = part[:bracket]
+			part = part[bracket:]
+		} else {
+			part = ""
+		}
+		if field != "" {
+			segments = append(segments, field)
+		}
+
+		for part != "" {
+			end := strings.Index(part, "]")
+			if !strings.HasPrefix(part, "[") || end < 0 {
+				return nil, fmt.Errorf("invalid path (%s)", path)
+			}
+			index := part[1:end]
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, fmt.Errorf("invalid index (%s) in path (%s)", index, path)
+			}
+			segments = append(segments, index)
+			part = part[end+1:]
+		}
+	}
+	return segments, nil
+}
+
+// normalizePath expands the leading ~ to the home directory and cleans the file path.
+func normalizePath(value, home string) string {
+	if value == "" {
+		return value
+	}
+	if home != "" && (value == "~" || strings.HasPrefix(value, "~/")) {
+		value = home + strings.TrimPrefix(value, "~")
+	}
+	return filepath.Clean(value)
+}
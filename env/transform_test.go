@@ -0,0 +1,65 @@
+package env
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		transforms []models.TransformModel
+		want       string
+	}{
+		{name: "no transforms", value: " value ", want: " value "},
+		{name: "trim", value: " \tvalue\n", transforms: []models.TransformModel{{Name: "trim"}}, want: "value"},
+		{name: "trim newline", value: " value\r\n\n", transforms: []models.TransformModel{{Name: "trim_newline"}}, want: " value"},
+		{name: "base64", value: "value", transforms: []models.TransformModel{{Name: "base64_encode"}}, want: "dmFsdWU="},
+		{name: "base64 decode", value: "dmFsdWU=", transforms: []models.TransformModel{{Name: "base64_decode"}}, want: "value"},
+		{name: "upper and lower", value: "Value", transforms: []models.TransformModel{{Name: "upper"}}, want: "VALUE"},
+		{name: "replace", value: "feature/login-page", transforms: []models.TransformModel{{Name: "replace", Old: "/", New: "-"}}, want: "feature-login-page"},
+		{name: "json path string", value: `{"data": {"items": [{"token": "secret"}]}}`, transforms: []models.TransformModel{{Name: "json_path", Path: ".data.items[0].token"}}, want: "secret"},
+		{name: "json path object", value: `{"data": {"ids": [1, 2]}}`, transforms: []models.TransformModel{{Name: "json_path", Path: ".data"}}, want: `{"ids":[1,2]}`},
+		{name: "path", value: "~/projects/../app/", transforms: []models.TransformModel{{Name: "path"}}, want: "/home/user/app"},
+		{name: "in order", value: " ZG1Gc2RXVT0K \n", transforms: []models.TransformModel{{Name: "trim"}, {Name: "base64_decode"}, {Name: "trim_newline"}, {Name: "base64_decode"}, {Name: "upper"}}, want: "VALUE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyTransforms("KEY", tt.value, tt.transforms, map[string]string{"HOME": "/home/user"})
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestApplyTransforms_Errors(t *testing.T) {
+	_, err := applyTransforms("TOKEN", "not base64", []models.TransformModel{{Name: "trim"}, {Name: "base64_decode"}}, nil)
+	require.EqualError(t, err, "transform (base64_decode) of env var (TOKEN) failed: illegal base64 data at input byte 3")
+
+	_, err = applyTransforms("TOKEN", `{"data": {}}`, []models.TransformModel{{Name: "json_path", Path: ".data.token"}}, nil)
+	require.EqualError(t, err, "transform (json_path) of env var (TOKEN) failed: field (token) not found in path (.data.token)")
+
+	_, err = applyTransforms("TOKEN", `{"items": []}`, []models.TransformModel{{Name: "json_path", Path: ".items[1]"}}, nil)
+	require.EqualError(t, err, "transform (json_path) of env var (TOKEN) failed: index (1) out of range in path (.items[1])")
+
+	_, err = applyTransforms("TOKEN", "plain", []models.TransformModel{{Name: "json_path", Path: ".token"}}, nil)
+	require.Error(t, err)
+}
+
+func TestGetDeclarationsSideEffects_Transforms(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"BRANCH": "feature/Login"},
+		models.EnvironmentItemModel{"SLUG": "  $BRANCH  ", "opts": map[string]interface{}{"transforms": []interface{}{
+			"trim",
+			"lower",
+			map[string]interface{}{"name": "replace", "old": "/", "new": "-"},
+		}}},
+	)
+
+	result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{})
+	require.NoError(t, err)
+	require.Equal(t, "feature-login", result.EvaluatedNewEnvs["SLUG"])
+}
@@ -0,0 +1,110 @@
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// EnvVarTypeError is returned if a value doesn't match the type of the env var.
+type EnvVarTypeError struct {
+	Key    string
+	Type   string
+	Reason string
+}
+
+// NewEnvVarTypeError ...
+func NewEnvVarTypeError(key, valueType, reason string) error {
+	return EnvVarTypeError{
+		Key:    key,
+		Type:   valueType,
+		Reason: reason,
+	}
+}
+
+func (err EnvVarTypeError) Error() string {
+	return fmt.Sprintf("env var (%s) is not a valid %s: %s", err.Key, err.Type, err.Reason)
+}
+
+// NormalizeValue validates the value of the env var (key) by the type option,
+// and returns its canonical form (like true for yes). Values without type are returned as is.
+// The returned EnvVarTypeError doesn't contain the value, it may be sensitive.
+func NormalizeValue(key, value string, options models.EnvironmentItemOptionsModel) (string, error) {
+	if options.Type == nil {
+		return value, nil
+	}
+
+	normalized, err := normalizeValue(value, *options.Type, options.ValueOptions)
+	if err != nil {
+		return "", NewEnvVarTypeError(key, *options.Type, err.Error())
+	}
+	return normalized, nil
+}
+
+func normalizeValue(value, valueType string, valueOptions []string) (string, error) {
+	switch valueType {
+	case models.TypeString:
+		return value, nil
+	case models.TypeInt:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return "", errors.New("value is not an integer")
+		}
+		return strconv.FormatInt(i, 10), nil
+	case models.TypeBool:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "yes", "y", "on", "1":
+			return "true", nil
+		case "false", "no", "n", "off", "0":
+			return "false", nil
+		}
+		return "", errors.New("value is not a boolean")
+	case models.TypeFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", errors.New("value is not a number")
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case models.TypeURL:
+		u, err := url.Parse(strings.TrimSpace(value))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return "", errors.New("value is not an absolute URL")
+		}
+		return u.String(), nil
+	case models.TypePath:
+		if value == "" {
+			return "", errors.New("value is empty")
+		}
+		return filepath.Clean(value), nil
+	case models.TypeDuration:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return "", errors.New("value is not a duration")
+		}
+		return d.String(), nil
+	case models.TypeEnum:
+		for _, option := range valueOptions {
+			if value == option {
+				return value, nil
+			}
+		}
+		return "", errors.New("value is not one of: " + strings.Join(valueOptions, ", "))
+	case models.TypeJSON:
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, []byte(value)); err != nil {
+			return "", errors.New("value is not valid JSON")
+		}
+		return compacted.String(), nil
+	default:
+		return "", models.ValidateType(valueType)
+	}
+}
@@ -0,0 +1,79 @@
+package env
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		valueType    string
+		valueOptions []string
+		value        string
+		want         string
+		wantErr      string
+	}{
+		{valueType: "string", value: " any value ", want: " any value "},
+		{valueType: "int", value: " 0042 ", want: "42"},
+		{valueType: "int", value: "4.2", wantErr: "env var (KEY) is not a valid int: value is not an integer"},
+		{valueType: "bool", value: "yes", want: "true"},
+		{valueType: "bool", value: "OFF", want: "false"},
+		{valueType: "bool", value: "maybe", wantErr: "env var (KEY) is not a valid bool: value is not a boolean"},
+		{valueType: "float", value: "1.50", want: "1.5"},
+		{valueType: "float", value: "NaN", wantErr: "env var (KEY) is not a valid float: value is not a number"},
+		{valueType: "url", value: "https://example.com/api?v=1", want: "https://example.com/api?v=1"},
+		{valueType: "url", value: "example.com/api", wantErr: "env var (KEY) is not a valid url: value is not an absolute URL"},
+		{valueType: "path", value: "./build/../out/", want: "out"},
+		{valueType: "path", value: "", wantErr: "env var (KEY) is not a valid path: value is empty"},
+		{valueType: "duration", value: "90s", want: "1m30s"},
+		{valueType: "duration", value: "90", wantErr: "env var (KEY) is not a valid duration: value is not a duration"},
+		{valueType: "enum", valueOptions: []string{"debug", "release"}, value: "release", want: "release"},
+		{valueType: "enum", valueOptions: []string{"debug", "release"}, value: "Release", wantErr: "env var (KEY) is not a valid enum: value is not one of: debug, release"},
+		{valueType: "json", value: "{\n  \"a\": [1, 2]\n}", want: `{"a":[1,2]}`},
+		{valueType: "json", value: "{", wantErr: "env var (KEY) is not a valid json: value is not valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.valueType+": "+tt.value, func(t *testing.T) {
+			options := models.EnvironmentItemOptionsModel{Type: pointers.NewStringPtr(tt.valueType), ValueOptions: tt.valueOptions}
+			got, err := NormalizeValue("KEY", tt.value, options)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				var typeErr EnvVarTypeError
+				require.True(t, errors.As(err, &typeErr))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	got, err := NormalizeValue("KEY", "value", models.EnvironmentItemOptionsModel{})
+	require.NoError(t, err)
+	require.Equal(t, "value", got)
+}
+
+func TestGetDeclarationsSideEffects_Types(t *testing.T) {
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"PORT": "8080"},
+		models.EnvironmentItemModel{"DEBUG": "on", "opts": map[string]interface{}{"type": "bool"}},
+		models.EnvironmentItemModel{"ADDRESS_PORT": "$PORT", "opts": map[string]interface{}{"type": "int"}},
+	)
+
+	result, err := GetDeclarationsSideEffects(newEnvs, TestEnvSource{})
+	require.NoError(t, err)
+	require.Equal(t, "true", result.EvaluatedNewEnvs["DEBUG"])
+	require.Equal(t, "8080", result.EvaluatedNewEnvs["ADDRESS_PORT"])
+
+	newEnvs = graphTestEnvs(t,
+		models.EnvironmentItemModel{"TIMEOUT": "${UNDEFINED}s", "opts": map[string]interface{}{"type": "duration"}},
+	)
+
+	_, err = GetDeclarationsSideEffects(newEnvs, TestEnvSource{})
+	var typeErr EnvVarTypeError
+	require.True(t, errors.As(err, &typeErr))
+	require.Equal(t, EnvVarTypeError{Key: "TIMEOUT", Type: "duration", Reason: "value is not a duration"}, typeErr)
+}
@@ -0,0 +1,118 @@
+package env
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// ValueSourceOptions configure the resolution of the value sources (the value_from option of the envs).
+type ValueSourceOptions struct {
+	// AllowCommands enables the command value sources, they fail the evaluation otherwise
+	AllowCommands bool
+	// LookupEnvstore returns the evaluated value of the key in the envstore (path or location),
+	// and whether the envstore declares the key. The envstore value sources fail the evaluation if it's not set.
+	LookupEnvstore func(envstore, key string) (string, bool, error)
+	// ValueBytesLimit is the max size of a resolved value, 0 means no limit
+	ValueBytesLimit int
+	// ListBytesLimit is the max total size of the resolved values, 0 means no limit
+	ListBytesLimit int
+}
+
+// resolveValueSource returns the value of the env var (key) from its source.
+// The commands run with the environment evaluated so far (envs).
+func resolveValueSource(key string, source models.ValueSourceModel, envs map[string]string, opts ValueSourceOptions) (string, error) {
+	if err := source.Validate(); err != nil {
+		return "", err
+	}
+
+	var value string
+	switch {
+	case source.File != "":
+		content, err := os.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value file: %s", err)
+		}
+		value = string(content)
+	case source.Envstore != "":
+		if opts.LookupEnvstore == nil {
+			return "", errors.New("envstore value sources are not supported")
+		}
+		var found bool
+		var err error
+		value, found, err = opts.LookupEnvstore(source.Envstore, source.Key)
+		if err != nil {
+			return "", fmt.Errorf("failed to read envstore (%s): %s", source.Envstore, err)
+		}
+		if !found {
+			return "", fmt.Errorf("env var (%s) is not declared in envstore (%s)", source.Key, source.Envstore)
+		}
+	case source.Command != "":
+		if !opts.AllowCommands {
+			return "", errors.New("command value sources are not allowed")
+		}
+		var err error
+		if value, err = runValueCommand(source, envs); err != nil {
+			return "", err
+		}
+	}
+
+	if opts.ValueBytesLimit > 0 && len(value) > opts.ValueBytesLimit {
+		return "", NewEnvVarValueTooLargeError(key, float64(len(value))/1024, float64(opts.ValueBytesLimit)/1024)
+	}
+	return value, nil
+}
+
+func runValueCommand(source models.ValueSourceModel, envs map[string]string) (string, error) {
+	timeout := time.Duration(source.TimeoutInSecs) * time.Second
+	if timeout == 0 {
+		timeout = models.DefaultValueSourceTimeoutInSecs * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", source.Command)
+	cmd.Env = make([]string, 0, len(envs))
+	for key, value := range envs {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// the children of the shell may keep the output open after the shell is killed
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("command (%s) timed out after %s", source.Command, timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("command (%s) failed: %s: %s", source.Command, err, message)
+		}
+		return "", fmt.Errorf("command (%s) failed: %s", source.Command, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// resolvedValuesSize returns the total size of the values resolved from value sources,
+// the other values are checked when they are added to the envstore.
+func resolvedValuesSize(newEnvs []models.EnvironmentItemModel, commands []Command) (int, error) {
+	size := 0
+	for i, env := range newEnvs {
+		options, err := env.GetOptions()
+		if err != nil {
+			return 0, err
+		}
+		if options.ValueFrom != nil {
+			size += len(commands[i].Variable.Value)
+		}
+	}
+	return size, nil
+}
@@ -0,0 +1,132 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDeclarationsSideEffects_ValueSources(t *testing.T) {
+	tmpDir := t.TempDir()
+	certPth := filepath.Join(tmpDir, "cert.pem")
+	require.NoError(t, os.WriteFile(certPth, []byte("-----BEGIN CERTIFICATE-----\n$NOT_EXPANDED\n"), 0644))
+	emptyPth := filepath.Join(tmpDir, "empty.txt")
+	require.NoError(t, os.WriteFile(emptyPth, []byte(""), 0644))
+
+	lookup := func(envstore, key string) (string, bool, error) {
+		if envstore == "shared.yml" && key == "TOKEN" {
+			return "shared-secret", true, nil
+		}
+		return "", false, nil
+	}
+
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"CERT": "", "opts": map[string]interface{}{"value_from": map[interface{}]interface{}{"file": certPth}}},
+		models.EnvironmentItemModel{"TOKEN": "", "opts": map[string]interface{}{
+			"value_from":   map[string]interface{}{"envstore": "shared.yml", "key": "TOKEN"},
+			"is_sensitive": true,
+		}},
+		models.EnvironmentItemModel{"BRANCH": "main"},
+		models.EnvironmentItemModel{"GREETING": "", "opts": map[string]interface{}{"value_from": map[string]interface{}{"command": "echo \"  hello $BRANCH  \""}}},
+		models.EnvironmentItemModel{"OPTIONAL": "", "opts": map[string]interface{}{"value_from": map[string]interface{}{"file": emptyPth}, "skip_if_empty": true}},
+	)
+	opts := DeclarationOptions{ValueSources: ValueSourceOptions{AllowCommands: true, LookupEnvstore: lookup}}
+
+	result, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, opts)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"CERT":     "-----BEGIN CERTIFICATE-----\n$NOT_EXPANDED\n",
+		"TOKEN":    "shared-secret",
+		"BRANCH":   "main",
+		"GREETING": "hello main",
+	}, result.EvaluatedNewEnvs)
+	require.Equal(t, SkipAction, result.CommandHistory[4].Action)
+	require.True(t, result.Provenance["TOKEN"][0].Sensitive)
+	require.Equal(t, &models.ValueSourceModel{File: certPth}, result.Provenance["CERT"][0].ValueSource)
+}
+
+func TestGetDeclarationsSideEffects_ValueSourceErrors(t *testing.T) {
+	valueFrom := func(source map[string]interface{}) []models.EnvironmentItemModel {
+		return graphTestEnvs(t, models.EnvironmentItemModel{"VALUE": "", "opts": map[string]interface{}{"value_from": source}})
+	}
+
+	tests := []struct {
+		name    string
+		envs    []models.EnvironmentItemModel
+		opts    ValueSourceOptions
+		wantErr string
+	}{
+		{
+			name:    "commands are opt-in",
+			envs:    valueFrom(map[string]interface{}{"command": "echo value"}),
+			wantErr: "failed to resolve value of env var (VALUE): command value sources are not allowed",
+		},
+		{
+			name:    "command timeout",
+			envs:    valueFrom(map[string]interface{}{"command": "sleep 5", "timeout_in_secs": 1}),
+			opts:    ValueSourceOptions{AllowCommands: true},
+			wantErr: "failed to resolve value of env var (VALUE): command (sleep 5) timed out after 1s",
+		},
+		{
+			name:    "failing command",
+			envs:    valueFrom(map[string]interface{}{"command": "echo oops >&2; exit 3"}),
+			opts:    ValueSourceOptions{AllowCommands: true},
+			wantErr: "failed to resolve value of env var (VALUE): command (echo oops >&2; exit 3) failed: exit status 3: oops",
+		},
+		{
+			name:    "envstore lookup is not set",
+			envs:    valueFrom(map[string]interface{}{"envstore": "shared.yml", "key": "TOKEN"}),
+			wantErr: "failed to resolve value of env var (VALUE): envstore value sources are not supported",
+		},
+		{
+			name: "undeclared envstore key",
+			envs: valueFrom(map[string]interface{}{"envstore": "shared.yml", "key": "TOKEN"}),
+			opts: ValueSourceOptions{LookupEnvstore: func(string, string) (string, bool, error) {
+				return "", false, nil
+			}},
+			wantErr: "failed to resolve value of env var (VALUE): env var (TOKEN) is not declared in envstore (shared.yml)",
+		},
+		{
+			name:    "invalid source",
+			envs:    valueFrom(map[string]interface{}{"file": "value.txt", "command": "echo value"}),
+			wantErr: "failed to resolve value of env var (VALUE): value_from should set exactly one of file, envstore or command",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GetDeclarationsSideEffectsWithOptions(tt.envs, TestEnvSource{}, DeclarationOptions{ValueSources: tt.opts})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestGetDeclarationsSideEffects_ValueSourceLimits(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "value.txt")
+	require.NoError(t, os.WriteFile(pth, make([]byte, 2048), 0644))
+	newEnvs := graphTestEnvs(t,
+		models.EnvironmentItemModel{"LITERAL": "value"},
+		models.EnvironmentItemModel{"VALUE": "", "opts": map[string]interface{}{"value_from": map[string]interface{}{"file": pth}}},
+	)
+
+	_, err := GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{ValueSources: ValueSourceOptions{ValueBytesLimit: 1024}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "env var (VALUE) value is too large (2 KB), max allowed size: 1 KB")
+
+	_, err = GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{ValueSources: ValueSourceOptions{ListBytesLimit: 2047}})
+	var listErr EnvVarListTooLargeError
+	require.True(t, errors.As(err, &listErr))
+	require.Equal(t, float64(2048)/1024, listErr.EnvListSizeInKB)
+
+	// only the resolved values are counted
+	_, err = GetDeclarationsSideEffectsWithOptions(newEnvs, TestEnvSource{}, DeclarationOptions{ValueSources: ValueSourceOptions{ListBytesLimit: 2048}})
+	require.NoError(t, err)
+
+	literalEnvs := graphTestEnvs(t, models.EnvironmentItemModel{"LITERAL": string(make([]byte, 4096))})
+	_, err = GetDeclarationsSideEffectsWithOptions(literalEnvs, TestEnvSource{}, DeclarationOptions{ValueSources: ValueSourceOptions{ValueBytesLimit: 1024, ListBytesLimit: 1024}})
+	require.NoError(t, err)
+}
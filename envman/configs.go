@@ -13,12 +13,18 @@ const (
 	envmanConfigFileName         = "configs.json"
 	defaultEnvBytesLimitInKB     = 256
 	defaultEnvListBytesLimitInKB = 256
+	defaultLockTimeoutInSecs     = 30
+	defaultHistoryLimit          = 50
 )
 
 // ConfigsModel ...
 type ConfigsModel struct {
 	EnvBytesLimitInKB     int `json:"env_bytes_limit_in_kb,omitempty"`
 	EnvListBytesLimitInKB int `json:"env_list_bytes_limit_in_kb,omitempty"`
+	// LockTimeoutInSecs is how long envman waits for another process to release the envstore lock
+	LockTimeoutInSecs int `json:"lock_timeout_in_secs,omitempty"`
+	// HistoryLimit is the number of revisions kept in the history of an envstore, 0 disables the history
+	HistoryLimit int `json:"history_limit"`
 }
 
 func getEnvmanConfigsDirPath() string {
@@ -44,6 +50,8 @@ func createDefaultConfigsModel() ConfigsModel {
 	return ConfigsModel{
 		EnvBytesLimitInKB:     defaultEnvBytesLimitInKB,
 		EnvListBytesLimitInKB: defaultEnvListBytesLimitInKB,
+		LockTimeoutInSecs:     defaultLockTimeoutInSecs,
+		HistoryLimit:          defaultHistoryLimit,
 	}
 }
 
@@ -66,6 +74,8 @@ func GetConfigs() (ConfigsModel, error) {
 	type ConfigsFileMode struct {
 		EnvBytesLimitInKB     *int `json:"env_bytes_limit_in_kb,omitempty"`
 		EnvListBytesLimitInKB *int `json:"env_list_bytes_limit_in_kb,omitempty"`
+		LockTimeoutInSecs     *int `json:"lock_timeout_in_secs,omitempty"`
+		HistoryLimit          *int `json:"history_limit,omitempty"`
 	}
 
 	var userConfigs ConfigsFileMode
@@ -79,6 +89,12 @@ func GetConfigs() (ConfigsModel, error) {
 	if userConfigs.EnvListBytesLimitInKB != nil {
 		defaultConfigs.EnvListBytesLimitInKB = *userConfigs.EnvListBytesLimitInKB
 	}
+	if userConfigs.LockTimeoutInSecs != nil {
+		defaultConfigs.LockTimeoutInSecs = *userConfigs.LockTimeoutInSecs
+	}
+	if userConfigs.HistoryLimit != nil {
+		defaultConfigs.HistoryLimit = *userConfigs.HistoryLimit
+	}
 
 	return defaultConfigs, nil
 }
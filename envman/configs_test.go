@@ -30,10 +30,15 @@ func TestGetConfigs(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, defaultEnvBytesLimitInKB, baseConf.EnvBytesLimitInKB)
 	require.Equal(t, defaultEnvListBytesLimitInKB, baseConf.EnvListBytesLimitInKB)
+	require.Equal(t, defaultLockTimeoutInSecs, baseConf.LockTimeoutInSecs)
+	require.Equal(t, defaultHistoryLimit, baseConf.HistoryLimit)
 
 	// modify it
 	baseConf.EnvBytesLimitInKB = 123
 	baseConf.EnvListBytesLimitInKB = 321
+	baseConf.LockTimeoutInSecs = 5
+	// 0 disables the history, it is not replaced by the default
+	baseConf.HistoryLimit = 0
 
 	// save to file
 	require.NoError(t, saveConfigs(baseConf))
@@ -45,6 +50,8 @@ func TestGetConfigs(t *testing.T) {
 	require.Equal(t, configs, baseConf)
 	require.Equal(t, 123, configs.EnvBytesLimitInKB)
 	require.Equal(t, 321, configs.EnvListBytesLimitInKB)
+	require.Equal(t, 5, configs.LockTimeoutInSecs)
+	require.Equal(t, 0, configs.HistoryLimit)
 
 	// delete the tmp config file
 	require.NoError(t, os.Remove(configPth))
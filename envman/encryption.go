@@ -0,0 +1,67 @@
+package envman
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/encryption"
+)
+
+const (
+	// EncryptionKeyEnvKey can hold the encryption identities (one per line), it takes precedence over the key file.
+	EncryptionKeyEnvKey = "ENVMAN_ENCRYPTION_KEY"
+	// EncryptionKeyFileEnvKey overrides the path of the encryption key file.
+	EncryptionKeyFileEnvKey = "ENVMAN_ENCRYPTION_KEY_FILE"
+
+	encryptionKeyFileName = "encryption.key"
+)
+
+// GetEncryptionKeyFilePath returns the path of the file holding the encryption identities.
+func GetEncryptionKeyFilePath() string {
+	if pth := os.Getenv(EncryptionKeyFileEnvKey); pth != "" {
+		return pth
+	}
+	return path.Join(getEnvmanConfigsDirPath(), encryptionKeyFileName)
+}
+
+// IsEncryptionKeyFromEnv returns true if the encryption identities are set by the ENVMAN_ENCRYPTION_KEY env var.
+func IsEncryptionKeyFromEnv() bool {
+	return strings.TrimSpace(os.Getenv(EncryptionKeyEnvKey)) != ""
+}
+
+// GetEncryptionIdentities returns the configured encryption identities, the first one is the primary identity.
+// Returns an empty list if no identity is configured.
+func GetEncryptionIdentities() ([]encryption.Identity, error) {
+	if IsEncryptionKeyFromEnv() {
+		return encryption.ParseIdentities(os.Getenv(EncryptionKeyEnvKey))
+	}
+
+	content, err := os.ReadFile(GetEncryptionKeyFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return encryption.ParseIdentities(string(content))
+}
+
+// SaveEncryptionIdentities writes the identities into the encryption key file.
+func SaveEncryptionIdentities(identities []encryption.Identity) error {
+	keyFilePth := GetEncryptionKeyFilePath()
+	if err := os.MkdirAll(path.Dir(keyFilePth), 0700); err != nil {
+		return err
+	}
+
+	var content strings.Builder
+	content.WriteString("# envman encryption identities, the first one is used for encryption\n")
+	for _, identity := range identities {
+		content.WriteString(identity.String() + "\n")
+	}
+
+	tmpPth := keyFilePth + ".tmp"
+	if err := os.WriteFile(tmpPth, []byte(content.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPth, keyFilePth)
+}
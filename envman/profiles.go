@@ -0,0 +1,235 @@
+package envman
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	profilesDirName       = "profiles"
+	profilesStateFileName = "profiles.json"
+	profileEnvstoreExt    = ".yml"
+)
+
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ProfilesStateModel stores the active profiles.
+type ProfilesStateModel struct {
+	Global      string            `json:"global,omitempty"`
+	Directories map[string]string `json:"directories,omitempty"`
+}
+
+func getProfilesDirPath() string {
+	return path.Join(getEnvmanConfigsDirPath(), profilesDirName)
+}
+
+func getProfilesStateFilePath() string {
+	return path.Join(getEnvmanConfigsDirPath(), profilesStateFileName)
+}
+
+// ValidateProfileName ...
+func ValidateProfileName(name string) error {
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid profile name (%s): only letters, digits, '.', '_' and '-' are allowed", name)
+	}
+	return nil
+}
+
+// GetProfileEnvstorePath returns the path of the profile's envstore.
+func GetProfileEnvstorePath(name string) string {
+	return path.Join(getProfilesDirPath(), name+profileEnvstoreExt)
+}
+
+// IsProfileExists ...
+func IsProfileExists(name string) (bool, error) {
+	if err := ValidateProfileName(name); err != nil {
+		return false, err
+	}
+
+	_, err := os.Stat(GetProfileEnvstorePath(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// EnsureProfilesDirExists ...
+func EnsureProfilesDirExists() error {
+	return os.MkdirAll(getProfilesDirPath(), 0755)
+}
+
+// ListProfiles returns the names of the profiles, sorted.
+func ListProfiles() ([]string, error) {
+	entries, err := os.ReadDir(getProfilesDirPath())
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != profileEnvstoreExt {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), profileEnvstoreExt)
+		if ValidateProfileName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteProfile removes the profile's envstore and deactivates the profile wherever it is active.
+func DeleteProfile(name string) error {
+	if exists, err := IsProfileExists(name); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("profile (%s) does not exist", name)
+	}
+
+	envstorePth := GetProfileEnvstorePath(name)
+	if err := os.Remove(envstorePth); err != nil {
+		return err
+	}
+	if err := os.Remove(envstorePth + ".lock"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	state, err := readProfilesState()
+	if err != nil {
+		return err
+	}
+	if state.Global == name {
+		state.Global = ""
+	}
+	for dir, profile := range state.Directories {
+		if profile == name {
+			delete(state.Directories, dir)
+		}
+	}
+	return saveProfilesState(state)
+}
+
+// CopyProfile creates the destination profile with the content of the source profile's envstore.
+func CopyProfile(source, destination string) error {
+	if exists, err := IsProfileExists(source); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("profile (%s) does not exist", source)
+	}
+	if exists, err := IsProfileExists(destination); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("profile (%s) already exists", destination)
+	}
+
+	content, err := os.ReadFile(GetProfileEnvstorePath(source))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(GetProfileEnvstorePath(destination), content, 0644)
+}
+
+// SetActiveProfile activates the profile for the working directory (and its subdirectories),
+// or globally if workDir is empty. An empty profile name deactivates the profile.
+func SetActiveProfile(name, workDir string) error {
+	if name != "" {
+		if exists, err := IsProfileExists(name); err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("profile (%s) does not exist", name)
+		}
+	}
+
+	state, err := readProfilesState()
+	if err != nil {
+		return err
+	}
+
+	if workDir == "" {
+		state.Global = name
+	} else {
+		if state.Directories == nil {
+			state.Directories = map[string]string{}
+		}
+		if name == "" {
+			delete(state.Directories, workDir)
+		} else {
+			state.Directories[workDir] = name
+		}
+	}
+
+	return saveProfilesState(state)
+}
+
+// GetActiveProfile returns the profile activated for the working directory (or its closest parent directory),
+// or the globally activated profile. Returns an empty string if no profile is active.
+func GetActiveProfile(workDir string) (string, error) {
+	state, err := readProfilesState()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Clean(workDir)
+	for dir != "" {
+		if profile, ok := state.Directories[dir]; ok {
+			return profile, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return state.Global, nil
+}
+
+func readProfilesState() (ProfilesStateModel, error) {
+	content, err := os.ReadFile(getProfilesStateFilePath())
+	if os.IsNotExist(err) {
+		return ProfilesStateModel{}, nil
+	} else if err != nil {
+		return ProfilesStateModel{}, err
+	}
+
+	var state ProfilesStateModel
+	if err := json.Unmarshal(content, &state); err != nil {
+		return ProfilesStateModel{}, fmt.Errorf("failed to parse %s: %s", getProfilesStateFilePath(), err)
+	}
+	return state, nil
+}
+
+func saveProfilesState(state ProfilesStateModel) error {
+	if err := ensureEnvmanConfigDirExists(); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	statePth := getProfilesStateFilePath()
+	tmpPth := statePth + ".tmp"
+	if err := os.WriteFile(tmpPth, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPth, statePth); err != nil {
+		_ = os.Remove(tmpPth)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package envman
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	names, err := ListProfiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{}, names)
+
+	require.NoError(t, EnsureProfilesDirExists())
+	require.NoError(t, os.WriteFile(GetProfileEnvstorePath("staging"), []byte("envs: []\n"), 0644))
+	require.NoError(t, os.WriteFile(GetProfileEnvstorePath("production"), []byte("envs: []\n"), 0644))
+
+	names, err = ListProfiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{"production", "staging"}, names)
+
+	t.Log("copy")
+	{
+		require.NoError(t, CopyProfile("staging", "dev"))
+		require.EqualError(t, CopyProfile("staging", "dev"), "profile (dev) already exists")
+		require.EqualError(t, CopyProfile("missing", "dev2"), "profile (missing) does not exist")
+		require.EqualError(t, CopyProfile("staging", "../dev"), "invalid profile name (../dev): only letters, digits, '.', '_' and '-' are allowed")
+	}
+
+	t.Log("active profile")
+	{
+		projectDir := filepath.Join(t.TempDir(), "project")
+
+		profile, err := GetActiveProfile(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, "", profile)
+
+		require.NoError(t, SetActiveProfile("production", ""))
+		require.NoError(t, SetActiveProfile("staging", projectDir))
+		require.EqualError(t, SetActiveProfile("missing", projectDir), "profile (missing) does not exist")
+
+		profile, err = GetActiveProfile(filepath.Join(projectDir, "subdir"))
+		require.NoError(t, err)
+		require.Equal(t, "staging", profile)
+
+		profile, err = GetActiveProfile(t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, "production", profile)
+
+		require.NoError(t, SetActiveProfile("", projectDir))
+		profile, err = GetActiveProfile(projectDir)
+		require.NoError(t, err)
+		require.Equal(t, "production", profile)
+	}
+
+	t.Log("delete")
+	{
+		require.NoError(t, DeleteProfile("production"))
+
+		names, err = ListProfiles()
+		require.NoError(t, err)
+		require.Equal(t, []string{"dev", "staging"}, names)
+
+		profile, err := GetActiveProfile(t.TempDir())
+		require.NoError(t, err)
+		require.Equal(t, "", profile)
+
+		require.EqualError(t, DeleteProfile("production"), "profile (production) does not exist")
+	}
+}
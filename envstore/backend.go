@@ -0,0 +1,106 @@
+package envstore
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+const (
+	// FileScheme selects the FileBackend, locations without a scheme are files as well
+	FileScheme = "file"
+	// MemoryScheme selects a named MemoryBackend
+	MemoryScheme = "mem"
+	// DirScheme selects the DirBackend
+	DirScheme = "dir"
+)
+
+const schemeSeparator = "://"
+
+// Backend stores an envstore, every load, save and lock of an Envstore goes through its backend.
+// The envs passed to and returned by a backend are already migrated and encrypted,
+// backends only need to persist them.
+type Backend interface {
+	// String returns the location of the envstore, used in messages
+	String() string
+	// Exists reports whether the envstore is initialized
+	Exists(ctx context.Context) (bool, error)
+	// Load returns the envstore as it is stored (without resolving its includes or decrypting its values)
+	Load(ctx context.Context) (models.EnvsSerializeModel, error)
+	// Save replaces the stored envstore
+	Save(ctx context.Context, envstore models.EnvsSerializeModel) error
+	// Remove deletes the envstore, it's not an error if it doesn't exist
+	Remove(ctx context.Context) error
+	// Lock acquires the envstore's lock, waiting at most timeout for it
+	Lock(ctx context.Context, timeout time.Duration) (unlock func() error, err error)
+}
+
+// OpenBackend returns the backend of the location:
+// file://PATH (or just PATH), dir://PATH or mem://NAME.
+func OpenBackend(location string) (Backend, error) {
+	scheme, pth, found := strings.Cut(location, schemeSeparator)
+	if !found {
+		scheme, pth = FileScheme, location
+	}
+	if pth == "" {
+		return nil, fmt.Errorf("no path provided in envstore location: %s", location)
+	}
+
+	switch scheme {
+	case FileScheme:
+		return NewFileBackend(pth), nil
+	case DirScheme:
+		return NewDirBackend(pth), nil
+	case MemoryScheme:
+		return NamedMemoryBackend(pth), nil
+	default:
+		return nil, fmt.Errorf("unknown envstore location scheme (%s), supported schemes: %s, %s, %s", scheme, FileScheme, DirScheme, MemoryScheme)
+	}
+}
+
+// SplitLocationList splits a list of envstore locations, separated by the OS specific path list separator.
+// The scheme separator of the locations (like file://) is not treated as a list separator.
+func SplitLocationList(list string) []string {
+	var locations []string
+	for _, item := range filepath.SplitList(list) {
+		if len(locations) > 0 && strings.HasPrefix(item, "//") {
+			last := locations[len(locations)-1]
+			switch last {
+			case FileScheme, DirScheme, MemoryScheme:
+				locations[len(locations)-1] = last + ":" + item
+				continue
+			}
+		}
+		locations = append(locations, item)
+	}
+	return locations
+}
+
+// includeRoot returns the directory, which the includes of the backend's envstore are relative to,
+// and the path of the envstore (if it's a file) for the include cycle detection.
+func includeRoot(backend Backend) (string, []string, error) {
+	switch b := backend.(type) {
+	case *FileBackend:
+		pth, err := filepath.Abs(b.pth)
+		if err != nil {
+			return "", nil, err
+		}
+		return filepath.Dir(pth), []string{pth}, nil
+	case *DirBackend:
+		dir, err := filepath.Abs(b.dir)
+		if err != nil {
+			return "", nil, err
+		}
+		return dir, nil, nil
+	default:
+		dir, err := filepath.Abs(".")
+		if err != nil {
+			return "", nil, err
+		}
+		return dir, nil, nil
+	}
+}
@@ -0,0 +1,143 @@
+package envstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenBackend(t *testing.T) {
+	backend, err := OpenBackend("envs/.envstore.yml")
+	require.NoError(t, err)
+	require.Equal(t, NewFileBackend("envs/.envstore.yml"), backend)
+
+	backend, err = OpenBackend("file:///tmp/.envstore.yml")
+	require.NoError(t, err)
+	require.Equal(t, NewFileBackend("/tmp/.envstore.yml"), backend)
+
+	backend, err = OpenBackend("dir://envs/")
+	require.NoError(t, err)
+	require.Equal(t, NewDirBackend("envs"), backend)
+
+	backend, err = OpenBackend("mem://test")
+	require.NoError(t, err)
+	require.True(t, backend == NamedMemoryBackend("test"))
+
+	_, err = OpenBackend("s3://bucket/envstore.yml")
+	require.EqualError(t, err, "unknown envstore location scheme (s3), supported schemes: file, dir, mem")
+
+	_, err = OpenBackend("dir://")
+	require.EqualError(t, err, "no path provided in envstore location: dir://")
+}
+
+func TestSplitLocationList(t *testing.T) {
+	require.Equal(t, []string{"a.yml", "b.yml"}, SplitLocationList("a.yml:b.yml"))
+	require.Equal(t, []string{"file:///a.yml", "dir://envs", "mem://test", "b.yml"}, SplitLocationList("file:///a.yml:dir://envs:mem://test:b.yml"))
+}
+
+func TestBackends(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	backends := map[string]Backend{
+		"file":   NewFileBackend(filepath.Join(tmpDir, ".envstore.yml")),
+		"dir":    NewDirBackend(filepath.Join(tmpDir, "envs")),
+		"memory": NewMemoryBackend(),
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := NewWithBackend(backend, Options{EnvSource: testEnvSource{}})
+
+			exists, err := backend.Exists(ctx)
+			require.NoError(t, err)
+			require.False(t, exists)
+
+			require.NoError(t, store.Init(ctx, InitOptions{}))
+			require.Error(t, store.Init(ctx, InitOptions{}))
+
+			require.NoError(t, store.Add(ctx, "A", "first", AddOptions{}))
+			require.NoError(t, store.Add(ctx, "B", "$A second", AddOptions{}))
+			require.NoError(t, store.Add(ctx, "A", "third", AddOptions{Append: true}))
+
+			envs, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []string{"A", "B", "A"}, envKeys(t, envs))
+
+			value, found, err := store.Get(ctx, "B")
+			require.NoError(t, err)
+			require.True(t, found)
+			require.Equal(t, "first second", value)
+
+			require.NoError(t, store.Remove(ctx, "A"))
+			envs, err = store.List(ctx)
+			require.NoError(t, err)
+			require.Equal(t, []string{"B"}, envKeys(t, envs))
+
+			require.NoError(t, store.Clear(ctx))
+			envs, err = store.List(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 0, len(envs))
+		})
+	}
+}
+
+func TestDirBackend(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "envs")
+	store := NewWithBackend(NewDirBackend(dir), Options{})
+	require.NoError(t, store.Init(ctx, InitOptions{}))
+
+	require.NoError(t, store.Add(ctx, "GREETING", "hello", AddOptions{}))
+	require.NoError(t, store.Add(ctx, "BIG", "multi\nline", AddOptions{}))
+	require.NoError(t, store.Add(ctx, "GREETING", "hi", AddOptions{Append: true}))
+
+	meta, err := os.ReadFile(filepath.Join(dir, ".envstore.yml"))
+	require.NoError(t, err)
+	require.Equal(t, `format_version: 1
+keys:
+- GREETING
+- BIG
+- GREETING
+`, string(meta))
+
+	content, err := os.ReadFile(filepath.Join(dir, "BIG.yml"))
+	require.NoError(t, err)
+	require.Equal(t, "- BIG: |-\n    multi\n    line\n", string(content))
+
+	content, err = os.ReadFile(filepath.Join(dir, "GREETING.yml"))
+	require.NoError(t, err)
+	require.Equal(t, "- GREETING: hello\n- GREETING: hi\n", string(content))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not an env"), 0644))
+	require.NoError(t, store.Remove(ctx, "BIG"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	require.Equal(t, []string{".envstore.yml", "GREETING.yml", "README.md"}, names)
+
+	require.EqualError(t, store.Add(ctx, "../A", "B", AddOptions{}), "env var key (../A) can't be stored in a directory envstore")
+}
+
+func TestMemoryBackend_Lock(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+
+	unlock, err := backend.Lock(ctx, time.Second)
+	require.NoError(t, err)
+
+	_, err = backend.Lock(ctx, 50*time.Millisecond)
+	require.Equal(t, NewLockedError("mem://", 0, 50*time.Millisecond), err)
+
+	require.NoError(t, unlock())
+	unlock, err = backend.Lock(ctx, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, unlock())
+}
@@ -0,0 +1,79 @@
+package envstore
+
+import (
+	"fmt"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	log "github.com/sirupsen/logrus"
+)
+
+// validateDefaults returns an error if the envstore defaults contain an unsupported option value.
+func validateDefaults(defaults *models.EnvstoreDefaultsModel) error {
+	if defaults == nil {
+		return nil
+	}
+	if defaults.Expansion != "" {
+		if err := models.ValidateExpansion(defaults.Expansion); err != nil {
+			return fmt.Errorf("invalid envstore defaults: %s", err)
+		}
+	}
+	if defaults.Strict != "" {
+		if err := models.ValidateStrict(defaults.Strict); err != nil {
+			return fmt.Errorf("invalid envstore defaults: %s", err)
+		}
+	}
+	if defaults.Resolution != "" {
+		if err := models.ValidateResolution(defaults.Resolution); err != nil {
+			return fmt.Errorf("invalid envstore defaults: %s", err)
+		}
+	}
+	return nil
+}
+
+// applyDefaults sets the envstore defaults on the options of the envstore's own envs, which don't set them.
+// The envs are modified in place, so it's only called on the envs loaded for evaluation, never on the envs to save.
+func applyDefaults(envstore models.EnvsSerializeModel) ([]models.EnvironmentItemModel, error) {
+	if envstore.Defaults == nil {
+		return envstore.Envs, nil
+	}
+	if err := validateDefaults(envstore.Defaults); err != nil {
+		return nil, err
+	}
+
+	for _, env := range envstore.Envs {
+		opts, err := env.GetOptions()
+		if err != nil {
+			return nil, err
+		}
+		if opts.Expansion == nil && envstore.Defaults.Expansion != "" {
+			opts.Expansion = pointers.NewStringPtr(envstore.Defaults.Expansion)
+		}
+		if opts.Strict == nil && envstore.Defaults.Strict != "" {
+			opts.Strict = pointers.NewStringPtr(envstore.Defaults.Strict)
+		}
+		env[models.OptionsKey] = opts
+	}
+	return envstore.Envs, nil
+}
+
+// warnUnrenderedTemplates warns about the envs (with the envstore defaults applied), which set is_template without the template expansion:
+// envman only renders the templates with the template expansion, these values are expanded like any other value.
+func warnUnrenderedTemplates(envs []models.EnvironmentItemModel) error {
+	for _, env := range envs {
+		key, _, err := env.GetKeyValuePair()
+		if err != nil {
+			return err
+		}
+		opts, err := env.GetOptions()
+		if err != nil {
+			return err
+		}
+		if opts.IsTemplate == nil || !*opts.IsTemplate || (opts.Expansion != nil && *opts.Expansion == models.ExpansionTemplate) {
+			continue
+		}
+		log.Warnf("Env var (%s) is a template (is_template), but it's not rendered without the %s expansion: set its expansion (or the envstore's default expansion) to %s",
+			key, models.ExpansionTemplate, models.ExpansionTemplate)
+	}
+	return nil
+}
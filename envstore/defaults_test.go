@@ -0,0 +1,146 @@
+package envstore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaults(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	bash := &models.EnvstoreDefaultsModel{Expansion: models.ExpansionBash}
+
+	backends := map[string]Backend{
+		"file":    NewFileBackend(filepath.Join(tmpDir, ".envstore.yml")),
+		"journal": NewFileBackend(filepath.Join(tmpDir, "journal.yml")),
+		"dir":     NewDirBackend(filepath.Join(tmpDir, "envs")),
+		"memory":  NewMemoryBackend(),
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			store := NewWithBackend(backend, Options{EnvSource: testEnvSource{}})
+			require.NoError(t, store.Init(ctx, InitOptions{Journal: name == "journal", Defaults: bash}))
+
+			require.NoError(t, store.Add(ctx, "VERSION", "v1.2.3", AddOptions{}))
+			require.NoError(t, store.Add(ctx, "BUILD_VERSION", "${VERSION#v}", AddOptions{}))
+			require.NoError(t, store.Add(ctx, "SIMPLE_VERSION", "${VERSION#v}", AddOptions{
+				EnvOptions: models.EnvironmentItemOptionsModel{Expansion: pointers.NewStringPtr(models.ExpansionSimple)},
+			}))
+
+			value, _, err := store.Get(ctx, "BUILD_VERSION")
+			require.NoError(t, err)
+			require.Equal(t, "1.2.3", value)
+
+			value, _, err = store.Get(ctx, "SIMPLE_VERSION")
+			require.NoError(t, err)
+			require.Equal(t, "", value)
+
+			// the defaults are not written into the envs
+			envs, err := store.ListOwn(ctx)
+			require.NoError(t, err)
+			opts, err := envs[1].GetOptions()
+			require.NoError(t, err)
+			require.Nil(t, opts.Expansion)
+
+			require.NoError(t, store.Clear(ctx))
+			stored, err := backend.Load(ctx)
+			require.NoError(t, err)
+			require.Equal(t, bash, stored.Defaults)
+		})
+	}
+
+	t.Run("includes use their own defaults", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "base.yml"), []byte(`defaults:
+  expansion: bash
+envs:
+- BASE: ${VERSION:-v1}
+`), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".envstore.yml"), []byte(`include:
+- base.yml
+envs:
+- OWN: ${VERSION:-v1}
+`), 0644))
+
+		result, err := openTestEnvstore(t, filepath.Join(dir, ".envstore.yml")).Evaluate(ctx)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"BASE": "v1", "OWN": ""}, result.EvaluatedNewEnvs)
+	})
+
+	t.Run("resolution", func(t *testing.T) {
+		pth := filepath.Join(t.TempDir(), ".envstore.yml")
+		require.NoError(t, os.WriteFile(pth, []byte(`defaults:
+  resolution: graph
+envs:
+- URL: https://$HOST/api
+- HOST: example.com
+`), 0644))
+
+		store := openTestEnvstore(t, pth)
+		resolution, err := store.Resolution(ctx)
+		require.NoError(t, err)
+		require.Equal(t, models.ResolutionGraph, resolution)
+
+		envs, resolution, err := store.ListWithResolution(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(envs))
+		require.Equal(t, models.ResolutionGraph, resolution)
+
+		value, _, err := store.Get(ctx, "URL")
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/api", value)
+
+		ordered := NewWithBackend(NewFileBackend(pth), Options{EnvSource: testEnvSource{}, Evaluation: env.DeclarationOptions{Resolution: models.ResolutionOrdered}})
+		value, _, err = ordered.Get(ctx, "URL")
+		require.NoError(t, err)
+		require.Equal(t, "https:///api", value)
+	})
+
+	t.Run("invalid defaults", func(t *testing.T) {
+		store := openTestEnvstore(t, filepath.Join(t.TempDir(), ".envstore.yml"))
+		err := store.Init(ctx, InitOptions{Defaults: &models.EnvstoreDefaultsModel{Expansion: "zsh"}})
+		require.EqualError(t, err, "invalid envstore defaults: unknown expansion (zsh), supported expansions: simple, bash, template")
+	})
+}
+
+func TestDefaults_UnrenderedTemplates(t *testing.T) {
+	ctx := context.Background()
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	template := "- APP_NAME: app\n- APP_SLUG: '{{ .APP_NAME }}'\n  opts:\n    is_template: true\n"
+
+	t.Log("is_template without the template expansion is expanded, with a warning")
+	{
+		envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+		require.NoError(t, os.WriteFile(envStorePth, []byte("envs:\n"+template), 0644))
+
+		value, _, err := openTestEnvstore(t, envStorePth).Get(ctx, "APP_SLUG")
+		require.NoError(t, err)
+		require.Equal(t, "{{ .APP_NAME }}", value)
+		require.Contains(t, logs.String(), "Env var (APP_SLUG) is a template (is_template), but it's not rendered without the template expansion")
+	}
+
+	logs.Reset()
+
+	t.Log("is_template is rendered with the default template expansion")
+	{
+		envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+		require.NoError(t, os.WriteFile(envStorePth, []byte("defaults:\n  expansion: template\nenvs:\n"+template), 0644))
+
+		value, _, err := openTestEnvstore(t, envStorePth).Get(ctx, "APP_SLUG")
+		require.NoError(t, err)
+		require.Equal(t, "app", value)
+		require.NotContains(t, logs.String(), "APP_SLUG")
+	}
+}
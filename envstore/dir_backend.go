@@ -0,0 +1,262 @@
+package envstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	dirMetaFileName = ".envstore.yml"
+	dirEnvFileExt   = ".yml"
+)
+
+// dirMetaModel is the content of the directory envstore's meta file.
+type dirMetaModel struct {
+	FormatVersion int                           `yaml:"format_version,omitempty"`
+	Recipients    []string                      `yaml:"recipients,omitempty"`
+	Include       []models.EnvstoreIncludeModel `yaml:"include,omitempty"`
+	Defaults      *models.EnvstoreDefaultsModel `yaml:"defaults,omitempty"`
+	// Keys are the keys of the envs in declaration order, a key is listed once for each of its declarations
+	Keys []string `yaml:"keys"`
+}
+
+// DirBackend stores the envstore in a directory, with one <KEY>.yml file per key (holding the declarations of the key),
+// so that big values and the diffs of the envstore stay readable.
+// The order of the declarations and the other fields of the envstore are stored in the .envstore.yml file of the directory.
+type DirBackend struct {
+	dir string
+}
+
+// NewDirBackend ...
+func NewDirBackend(dir string) *DirBackend {
+	return &DirBackend{dir: filepath.Clean(dir)}
+}
+
+// String ...
+func (b *DirBackend) String() string {
+	return DirScheme + schemeSeparator + b.dir
+}
+
+func (b *DirBackend) metaPath() string {
+	return filepath.Join(b.dir, dirMetaFileName)
+}
+
+func (b *DirBackend) envPath(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("env var key (%s) can't be stored in a directory envstore", key)
+	}
+	return filepath.Join(b.dir, key+dirEnvFileExt), nil
+}
+
+// Exists ...
+func (b *DirBackend) Exists(_ context.Context) (bool, error) {
+	if _, err := os.Stat(b.metaPath()); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (b *DirBackend) loadMeta() (dirMetaModel, error) {
+	content, err := fileutil.ReadBytesFromFile(b.metaPath())
+	if err != nil {
+		return dirMetaModel{}, err
+	}
+
+	var meta dirMetaModel
+	if err := yaml.Unmarshal(content, &meta); err != nil {
+		return dirMetaModel{}, fmt.Errorf("invalid envstore meta file (%s): %s", b.metaPath(), err)
+	}
+	if err := checkEnvstoreFormatVersion(meta.FormatVersion); err != nil {
+		return dirMetaModel{}, err
+	}
+	return meta, nil
+}
+
+// storedKeys returns the keys of the currently stored envstore (if any).
+func (b *DirBackend) storedKeys(ctx context.Context) ([]string, error) {
+	if exists, err := b.Exists(ctx); err != nil || !exists {
+		return nil, err
+	}
+	meta, err := b.loadMeta()
+	if err != nil {
+		return nil, err
+	}
+	return meta.Keys, nil
+}
+
+// Load ...
+func (b *DirBackend) Load(_ context.Context) (models.EnvsSerializeModel, error) {
+	meta, err := b.loadMeta()
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+
+	envstore := models.EnvsSerializeModel{
+		FormatVersion: meta.FormatVersion,
+		Recipients:    meta.Recipients,
+		Include:       meta.Include,
+		Defaults:      meta.Defaults,
+		Envs:          []models.EnvironmentItemModel{},
+	}
+
+	// the declarations of a key are consumed in order, as the key occurs in the meta file
+	declarations := map[string][]models.EnvironmentItemModel{}
+	for _, key := range meta.Keys {
+		if _, loaded := declarations[key]; !loaded {
+			envs, err := b.loadEnvFile(key)
+			if err != nil {
+				return models.EnvsSerializeModel{}, err
+			}
+			declarations[key] = envs
+		}
+
+		if len(declarations[key]) == 0 {
+			return models.EnvsSerializeModel{}, fmt.Errorf("missing declaration of env var (%s) in envstore: %s", key, b)
+		}
+		envstore.Envs = append(envstore.Envs, declarations[key][0])
+		declarations[key] = declarations[key][1:]
+	}
+
+	return envstore, nil
+}
+
+func (b *DirBackend) loadEnvFile(key string) ([]models.EnvironmentItemModel, error) {
+	pth, err := b.envPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	var envs []models.EnvironmentItemModel
+	if err := yaml.Unmarshal(content, &envs); err != nil {
+		return nil, fmt.Errorf("invalid env var file (%s): %s", pth, err)
+	}
+	for _, env := range envs {
+		if err := env.NormalizeValidateFillDefaults(); err != nil {
+			return nil, err
+		}
+		envKey, _, err := env.GetKeyValuePair()
+		if err != nil {
+			return nil, err
+		}
+		if envKey != key {
+			return nil, fmt.Errorf("env var file (%s) declares another env var: %s", pth, envKey)
+		}
+	}
+	return envs, nil
+}
+
+// Save writes the env files first and the meta file last, then removes the env files of the removed keys.
+func (b *DirBackend) Save(ctx context.Context, envstore models.EnvsSerializeModel) error {
+	previousKeys, err := b.storedKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := generateFormattedYMLForEnvModels(envstore.Envs)
+	if err != nil {
+		return err
+	}
+
+	meta := dirMetaModel{
+		FormatVersion: envstore.FormatVersion,
+		Recipients:    envstore.Recipients,
+		Include:       envstore.Include,
+		Defaults:      envstore.Defaults,
+		Keys:          []string{},
+	}
+	declarations := map[string][]models.EnvironmentItemModel{}
+	for _, env := range formatted.Envs {
+		key, _, err := env.GetKeyValuePair()
+		if err != nil {
+			return err
+		}
+		meta.Keys = append(meta.Keys, key)
+		declarations[key] = append(declarations[key], env)
+	}
+
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return err
+	}
+
+	for key, envs := range declarations {
+		pth, err := b.envPath(key)
+		if err != nil {
+			return err
+		}
+		content, err := yaml.Marshal(envs)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomically(pth, content); err != nil {
+			return err
+		}
+	}
+
+	metaContent, err := yaml.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(b.metaPath(), metaContent); err != nil {
+		return err
+	}
+
+	var removedKeys []string
+	for _, key := range previousKeys {
+		if _, declared := declarations[key]; !declared {
+			removedKeys = append(removedKeys, key)
+		}
+	}
+	return b.removeEnvFiles(removedKeys)
+}
+
+// Remove removes the env files and the meta file, other files of the directory are kept.
+func (b *DirBackend) Remove(ctx context.Context) error {
+	keys, err := b.storedKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if err := b.removeEnvFiles(keys); err != nil {
+		return err
+	}
+	if err := os.Remove(b.metaPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (b *DirBackend) removeEnvFiles(keys []string) error {
+	for _, key := range keys {
+		pth, err := b.envPath(key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(pth); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock locks the <dir>.lock file next to the directory.
+func (b *DirBackend) Lock(ctx context.Context, timeout time.Duration) (func() error, error) {
+	lock, err := lockEnvstore(ctx, b.dir, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return lock.unlock, nil
+}
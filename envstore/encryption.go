@@ -0,0 +1,163 @@
+package envstore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/encryption"
+	"github.com/bitrise-io/envman/v2/envman"
+	"github.com/bitrise-io/envman/v2/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// encryptionRecipients returns the envstore's recipients and the recipient of the primary encryption identity (if any).
+func encryptionRecipients(storeRecipients []string) ([]encryption.Recipient, error) {
+	var recipients []encryption.Recipient
+	seen := map[string]bool{}
+
+	identities, err := envman.GetEncryptionIdentities()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key: %s", err)
+	}
+	if len(identities) > 0 {
+		primary := identities[0].Recipient()
+		recipients = append(recipients, primary)
+		seen[primary.String()] = true
+	}
+
+	for _, storeRecipient := range storeRecipients {
+		recipient, err := encryption.ParseRecipient(storeRecipient)
+		if err != nil {
+			return nil, err
+		}
+		if seen[recipient.String()] {
+			continue
+		}
+		recipients = append(recipients, recipient)
+		seen[recipient.String()] = true
+	}
+
+	return recipients, nil
+}
+
+// encryptEnvs returns a copy of the envs, where the sensitive values are encrypted.
+// If there are no recipients (no encryption key is configured and the envstore has no recipients) the envs are returned as they are,
+// with a warning listing the sensitive values stored unencrypted.
+func encryptEnvs(envs []models.EnvironmentItemModel, storeRecipients []string) ([]models.EnvironmentItemModel, error) {
+	recipients, err := encryptionRecipients(storeRecipients)
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		keys, err := plaintextSensitiveKeys(envs)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			log.Warnf("Sensitive env vars are stored unencrypted, because no encryption key is configured (set %s or create %s): %s",
+				envman.EncryptionKeyEnvKey, envman.GetEncryptionKeyFilePath(), strings.Join(keys, ", "))
+		}
+		return envs, nil
+	}
+
+	encryptedEnvs := make([]models.EnvironmentItemModel, 0, len(envs))
+	for _, env := range envs {
+		opts, err := env.GetOptions()
+		if err != nil {
+			return nil, err
+		}
+		key, value, err := env.GetKeyValuePair()
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.IsSensitive == nil || !*opts.IsSensitive || value == "" || encryption.IsEncrypted(value) {
+			encryptedEnvs = append(encryptedEnvs, env)
+			continue
+		}
+
+		encryptedValue, err := encryption.Encrypt(value, recipients)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt env var (%s): %s", key, err)
+		}
+
+		encryptedEnv := models.EnvironmentItemModel{}
+		for k, v := range env {
+			encryptedEnv[k] = v
+		}
+		encryptedEnv[key] = encryptedValue
+		encryptedEnvs = append(encryptedEnvs, encryptedEnv)
+	}
+
+	return encryptedEnvs, nil
+}
+
+// plaintextSensitiveKeys returns the keys of the sensitive envs with a non-empty, unencrypted value.
+func plaintextSensitiveKeys(envs []models.EnvironmentItemModel) ([]string, error) {
+	var keys []string
+	for _, env := range envs {
+		opts, err := env.GetOptions()
+		if err != nil {
+			return nil, err
+		}
+		key, value, err := env.GetKeyValuePair()
+		if err != nil {
+			return nil, err
+		}
+		if opts.IsSensitive != nil && *opts.IsSensitive && value != "" && !encryption.IsEncrypted(value) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// decryptedEnvs returns a copy of the envs with the sensitive values decrypted, the envs are not modified.
+func decryptedEnvs(envs []models.EnvironmentItemModel) ([]models.EnvironmentItemModel, error) {
+	copied := make([]models.EnvironmentItemModel, 0, len(envs))
+	for _, env := range envs {
+		copiedEnv := models.EnvironmentItemModel{}
+		for key, value := range env {
+			copiedEnv[key] = value
+		}
+		copied = append(copied, copiedEnv)
+	}
+	if err := decryptEnvs(copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// decryptEnvs replaces the encrypted values of the envs with the decrypted ones.
+func decryptEnvs(envs []models.EnvironmentItemModel) error {
+	var identities []encryption.Identity
+	identitiesLoaded := false
+
+	for _, env := range envs {
+		key, value, err := env.GetKeyValuePair()
+		if err != nil {
+			return err
+		}
+		if !encryption.IsEncrypted(value) {
+			continue
+		}
+
+		if !identitiesLoaded {
+			identities, err = envman.GetEncryptionIdentities()
+			if err != nil {
+				return fmt.Errorf("failed to read encryption key: %s", err)
+			}
+			identitiesLoaded = true
+		}
+		if len(identities) == 0 {
+			return fmt.Errorf("env var (%s) is encrypted, but no encryption key is configured: set %s or create %s", key, envman.EncryptionKeyEnvKey, envman.GetEncryptionKeyFilePath())
+		}
+
+		decryptedValue, err := encryption.Decrypt(value, identities)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt env var (%s): %s", key, err)
+		}
+		env[key] = decryptedValue
+	}
+
+	return nil
+}
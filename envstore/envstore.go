@@ -0,0 +1,679 @@
+// Package envstore reads, modifies and evaluates envman envstores.
+//
+// An Envstore never prompts and doesn't depend on global state other than the envman encryption key,
+// its methods can be called from multiple goroutines: modifications are serialized in-process by a mutex
+// and across processes by the envstore's lock file.
+package envstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/encryption"
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// DefaultLockTimeout is used if Options.LockTimeout is not set.
+const DefaultLockTimeout = 30 * time.Second
+
+// Options configure an Envstore.
+type Options struct {
+	// LockTimeout is the max time to wait for the envstore's lock before a modification
+	LockTimeout time.Duration
+	// EnvSource is the initial environment the envs are evaluated in, defaults to the current process' environment
+	EnvSource env.EnvironmentSource
+	// Evaluation configures the evaluation of the envs, like the default strict mode
+	Evaluation env.DeclarationOptions
+	// HistoryLimit is the number of revisions kept in the <envstore>.history file of file envstores,
+	// the modifications are not recorded if it's not set
+	HistoryLimit int
+}
+
+// InitOptions configure Envstore.Init.
+type InitOptions struct {
+	// Journal creates an append-only journal envstore instead of the classic YAML layout
+	Journal bool
+	// Clear removes the existing envstore first
+	Clear bool
+	// Defaults are the default options of the envstore's envs, like the expansion
+	Defaults *models.EnvstoreDefaultsModel
+}
+
+// AddOptions configure Envstore.Add, Envstore.Unset and Envstore.Import.
+type AddOptions struct {
+	// Append adds the env after the existing envs with the same key, instead of replacing them
+	Append bool
+	// EnvOptions are the options of the added env (is_expand, is_sensitive, ...)
+	EnvOptions models.EnvironmentItemOptionsModel
+	// Validate is called while the envstore is locked, before the env is added
+	Validate AddValidator
+}
+
+// AddValidator validates an env before it's added to the envstore, based on the envstore's current envs
+// (with the sensitive values decrypted). The returned value and options are added (the returned Validate is ignored).
+type AddValidator func(envs []models.EnvironmentItemModel, key, value string, opts AddOptions) (string, AddOptions, error)
+
+// Envstore is an envstore, stored by its Backend.
+type Envstore struct {
+	backend Backend
+	opts    Options
+	mu      sync.Mutex
+}
+
+// New returns the Envstore at the location (see OpenBackend), the envstore is not accessed until a method is called.
+func New(location string, opts Options) (*Envstore, error) {
+	if location == "" {
+		return nil, errors.New("no path provided")
+	}
+
+	backend, err := OpenBackend(location)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithBackend(backend, opts), nil
+}
+
+// NewWithBackend returns the Envstore stored by the backend.
+func NewWithBackend(backend Backend, opts Options) *Envstore {
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = DefaultLockTimeout
+	}
+	if opts.EnvSource == nil {
+		opts.EnvSource = &env.DefaultEnvironmentSource{}
+	}
+	return &Envstore{backend: backend, opts: opts}
+}
+
+// Path returns the location of the envstore.
+func (s *Envstore) Path() string {
+	return s.backend.String()
+}
+
+// modify runs fn while holding both the in-process and the inter-process lock of the envstore.
+func (s *Envstore) modify(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.backend.Lock(ctx, s.opts.LockTimeout)
+	if err != nil {
+		return err
+	}
+
+	fnErr := fn()
+	if err := unlock(); err != nil && fnErr == nil {
+		return fmt.Errorf("failed to unlock envstore: %s", err)
+	}
+	return fnErr
+}
+
+// Locked runs fn while holding the envstore's lock, for the operations on the envstore
+// which are not covered by the Envstore's methods (like copying its file).
+func (s *Envstore) Locked(ctx context.Context, fn func() error) error {
+	return s.modify(ctx, fn)
+}
+
+// load loads the envstore from the backend, with the $$ of the older format versions rewritten (see upgradeDollarSigns).
+func (s *Envstore) load(ctx context.Context) (models.EnvsSerializeModel, error) {
+	envstore, err := s.backend.Load(ctx)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+	if err := upgradeDollarSigns(envstore); err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+	return envstore, nil
+}
+
+// loadHeader returns the stored envstore without its envs,
+// so that rewriting the envstore keeps its other fields (like the recipients and the includes).
+// Only a not yet existing envstore has an empty header: an envstore which fails to load
+// (like one with a newer format version than supported, or an invalid one) must not be overwritten.
+func (s *Envstore) loadHeader(ctx context.Context) (models.EnvsSerializeModel, error) {
+	exists, err := s.backend.Exists(ctx)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+	if !exists {
+		return models.EnvsSerializeModel{}, nil
+	}
+
+	envstore, err := s.load(ctx)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+
+	envstore.Envs = nil
+	return envstore, nil
+}
+
+// prepare migrates the envstore to the current format version, and encrypts its sensitive values.
+func prepare(envstore models.EnvsSerializeModel) (models.EnvsSerializeModel, error) {
+	if err := migrateEnvstore(&envstore); err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+
+	envs, err := encryptEnvs(envstore.Envs, envstore.Recipients)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+	envstore.Envs = envs
+	return envstore, nil
+}
+
+func (s *Envstore) save(ctx context.Context, envstore models.EnvsSerializeModel) error {
+	prepared, err := prepare(envstore)
+	if err != nil {
+		return err
+	}
+	return s.backend.Save(ctx, prepared)
+}
+
+// saveChanges persists a change of the envstore: journal envstores get the records of the change appended,
+// other envstores are saved with the updated env list.
+// Journals with an older format version than the changed envstore requires are rewritten as well, to migrate them.
+func (s *Envstore) saveChanges(ctx context.Context, envstore models.EnvsSerializeModel, records []journalRecord) error {
+	fileBackend, ok := s.backend.(*FileBackend)
+	if !ok {
+		return s.save(ctx, envstore)
+	}
+
+	journal, err := fileBackend.isJournal(ctx)
+	if err != nil {
+		return err
+	}
+	if !journal {
+		return s.save(ctx, envstore)
+	}
+
+	appended, err := appendRecords(ctx, fileBackend, envstore, records)
+	if err != nil || appended {
+		return err
+	}
+	return s.save(ctx, envstore)
+}
+
+// appendChange appends the records of a change, which declares the envs, to a journal envstore without replaying the journal:
+// only its header is read, to check whether its format version supports the declared envs.
+// It returns false (without appending the records) if the envstore is not a journal, or it has to be rewritten to migrate it.
+func (s *Envstore) appendChange(ctx context.Context, envs []models.EnvironmentItemModel, records []journalRecord) (bool, error) {
+	fileBackend, ok := s.backend.(*FileBackend)
+	if !ok {
+		return false, nil
+	}
+
+	header, journal, err := readJournalHeader(fileBackend.pth)
+	if err != nil || !journal {
+		return false, err
+	}
+	header.Envs = envs
+	return appendRecords(ctx, fileBackend, header, records)
+}
+
+// appendRecords appends the records to the journal (with the sensitive values of the added envs encrypted),
+// unless the envstore requires a newer format version than the journal's.
+func appendRecords(ctx context.Context, fileBackend *FileBackend, envstore models.EnvsSerializeModel, records []journalRecord) (bool, error) {
+	requiredVersion, err := requiredFormatVersion(envstore)
+	if err != nil {
+		return false, err
+	}
+	if envstore.FormatVersion < requiredVersion {
+		return false, nil
+	}
+
+	var addedEnvs []models.EnvironmentItemModel
+	for _, record := range records {
+		if record.Op == journalOpAdd {
+			addedEnvs = append(addedEnvs, record.Env)
+		}
+	}
+	if len(addedEnvs) > 0 {
+		encryptedEnvs, err := encryptEnvs(addedEnvs, envstore.Recipients)
+		if err != nil {
+			return false, err
+		}
+		for i := range records {
+			if records[i].Op == journalOpAdd {
+				records[i].Env, encryptedEnvs = encryptedEnvs[0], encryptedEnvs[1:]
+			}
+		}
+	}
+	return true, fileBackend.appendJournalRecords(ctx, records)
+}
+
+// Init creates an empty envstore, it fails if the envstore already exists (unless opts.Clear is set).
+// The journal layout is only supported by file envstores.
+func (s *Envstore) Init(ctx context.Context, opts InitOptions) error {
+	return s.modifyRecorded(ctx, "init", func() error {
+		if opts.Clear {
+			if err := s.backend.Remove(ctx); err != nil {
+				return fmt.Errorf("failed to clear path: %s", err)
+			}
+		}
+
+		if exists, err := s.backend.Exists(ctx); err != nil {
+			return err
+		} else if exists {
+			return fmt.Errorf("failed to init at path: Path already exist: %s", s.Path())
+		}
+
+		if err := validateDefaults(opts.Defaults); err != nil {
+			return err
+		}
+
+		envstore, err := prepare(models.EnvsSerializeModel{Defaults: opts.Defaults, Envs: []models.EnvironmentItemModel{}})
+		if err != nil {
+			return err
+		}
+
+		if opts.Journal {
+			fileBackend, ok := s.backend.(*FileBackend)
+			if !ok {
+				return fmt.Errorf("the journal layout is not supported by envstore: %s", s.Path())
+			}
+			return fileBackend.saveLayout(envstore, true)
+		}
+		return s.backend.Save(ctx, envstore)
+	})
+}
+
+// Clear removes every env of the envstore, its other fields (like the recipients and the includes) are kept.
+func (s *Envstore) Clear(ctx context.Context) error {
+	if exists, err := s.backend.Exists(ctx); err != nil {
+		return err
+	} else if !exists {
+		return errors.New("EnvStore not found in path:" + s.Path())
+	}
+
+	return s.modifyRecorded(ctx, "clear", func() error {
+		envstore, err := s.loadHeader(ctx)
+		if err != nil {
+			return err
+		}
+		envstore.Envs = []models.EnvironmentItemModel{}
+		return s.save(ctx, envstore)
+	})
+}
+
+// Compact rewrites a journal envstore into the classic YAML layout, other envstores are just rewritten.
+func (s *Envstore) Compact(ctx context.Context) error {
+	return s.modifyRecorded(ctx, "compact", func() error {
+		envstore, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		fileBackend, ok := s.backend.(*FileBackend)
+		if !ok {
+			return s.save(ctx, envstore)
+		}
+
+		prepared, err := prepare(envstore)
+		if err != nil {
+			return err
+		}
+		return fileBackend.saveLayout(prepared, false)
+	})
+}
+
+// Write replaces the envs declared by the envstore, its other fields (like the recipients and the includes) are kept.
+func (s *Envstore) Write(ctx context.Context, envs []models.EnvironmentItemModel) error {
+	return s.modifyRecorded(ctx, "write", func() error {
+		envstore, err := s.loadHeader(ctx)
+		if err != nil {
+			return err
+		}
+		envstore.Envs = envs
+		return s.save(ctx, envstore)
+	})
+}
+
+// Add declares the env. The existing envs with the same key are replaced, unless opts.Append is set.
+// Without opts.Validate the env is appended to a journal envstore without replaying the journal.
+func (s *Envstore) Add(ctx context.Context, key, value string, opts AddOptions) error {
+	return s.modifyRecorded(ctx, "add "+key, func() error {
+		if opts.Validate == nil {
+			newEnv, record, err := newAddChange(key, value, opts)
+			if err != nil {
+				return err
+			}
+			if appended, err := s.appendChange(ctx, []models.EnvironmentItemModel{newEnv}, []journalRecord{record}); err != nil || appended {
+				return err
+			}
+		}
+
+		envstore, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		if opts.Validate != nil {
+			envs, err := decryptedEnvs(envstore.Envs)
+			if err != nil {
+				return err
+			}
+			if value, opts, err = opts.Validate(envs, key, value, opts); err != nil {
+				return err
+			}
+		}
+
+		newEnv, record, err := newAddChange(key, value, opts)
+		if err != nil {
+			return err
+		}
+		envstore.Envs, err = UpsertEnv(envstore.Envs, newEnv, record.Replace)
+		if err != nil {
+			return err
+		}
+
+		return s.saveChanges(ctx, envstore, []journalRecord{record})
+	})
+}
+
+// newAddChange returns the env added with the value and the options, and the journal record of adding it.
+func newAddChange(key, value string, opts AddOptions) (models.EnvironmentItemModel, journalRecord, error) {
+	newEnv := models.EnvironmentItemModel{
+		key:               value,
+		models.OptionsKey: opts.EnvOptions,
+	}
+	if err := newEnv.NormalizeValidateFillDefaults(); err != nil {
+		return nil, journalRecord{}, err
+	}
+
+	record, err := newJournalAddRecord(newEnv, !opts.Append)
+	if err != nil {
+		return nil, journalRecord{}, err
+	}
+	return newEnv, record, nil
+}
+
+// Unset declares the env as unset: it's removed from the environment the envstore is evaluated in.
+// The existing envs with the same key are replaced, unless opts.Append is set (opts.EnvOptions is ignored).
+// Without opts.Validate the env is appended to a journal envstore without replaying the journal.
+func (s *Envstore) Unset(ctx context.Context, key string, opts AddOptions) error {
+	newEnv, err := newUnsetEnv(key)
+	if err != nil {
+		return err
+	}
+
+	return s.modifyRecorded(ctx, "unset "+key, func() error {
+		if opts.Validate == nil {
+			record := journalRecord{Op: journalOpUnset, Key: key, Replace: !opts.Append}
+			if appended, err := s.appendChange(ctx, []models.EnvironmentItemModel{newEnv}, []journalRecord{record}); err != nil || appended {
+				return err
+			}
+		}
+
+		envstore, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		if opts.Validate != nil {
+			envs, err := decryptedEnvs(envstore.Envs)
+			if err != nil {
+				return err
+			}
+			if _, opts, err = opts.Validate(envs, key, "", opts); err != nil {
+				return err
+			}
+		}
+
+		replace := !opts.Append
+		envstore.Envs, err = UpsertEnv(envstore.Envs, newEnv, replace)
+		if err != nil {
+			return err
+		}
+
+		return s.saveChanges(ctx, envstore, []journalRecord{{Op: journalOpUnset, Key: key, Replace: replace}})
+	})
+}
+
+// Import adds the envs in a single modification, in order. Each env replaces the existing envs with the same key,
+// unless opts.Append is set (opts.EnvOptions is ignored, the envs declare their own options).
+// opts.Validate is called for each env, with the envs declared before it.
+// Journal envstores get a record appended for each env, without opts.Validate the journal is not replayed.
+func (s *Envstore) Import(ctx context.Context, envs []models.EnvironmentItemModel, opts AddOptions) error {
+	return s.modifyRecorded(ctx, "import", func() error {
+		var envstore models.EnvsSerializeModel
+		var current []models.EnvironmentItemModel
+		if opts.Validate != nil {
+			var err error
+			if envstore, err = s.load(ctx); err != nil {
+				return err
+			}
+			if current, err = decryptedEnvs(envstore.Envs); err != nil {
+				return err
+			}
+		}
+
+		var newEnvs []models.EnvironmentItemModel
+		var records []journalRecord
+		for _, env := range envs {
+			key, value, err := env.GetKeyValuePair()
+			if err != nil {
+				return err
+			}
+			envOpts, err := env.GetOptions()
+			if err != nil {
+				return err
+			}
+
+			addOpts := AddOptions{Append: opts.Append, EnvOptions: envOpts}
+			if opts.Validate != nil {
+				if value, addOpts, err = opts.Validate(current, key, value, addOpts); err != nil {
+					return err
+				}
+			}
+
+			newEnv, record, err := newAddChange(key, value, addOpts)
+			if err != nil {
+				return err
+			}
+			if opts.Validate != nil {
+				if current, err = UpsertEnv(current, newEnv, record.Replace); err != nil {
+					return err
+				}
+			}
+			newEnvs = append(newEnvs, newEnv)
+			records = append(records, record)
+		}
+
+		if opts.Validate == nil {
+			appended, err := s.appendChange(ctx, newEnvs, records)
+			if err != nil || appended {
+				return err
+			}
+			if envstore, err = s.load(ctx); err != nil {
+				return err
+			}
+		}
+
+		for i, newEnv := range newEnvs {
+			var err error
+			if envstore.Envs, err = UpsertEnv(envstore.Envs, newEnv, records[i].Replace); err != nil {
+				return err
+			}
+		}
+		return s.saveChanges(ctx, envstore, records)
+	})
+}
+
+// Remove drops every declaration of the key from the envstore (the includes are not modified).
+func (s *Envstore) Remove(ctx context.Context, key string) error {
+	return s.modifyRecorded(ctx, "remove "+key, func() error {
+		envstore, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+
+		envs, err := removeEnv(envstore.Envs, key)
+		if err != nil {
+			return err
+		}
+		if len(envs) == len(envstore.Envs) {
+			return nil
+		}
+		envstore.Envs = envs
+
+		return s.saveChanges(ctx, envstore, []journalRecord{{Op: journalOpRemove, Key: key}})
+	})
+}
+
+// List returns the envs of the envstore in declaration order, including the envs of its includes,
+// with the sensitive values decrypted.
+func (s *Envstore) List(ctx context.Context) ([]models.EnvironmentItemModel, error) {
+	envs, _, err := s.ListWithResolution(ctx)
+	return envs, err
+}
+
+// ListWithResolution returns the envs like List, and the default resolution of the envstore (see Resolution),
+// both read by a single load of the envstore.
+func (s *Envstore) ListWithResolution(ctx context.Context) ([]models.EnvironmentItemModel, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	envstore, err := loadResolvedEnvstore(ctx, s.backend)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := decryptEnvs(envstore.Envs); err != nil {
+		return nil, "", err
+	}
+	if envstore.Defaults == nil {
+		return envstore.Envs, "", nil
+	}
+	return envstore.Envs, envstore.Defaults.Resolution, nil
+}
+
+// ListOwn returns the envs declared by the envstore itself (without the envs of its includes),
+// with the sensitive values decrypted.
+func (s *Envstore) ListOwn(ctx context.Context) ([]models.EnvironmentItemModel, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	envstore, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := decryptEnvs(envstore.Envs); err != nil {
+		return nil, err
+	}
+	return envstore.Envs, nil
+}
+
+// Resolution returns the resolution set in the envstore defaults, or an empty string if it's not set.
+func (s *Envstore) Resolution(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	envstore, err := s.load(ctx)
+	if err != nil {
+		return "", err
+	}
+	if envstore.Defaults == nil {
+		return "", nil
+	}
+	return envstore.Defaults.Resolution, nil
+}
+
+// Evaluate evaluates the envs of the envstore in the initial environment (Options.EnvSource).
+// If Options.Evaluation doesn't set the resolution, the envstore's default resolution is used,
+// and if it doesn't set the envstore lookup of the value sources, ValueSourceLookup is used.
+func (s *Envstore) Evaluate(ctx context.Context) (env.DeclarationSideEffects, error) {
+	envs, resolution, err := s.ListWithResolution(ctx)
+	if err != nil {
+		return env.DeclarationSideEffects{}, err
+	}
+
+	opts := LayersEvaluationOptions([]string{resolution}, s.opts.Evaluation, s.opts)
+	return env.GetDeclarationsSideEffectsWithOptions(envs, s.opts.EnvSource, opts)
+}
+
+// Get returns the evaluated value of the env, and whether the envstore declares (and doesn't unset) it.
+func (s *Envstore) Get(ctx context.Context, key string) (string, bool, error) {
+	result, err := s.Evaluate(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := result.EvaluatedNewEnvs[key]
+	return value, ok, nil
+}
+
+// Command returns the command with its environment set to the result of evaluating the envstore.
+func (s *Envstore) Command(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	result, err := s.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return command(ctx, result, name, args...), nil
+}
+
+// command returns the command with the evaluated environment.
+func command(ctx context.Context, result env.DeclarationSideEffects, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = make([]string, 0, len(result.ResultEnvironment))
+	for key, value := range result.ResultEnvironment {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	return cmd
+}
+
+// Reencrypt decrypts the sensitive values of the envstore and encrypts them again
+// for the primary encryption identity and the envstore's (updated) recipients.
+func (s *Envstore) Reencrypt(ctx context.Context, addRecipients, removeRecipients []string) error {
+	return s.modifyRecorded(ctx, "rekey", func() error {
+		envstore, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		if err := decryptEnvs(envstore.Envs); err != nil {
+			return err
+		}
+
+		recipients, err := updateRecipients(envstore.Recipients, addRecipients, removeRecipients)
+		if err != nil {
+			return err
+		}
+		envstore.Recipients = recipients
+
+		return s.save(ctx, envstore)
+	})
+}
+
+func updateRecipients(recipients, addRecipients, removeRecipients []string) ([]string, error) {
+	removed := map[string]bool{}
+	for _, r := range removeRecipients {
+		recipient, err := encryption.ParseRecipient(r)
+		if err != nil {
+			return nil, err
+		}
+		removed[recipient.String()] = true
+	}
+
+	var updated []string
+	seen := map[string]bool{}
+	for _, r := range append(recipients, addRecipients...) {
+		recipient, err := encryption.ParseRecipient(r)
+		if err != nil {
+			return nil, err
+		}
+		if removed[recipient.String()] || seen[recipient.String()] {
+			continue
+		}
+		updated = append(updated, recipient.String())
+		seen[recipient.String()] = true
+	}
+	return updated, nil
+}
@@ -0,0 +1,141 @@
+package envstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+type testEnvSource map[string]string
+
+func (s testEnvSource) GetEnvironment() map[string]string {
+	envs := map[string]string{}
+	for key, value := range s {
+		envs[key] = value
+	}
+	return envs
+}
+
+func openTestEnvstore(t *testing.T, pth string) *Envstore {
+	store, err := New(pth, Options{EnvSource: testEnvSource{}})
+	require.NoError(t, err)
+	return store
+}
+
+func newTestEnvstore(t *testing.T) *Envstore {
+	store := openTestEnvstore(t, filepath.Join(t.TempDir(), ".envstore.yml"))
+	require.NoError(t, store.Init(context.Background(), InitOptions{}))
+	return store
+}
+
+func TestEnvstore(t *testing.T) {
+	ctx := context.Background()
+
+	for _, journal := range []bool{false, true} {
+		store := openTestEnvstore(t, filepath.Join(t.TempDir(), ".envstore.yml"))
+		require.NoError(t, store.Init(ctx, InitOptions{Journal: journal}))
+
+		require.NoError(t, store.Add(ctx, "GREETING", "hello", AddOptions{}))
+		require.NoError(t, store.Add(ctx, "MESSAGE", "$GREETING world", AddOptions{}))
+		require.NoError(t, store.Add(ctx, "TOKEN", "secret", AddOptions{}))
+		require.NoError(t, store.Add(ctx, "TOKEN", "other secret", AddOptions{Append: true}))
+
+		envs, err := store.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 4, len(envs))
+
+		value, found, err := store.Get(ctx, "MESSAGE")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "hello world", value)
+
+		cmd, err := store.Command(ctx, "sh", "-c", "echo $MESSAGE")
+		require.NoError(t, err)
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		require.Equal(t, "hello world\n", string(out))
+
+		require.NoError(t, store.Remove(ctx, "TOKEN"))
+		_, found, err = store.Get(ctx, "TOKEN")
+		require.NoError(t, err)
+		require.False(t, found)
+
+		require.NoError(t, store.Unset(ctx, "GREETING", AddOptions{}))
+		_, found, err = store.Get(ctx, "GREETING")
+		require.NoError(t, err)
+		require.False(t, found)
+
+		envs, err = store.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(envs))
+	}
+}
+
+func TestEnvstoreImport(t *testing.T) {
+	ctx := context.Background()
+	pth := filepath.Join(t.TempDir(), ".envstore.yml")
+	store := openTestEnvstoreWithHistory(t, pth, 50)
+	require.NoError(t, store.Init(ctx, InitOptions{}))
+	require.NoError(t, store.Add(ctx, "BRANCH", "dev", AddOptions{}))
+
+	var validated []int
+	validate := func(envs []models.EnvironmentItemModel, key, value string, opts AddOptions) (string, AddOptions, error) {
+		validated = append(validated, len(envs))
+		return strings.ToUpper(value), opts, nil
+	}
+	require.NoError(t, store.Import(ctx, []models.EnvironmentItemModel{
+		{"BRANCH": "main"},
+		{"APP_ENV": "production"},
+	}, AddOptions{Validate: validate}))
+
+	// each env is validated with the envs imported before it
+	require.Equal(t, []int{1, 1}, validated)
+	requireEnvValue(t, store, "BRANCH", "MAIN")
+	requireEnvValue(t, store, "APP_ENV", "PRODUCTION")
+
+	revisions, _, err := store.History(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "import", revisions[len(revisions)-1].Command)
+
+	require.NoError(t, store.Import(ctx, []models.EnvironmentItemModel{{"BRANCH": "release"}}, AddOptions{Append: true}))
+	envs, err := store.ListOwn(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(envs))
+}
+
+func TestEnvstore_Missing(t *testing.T) {
+	ctx := context.Background()
+	store := openTestEnvstore(t, filepath.Join(t.TempDir(), ".envstore.yml"))
+
+	_, err := store.List(ctx)
+	require.Error(t, err)
+
+	require.Error(t, store.Add(ctx, "A", "B", AddOptions{}))
+	_, err = os.Stat(store.Path())
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestEnvstore_Invalid(t *testing.T) {
+	ctx := context.Background()
+	pth := filepath.Join(t.TempDir(), ".envstore.yml")
+	content := []byte("include:\n- base.yml\nenvs:\n- A: [\n")
+	require.NoError(t, os.WriteFile(pth, content, 0644))
+	store := openTestEnvstore(t, pth)
+
+	require.Error(t, store.Write(ctx, []models.EnvironmentItemModel{{"A": "B"}}))
+	require.Error(t, store.Clear(ctx))
+
+	stored, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	require.Equal(t, string(content), string(stored))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("", Options{})
+	require.EqualError(t, err, "no path provided")
+}
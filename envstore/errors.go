@@ -0,0 +1,46 @@
+package envstore
+
+import (
+	"fmt"
+	"time"
+)
+
+// LockedError is returned if the envstore's lock could not be acquired within the lock timeout.
+type LockedError struct {
+	EnvstorePath string
+	HolderPID    int
+	Timeout      time.Duration
+}
+
+func NewLockedError(envstorePath string, holderPID int, timeout time.Duration) LockedError {
+	return LockedError{
+		EnvstorePath: envstorePath,
+		HolderPID:    holderPID,
+		Timeout:      timeout,
+	}
+}
+
+func (e LockedError) Error() string {
+	holder := "another process"
+	if e.HolderPID > 0 {
+		holder = fmt.Sprintf("process %d", e.HolderPID)
+	}
+	return fmt.Sprintf("envstore (%s) is locked by %s, gave up waiting after %s", e.EnvstorePath, holder, e.Timeout)
+}
+
+// FormatVersionError is returned for envstores written by a newer envman version.
+type FormatVersionError struct {
+	FormatVersion          int
+	SupportedFormatVersion int
+}
+
+func NewFormatVersionError(formatVersion, supportedFormatVersion int) FormatVersionError {
+	return FormatVersionError{
+		FormatVersion:          formatVersion,
+		SupportedFormatVersion: supportedFormatVersion,
+	}
+}
+
+func (e FormatVersionError) Error() string {
+	return fmt.Sprintf("envstore format version (%d) is newer than the latest supported version (%d), please upgrade envman", e.FormatVersion, e.SupportedFormatVersion)
+}
@@ -0,0 +1,318 @@
+package envstore
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"gopkg.in/yaml.v2"
+)
+
+// UpsertEnv replaces every env with the new env's key if replace is set, otherwise (or if there is no such env) appends it.
+func UpsertEnv(oldEnvSlice []models.EnvironmentItemModel, newEnv models.EnvironmentItemModel, replace bool) ([]models.EnvironmentItemModel, error) {
+	newKey, _, err := newEnv.GetKeyValuePair()
+	if err != nil {
+		return []models.EnvironmentItemModel{}, err
+	}
+
+	var newEnvs []models.EnvironmentItemModel
+	exist := false
+
+	for _, env := range oldEnvSlice {
+		key, _, err := env.GetKeyValuePair()
+		if err != nil {
+			return []models.EnvironmentItemModel{}, err
+		}
+
+		if replace && key == newKey {
+			exist = true
+			newEnvs = append(newEnvs, newEnv)
+		} else {
+			newEnvs = append(newEnvs, env)
+		}
+	}
+
+	if !exist {
+		newEnvs = append(newEnvs, newEnv)
+	}
+
+	return newEnvs, nil
+}
+
+// removeEnv drops every env with the key.
+func removeEnv(oldEnvSlice []models.EnvironmentItemModel, key string) ([]models.EnvironmentItemModel, error) {
+	newEnvs := []models.EnvironmentItemModel{}
+	for _, env := range oldEnvSlice {
+		envKey, _, err := env.GetKeyValuePair()
+		if err != nil {
+			return nil, err
+		}
+		if envKey != key {
+			newEnvs = append(newEnvs, env)
+		}
+	}
+	return newEnvs, nil
+}
+
+func removeDefaults(env *models.EnvironmentItemModel) error {
+	opts, err := env.GetOptions()
+	if err != nil {
+		return err
+	}
+
+	if opts.Title != nil && *opts.Title == "" {
+		opts.Title = nil
+	}
+	if opts.Description != nil && *opts.Description == "" {
+		opts.Description = nil
+	}
+	if opts.Category != nil && *opts.Category == "" {
+		opts.Category = nil
+	}
+	if opts.Summary != nil && *opts.Summary == "" {
+		opts.Summary = nil
+	}
+	if opts.IsRequired != nil && *opts.IsRequired == models.DefaultIsRequired {
+		opts.IsRequired = nil
+	}
+	if opts.IsDontChangeValue != nil && *opts.IsDontChangeValue == models.DefaultIsDontChangeValue {
+		opts.IsDontChangeValue = nil
+	}
+	if opts.IsTemplate != nil && *opts.IsTemplate == models.DefaultIsTemplate {
+		opts.IsTemplate = nil
+	}
+	if opts.IsExpand != nil && *opts.IsExpand == models.DefaultIsExpand {
+		opts.IsExpand = nil
+	}
+	if opts.IsSensitive != nil && *opts.IsSensitive == models.DefaultIsSensitive {
+		opts.IsSensitive = nil
+	}
+	if opts.SkipIfEmpty != nil && *opts.SkipIfEmpty == models.DefaultSkipIfEmpty {
+		opts.SkipIfEmpty = nil
+	}
+	if opts.Unset != nil && *opts.Unset == models.DefaultUnset {
+		opts.Unset = nil
+	}
+
+	(*env)[models.OptionsKey] = opts
+	return nil
+}
+
+func generateFormattedYMLForEnvModels(envs []models.EnvironmentItemModel) (models.EnvsSerializeModel, error) {
+	envMapSlice := []models.EnvironmentItemModel{}
+	for _, env := range envs {
+		err := removeDefaults(&env)
+		if err != nil {
+			return models.EnvsSerializeModel{}, err
+		}
+
+		hasOptions := false
+		opts, err := env.GetOptions()
+		if err != nil {
+			return models.EnvsSerializeModel{}, err
+		}
+
+		if opts.Title != nil {
+			hasOptions = true
+		}
+		if opts.Description != nil {
+			hasOptions = true
+		}
+		if opts.Summary != nil {
+			hasOptions = true
+		}
+		if len(opts.ValueOptions) > 0 {
+			hasOptions = true
+		}
+		if opts.IsRequired != nil {
+			hasOptions = true
+		}
+		if opts.IsDontChangeValue != nil {
+			hasOptions = true
+		}
+		if opts.IsTemplate != nil {
+			hasOptions = true
+		}
+		if opts.IsExpand != nil {
+			hasOptions = true
+		}
+		if opts.IsSensitive != nil {
+			hasOptions = true
+		}
+		if opts.SkipIfEmpty != nil {
+			hasOptions = true
+		}
+		if opts.Unset != nil {
+			hasOptions = true
+		}
+		if opts.Expansion != nil {
+			hasOptions = true
+		}
+		if opts.Strict != nil {
+			hasOptions = true
+		}
+		if opts.ValueFrom != nil {
+			hasOptions = true
+		}
+		if len(opts.Transforms) > 0 {
+			hasOptions = true
+		}
+		if opts.Type != nil {
+			hasOptions = true
+		}
+		if opts.Operation != nil {
+			hasOptions = true
+		}
+		if opts.Separator != nil {
+			hasOptions = true
+		}
+		if opts.Dedupe != nil {
+			hasOptions = true
+		}
+		if opts.Pattern != nil {
+			hasOptions = true
+		}
+		if opts.MinLength != nil {
+			hasOptions = true
+		}
+		if opts.MaxLength != nil {
+			hasOptions = true
+		}
+		if opts.Min != nil {
+			hasOptions = true
+		}
+		if opts.Max != nil {
+			hasOptions = true
+		}
+
+		if !hasOptions {
+			delete(env, models.OptionsKey)
+		}
+
+		envMapSlice = append(envMapSlice, env)
+	}
+
+	return models.EnvsSerializeModel{
+		Envs: envMapSlice,
+	}, nil
+}
+
+// formatYML returns the envstore in the classic YAML layout.
+func formatYML(envstore models.EnvsSerializeModel) ([]byte, error) {
+	envYML, err := generateFormattedYMLForEnvModels(envstore.Envs)
+	if err != nil {
+		return nil, err
+	}
+	envYML.FormatVersion = envstore.FormatVersion
+	envYML.Recipients = envstore.Recipients
+	envYML.Include = envstore.Include
+	envYML.Defaults = envstore.Defaults
+
+	return yaml.Marshal(envYML)
+}
+
+// writeFileAtomically writes the content into a temporary file next to pth, fsyncs it
+// and renames it over pth, so readers never see a partially written file.
+func writeFileAtomically(pth string, content []byte) error {
+	return writeFileAtomicallyWithPerm(pth, content, 0644)
+}
+
+// writeFileAtomicallyWithPerm is writeFileAtomically, creating the file with perm if it does not exist yet
+// (existing files keep their permissions).
+func writeFileAtomicallyWithPerm(pth string, content []byte, perm os.FileMode) error {
+	if info, err := os.Stat(pth); err == nil {
+		perm = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dir := filepath.Dir(pth)
+	tmpFile, err := os.CreateTemp(dir, filepath.Base(pth)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPth := tmpFile.Name()
+	removeTmp := func() {
+		_ = os.Remove(tmpPth)
+	}
+
+	if _, err := tmpFile.Write(content); err != nil {
+		_ = tmpFile.Close()
+		removeTmp()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		removeTmp()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		removeTmp()
+		return err
+	}
+	if err := os.Chmod(tmpPth, perm); err != nil {
+		removeTmp()
+		return err
+	}
+	if err := os.Rename(tmpPth, pth); err != nil {
+		removeTmp()
+		return err
+	}
+
+	// persist the rename itself
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return nil
+	}
+	_ = dirFile.Sync()
+	return dirFile.Close()
+}
+
+// ParseYML parses an envstore in the classic YAML layout, and resolves its includes relative to includeBaseDir.
+// $$ is an escaped dollar sign in the returned envs, evaluate them with env.DeclarationOptions.DollarEscape.
+func ParseYML(content []byte, includeBaseDir string) ([]models.EnvironmentItemModel, error) {
+	envstore, err := parseEnvstoreYML(content)
+	if err != nil {
+		return []models.EnvironmentItemModel{}, err
+	}
+
+	baseDir, err := filepath.Abs(includeBaseDir)
+	if err != nil {
+		return []models.EnvironmentItemModel{}, err
+	}
+	envs, err := resolveEnvstoreIncludes(envstore, baseDir, nil)
+	if err != nil {
+		return []models.EnvironmentItemModel{}, err
+	}
+	return envs, nil
+}
+
+func parseEnvstoreYML(bytes []byte) (models.EnvsSerializeModel, error) {
+	var envsYML models.EnvsSerializeModel
+	if err := yaml.Unmarshal(bytes, &envsYML); err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+	if err := checkEnvstoreFormatVersion(envsYML.FormatVersion); err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+	for _, env := range envsYML.Envs {
+		if err := env.NormalizeValidateFillDefaults(); err != nil {
+			return models.EnvsSerializeModel{}, err
+		}
+	}
+	return envsYML, nil
+}
+
+// readEnvstore reads the envstore (either in the YAML or in the journal layout) without decrypting its values.
+func readEnvstore(pth string) (models.EnvsSerializeModel, error) {
+	bytes, err := fileutil.ReadBytesFromFile(pth)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+
+	if isJournal(bytes) {
+		return parseJournal(bytes)
+	}
+	return parseEnvstoreYML(bytes)
+}
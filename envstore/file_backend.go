@@ -0,0 +1,83 @@
+package envstore
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// FileBackend stores the envstore in a single file, either in the classic YAML or in the append-only journal layout.
+type FileBackend struct {
+	pth string
+}
+
+// NewFileBackend ...
+func NewFileBackend(pth string) *FileBackend {
+	return &FileBackend{pth: pth}
+}
+
+// String ...
+func (b *FileBackend) String() string {
+	return b.pth
+}
+
+// Exists ...
+func (b *FileBackend) Exists(_ context.Context) (bool, error) {
+	return pathutil.IsPathExists(b.pth)
+}
+
+// Load ...
+func (b *FileBackend) Load(_ context.Context) (models.EnvsSerializeModel, error) {
+	return readEnvstore(b.pth)
+}
+
+// Save keeps the layout of the existing envstore, new envstores are written in the YAML layout.
+func (b *FileBackend) Save(ctx context.Context, envstore models.EnvsSerializeModel) error {
+	journal, err := b.isJournal(ctx)
+	if err != nil {
+		return err
+	}
+	return b.saveLayout(envstore, journal)
+}
+
+// Remove ...
+func (b *FileBackend) Remove(_ context.Context) error {
+	if err := os.Remove(b.pth); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Lock locks the <envstore>.lock file next to the envstore.
+func (b *FileBackend) Lock(ctx context.Context, timeout time.Duration) (func() error, error) {
+	lock, err := lockEnvstore(ctx, b.pth, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return lock.unlock, nil
+}
+
+func (b *FileBackend) isJournal(_ context.Context) (bool, error) {
+	return isJournalStore(b.pth)
+}
+
+func (b *FileBackend) saveLayout(envstore models.EnvsSerializeModel, journal bool) error {
+	var content []byte
+	var err error
+	if journal {
+		content, err = formatJournal(envstore)
+	} else {
+		content, err = formatYML(envstore)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(b.pth, content)
+}
+
+func (b *FileBackend) appendJournalRecords(_ context.Context, records []journalRecord) error {
+	return appendJournalRecords(b.pth, records)
+}
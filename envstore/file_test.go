@@ -0,0 +1,49 @@
+package envstore
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveDefaults(t *testing.T) {
+	// Filled env
+	env := models.EnvironmentItemModel{
+		"test_key": "test_value",
+		models.OptionsKey: models.EnvironmentItemOptionsModel{
+			Title:      pointers.NewStringPtr("test_title"),
+			IsTemplate: pointers.NewBoolPtr(!models.DefaultIsTemplate),
+
+			Description:       pointers.NewStringPtr(""),
+			Summary:           pointers.NewStringPtr(""),
+			ValueOptions:      []string{},
+			IsRequired:        pointers.NewBoolPtr(models.DefaultIsRequired),
+			IsDontChangeValue: pointers.NewBoolPtr(models.DefaultIsDontChangeValue),
+			IsExpand:          pointers.NewBoolPtr(models.DefaultIsExpand),
+			IsSensitive:       pointers.NewBoolPtr(models.DefaultIsSensitive),
+			SkipIfEmpty:       pointers.NewBoolPtr(models.DefaultSkipIfEmpty),
+		},
+	}
+
+	require.Equal(t, nil, removeDefaults(&env))
+
+	opts, err := env.GetOptions()
+	require.Equal(t, nil, err)
+
+	require.NotEqual(t, (*string)(nil), opts.Title)
+	require.Equal(t, "test_title", *opts.Title)
+	require.NotEqual(t, (*bool)(nil), opts.IsTemplate)
+	require.Equal(t, !models.DefaultIsTemplate, *opts.IsTemplate)
+
+	require.Equal(t, (*string)(nil), opts.Description)
+	require.Equal(t, (*string)(nil), opts.Summary)
+	require.Equal(t, 0, len(opts.ValueOptions))
+	require.Equal(t, (*bool)(nil), opts.IsRequired)
+	require.Equal(t, (*bool)(nil), opts.IsDontChangeValue)
+	require.Equal(t, (*bool)(nil), opts.IsExpand)
+	require.Equal(t, (*bool)(nil), opts.IsSensitive)
+	require.Equal(t, (*bool)(nil), opts.SkipIfEmpty)
+
+}
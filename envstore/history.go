@@ -0,0 +1,366 @@
+package envstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const historyFileSuffix = ".history"
+
+// historyFilePerm is the permissions of the new history files: they contain full copies of the envstore,
+// including its (possibly unencrypted) sensitive values.
+const historyFilePerm = 0600
+
+// Revision is the content of an envstore before a modification, recorded in the envstore's history.
+type Revision struct {
+	// ID grows with every recorded revision of the envstore
+	ID int `json:"id"`
+	// Command is the modification the revision was recorded before, like "add KEY" or "clear"
+	Command string    `json:"command"`
+	Time    time.Time `json:"time"`
+	// Hash is the sha256 hash of the envstore's content, empty if the envstore did not exist
+	Hash string `json:"hash,omitempty"`
+}
+
+// Checkpoint is a named content of an envstore, created by Envstore.Checkpoint.
+type Checkpoint struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Hash string    `json:"hash"`
+}
+
+// historyModel is the <envstore>.history file next to a file envstore.
+// The contents are stored once per hash, so the unchanged envstore doesn't grow the history.
+type historyModel struct {
+	NextID      int                      `json:"next_id"`
+	Revisions   []Revision               `json:"revisions"`
+	Checkpoints []Checkpoint             `json:"checkpoints,omitempty"`
+	Contents    map[string]storedContent `json:"contents,omitempty"`
+}
+
+// storedContent is a recorded content of the envstore. Consecutive revisions usually differ in a single env var,
+// so a content recorded before a later one is stored as a delta against the later content:
+// only the bytes between their common prefix and suffix are kept.
+type storedContent struct {
+	// Content is the content stored as is, if Base is empty
+	Content string `json:"content,omitempty"`
+	// Base is the hash of the content the delta is applied to
+	Base   string `json:"base,omitempty"`
+	Prefix int    `json:"prefix,omitempty"`
+	Suffix int    `json:"suffix,omitempty"`
+	Delta  string `json:"delta,omitempty"`
+}
+
+// newDelta returns the content stored as a delta against the base content (baseHash).
+func newDelta(content, base, baseHash string) storedContent {
+	prefix := 0
+	for prefix < len(content) && prefix < len(base) && content[prefix] == base[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(content)-prefix && suffix < len(base)-prefix && content[len(content)-1-suffix] == base[len(base)-1-suffix] {
+		suffix++
+	}
+	return storedContent{Base: baseHash, Prefix: prefix, Suffix: suffix, Delta: content[prefix : len(content)-suffix]}
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func readHistory(pth string) (historyModel, error) {
+	var history historyModel
+	bytes, err := os.ReadFile(pth)
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return historyModel{}, err
+	}
+	if err := json.Unmarshal(bytes, &history); err != nil {
+		return historyModel{}, fmt.Errorf("failed to parse history file (%s): %s", pth, err)
+	}
+	return history, nil
+}
+
+func writeHistory(pth string, history historyModel) error {
+	history.collectContents()
+	bytes, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomicallyWithPerm(pth, bytes, historyFilePerm)
+}
+
+// record adds the content (nil if the envstore did not exist) as a revision, keeping at most limit revisions.
+// The content of the previous revision is stored as a delta against the recorded content.
+func (h *historyModel) record(command string, content []byte, limit int) {
+	revision := Revision{ID: h.NextID + 1, Command: command, Time: time.Now().UTC()}
+	if content != nil {
+		revision.Hash = h.addContent(content)
+		if len(h.Revisions) > 0 {
+			h.storeAsDelta(h.Revisions[len(h.Revisions)-1].Hash, revision.Hash)
+		}
+	}
+	h.NextID = revision.ID
+	h.Revisions = append(h.Revisions, revision)
+	if len(h.Revisions) > limit {
+		h.Revisions = h.Revisions[len(h.Revisions)-limit:]
+	}
+}
+
+func (h *historyModel) addContent(content []byte) string {
+	hash := contentHash(content)
+	if h.Contents == nil {
+		h.Contents = map[string]storedContent{}
+	}
+	if _, ok := h.Contents[hash]; !ok {
+		h.Contents[hash] = storedContent{Content: string(content)}
+	}
+	return hash
+}
+
+// storeAsDelta stores the content (hash) as a delta against the base content (baseHash).
+// Only contents stored as is are converted, against a content stored as is, so the deltas never form a cycle.
+func (h *historyModel) storeAsDelta(hash, baseHash string) {
+	if hash == "" || hash == baseHash {
+		return
+	}
+	stored, ok := h.Contents[hash]
+	if !ok || stored.Base != "" {
+		return
+	}
+	base, ok := h.Contents[baseHash]
+	if !ok || base.Base != "" {
+		return
+	}
+	h.Contents[hash] = newDelta(stored.Content, base.Content, baseHash)
+}
+
+// content returns the recorded content (hash), applying its deltas.
+func (h historyModel) content(hash string) (string, bool) {
+	stored, ok := h.Contents[hash]
+	if !ok {
+		return "", false
+	}
+	if stored.Base == "" {
+		return stored.Content, true
+	}
+	base, ok := h.content(stored.Base)
+	if !ok || stored.Prefix+stored.Suffix > len(base) {
+		return "", false
+	}
+	return base[:stored.Prefix] + stored.Delta + base[len(base)-stored.Suffix:], true
+}
+
+// collectContents drops the contents, which are not referenced by any revision or checkpoint
+// (or by the delta of a referenced content).
+func (h *historyModel) collectContents() {
+	referenced := map[string]bool{}
+	reference := func(hash string) {
+		for hash != "" && !referenced[hash] {
+			referenced[hash] = true
+			hash = h.Contents[hash].Base
+		}
+	}
+	for _, revision := range h.Revisions {
+		reference(revision.Hash)
+	}
+	for _, checkpoint := range h.Checkpoints {
+		reference(checkpoint.Hash)
+	}
+	for hash := range h.Contents {
+		if !referenced[hash] {
+			delete(h.Contents, hash)
+		}
+	}
+}
+
+func (h historyModel) checkpoint(name string) (Checkpoint, bool) {
+	for _, checkpoint := range h.Checkpoints {
+		if checkpoint.Name == name {
+			return checkpoint, true
+		}
+	}
+	return Checkpoint{}, false
+}
+
+func (b *FileBackend) historyPath() string {
+	return b.pth + historyFileSuffix
+}
+
+// content returns the stored bytes of the envstore, nil if it does not exist.
+func (b *FileBackend) content() ([]byte, error) {
+	content, err := os.ReadFile(b.pth)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return content, nil
+}
+
+// restoreContent writes back a recorded content of the envstore, an empty hash removes the envstore.
+func (b *FileBackend) restoreContent(history historyModel, hash string) error {
+	if hash == "" {
+		return b.Remove(context.Background())
+	}
+	content, ok := history.content(hash)
+	if !ok {
+		return fmt.Errorf("content (%s) is missing from history file: %s", hash, b.historyPath())
+	}
+	return writeFileAtomically(b.pth, []byte(content))
+}
+
+func (s *Envstore) historyBackend() (*FileBackend, error) {
+	fileBackend, ok := s.backend.(*FileBackend)
+	if !ok {
+		return nil, fmt.Errorf("history is not supported by envstore: %s", s.Path())
+	}
+	return fileBackend, nil
+}
+
+// modifyRecorded is modify for the modifications, which are recorded in the history of file envstores
+// (if Options.HistoryLimit is set). The content is only recorded if the modification changed it.
+func (s *Envstore) modifyRecorded(ctx context.Context, command string, fn func() error) error {
+	return s.modify(ctx, func() error {
+		fileBackend, ok := s.backend.(*FileBackend)
+		if !ok || s.opts.HistoryLimit <= 0 {
+			return fn()
+		}
+
+		before, err := fileBackend.content()
+		if err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		after, err := fileBackend.content()
+		if err != nil {
+			return err
+		}
+		if (before == nil) == (after == nil) && string(before) == string(after) {
+			return nil
+		}
+
+		history, err := readHistory(fileBackend.historyPath())
+		if err != nil {
+			return fmt.Errorf("failed to record envstore history: %s", err)
+		}
+		history.record(command, before, s.opts.HistoryLimit)
+		if err := writeHistory(fileBackend.historyPath(), history); err != nil {
+			return fmt.Errorf("failed to record envstore history: %s", err)
+		}
+		return nil
+	})
+}
+
+// History returns the recorded revisions (oldest first) and the checkpoints of a file envstore.
+func (s *Envstore) History(_ context.Context) ([]Revision, []Checkpoint, error) {
+	fileBackend, err := s.historyBackend()
+	if err != nil {
+		return nil, nil, err
+	}
+	history, err := readHistory(fileBackend.historyPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	return history.Revisions, history.Checkpoints, nil
+}
+
+// Undo restores the envstore's content recorded before its last recorded modification, and drops that revision
+// from the history: calling it repeatedly steps back through the history. Undo itself is not recorded.
+func (s *Envstore) Undo(ctx context.Context) (Revision, error) {
+	fileBackend, err := s.historyBackend()
+	if err != nil {
+		return Revision{}, err
+	}
+
+	var revision Revision
+	err = s.modify(ctx, func() error {
+		history, err := readHistory(fileBackend.historyPath())
+		if err != nil {
+			return err
+		}
+		if len(history.Revisions) == 0 {
+			return fmt.Errorf("no revision to undo in the history of envstore: %s", s.Path())
+		}
+
+		revision = history.Revisions[len(history.Revisions)-1]
+		if err := fileBackend.restoreContent(history, revision.Hash); err != nil {
+			return err
+		}
+		history.Revisions = history.Revisions[:len(history.Revisions)-1]
+		return writeHistory(fileBackend.historyPath(), history)
+	})
+	return revision, err
+}
+
+// Checkpoint saves the envstore's current content with the name, an existing checkpoint with the same name is replaced.
+// Checkpoints are kept regardless of the history limit.
+func (s *Envstore) Checkpoint(ctx context.Context, name string) error {
+	if name == "" {
+		return errors.New("no checkpoint name provided")
+	}
+	fileBackend, err := s.historyBackend()
+	if err != nil {
+		return err
+	}
+
+	return s.modify(ctx, func() error {
+		content, err := fileBackend.content()
+		if err != nil {
+			return err
+		}
+		if content == nil {
+			return errors.New("EnvStore not found in path:" + s.Path())
+		}
+
+		history, err := readHistory(fileBackend.historyPath())
+		if err != nil {
+			return err
+		}
+		checkpoint := Checkpoint{Name: name, Time: time.Now().UTC(), Hash: history.addContent(content)}
+		replaced := false
+		for i := range history.Checkpoints {
+			if history.Checkpoints[i].Name == name {
+				history.Checkpoints[i] = checkpoint
+				replaced = true
+			}
+		}
+		if !replaced {
+			history.Checkpoints = append(history.Checkpoints, checkpoint)
+		}
+		return writeHistory(fileBackend.historyPath(), history)
+	})
+}
+
+// Restore replaces the envstore's content with the named checkpoint, the restore is recorded in the history (so it can be undone).
+func (s *Envstore) Restore(ctx context.Context, name string) error {
+	fileBackend, err := s.historyBackend()
+	if err != nil {
+		return err
+	}
+
+	return s.modifyRecorded(ctx, "restore "+name, func() error {
+		history, err := readHistory(fileBackend.historyPath())
+		if err != nil {
+			return err
+		}
+		checkpoint, ok := history.checkpoint(name)
+		if !ok {
+			return fmt.Errorf("checkpoint (%s) not found in the history of envstore: %s", name, s.Path())
+		}
+		return fileBackend.restoreContent(history, checkpoint.Hash)
+	})
+}
@@ -0,0 +1,184 @@
+package envstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func openTestEnvstoreWithHistory(t *testing.T, pth string, limit int) *Envstore {
+	store, err := New(pth, Options{EnvSource: testEnvSource{}, HistoryLimit: limit})
+	require.NoError(t, err)
+	return store
+}
+
+func requireEnvValue(t *testing.T, store *Envstore, key, expected string) {
+	value, found, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, expected, value)
+}
+
+func TestEnvstoreHistory(t *testing.T) {
+	ctx := context.Background()
+
+	for _, journal := range []bool{false, true} {
+		pth := filepath.Join(t.TempDir(), ".envstore.yml")
+		store := openTestEnvstoreWithHistory(t, pth, 50)
+		require.NoError(t, store.Init(ctx, InitOptions{Journal: journal}))
+		require.NoError(t, store.Add(ctx, "GREETING", "hello", AddOptions{}))
+		require.NoError(t, store.Add(ctx, "GREETING", "hi", AddOptions{}))
+		require.NoError(t, store.Clear(ctx))
+		// an unchanged envstore is not recorded
+		require.NoError(t, store.Remove(ctx, "GREETING"))
+
+		revisions, checkpoints, err := store.History(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(checkpoints))
+		require.Equal(t, 4, len(revisions))
+		require.Equal(t, "init", revisions[0].Command)
+		require.Equal(t, "", revisions[0].Hash)
+		require.Equal(t, "add GREETING", revisions[1].Command)
+		require.Equal(t, "add GREETING", revisions[2].Command)
+		require.Equal(t, "clear", revisions[3].Command)
+		require.Equal(t, 4, revisions[3].ID)
+
+		revision, err := store.Undo(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "clear", revision.Command)
+		requireEnvValue(t, store, "GREETING", "hi")
+
+		_, err = store.Undo(ctx)
+		require.NoError(t, err)
+		requireEnvValue(t, store, "GREETING", "hello")
+
+		_, err = store.Undo(ctx)
+		require.NoError(t, err)
+		_, err = store.Undo(ctx)
+		require.NoError(t, err)
+		_, err = os.Stat(pth)
+		require.True(t, os.IsNotExist(err))
+
+		_, err = store.Undo(ctx)
+		require.EqualError(t, err, "no revision to undo in the history of envstore: "+pth)
+	}
+}
+
+func TestEnvstoreHistory_Limit(t *testing.T) {
+	ctx := context.Background()
+	pth := filepath.Join(t.TempDir(), ".envstore.yml")
+
+	store := openTestEnvstoreWithHistory(t, pth, 2)
+	require.NoError(t, store.Init(ctx, InitOptions{}))
+	for _, value := range []string{"1", "2", "3"} {
+		require.NoError(t, store.Add(ctx, "COUNT", value, AddOptions{}))
+	}
+
+	revisions, _, err := store.History(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(revisions))
+	require.Equal(t, 3, revisions[0].ID)
+	require.Equal(t, 4, revisions[1].ID)
+
+	history, err := readHistory(pth + historyFileSuffix)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(history.Contents))
+
+	// no history is recorded without a limit
+	pth = filepath.Join(t.TempDir(), ".envstore.yml")
+	store = openTestEnvstoreWithHistory(t, pth, 0)
+	require.NoError(t, store.Init(ctx, InitOptions{}))
+	require.NoError(t, store.Add(ctx, "COUNT", "1", AddOptions{}))
+	_, err = os.Stat(pth + historyFileSuffix)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestEnvstoreHistory_Deltas(t *testing.T) {
+	ctx := context.Background()
+	pth := filepath.Join(t.TempDir(), ".envstore.yml")
+
+	store := openTestEnvstoreWithHistory(t, pth, 50)
+	require.NoError(t, store.Init(ctx, InitOptions{}))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Add(ctx, fmt.Sprintf("KEY_%d", i), fmt.Sprintf("value %d", i), AddOptions{}))
+	}
+	require.NoError(t, store.Add(ctx, "KEY_5", "changed", AddOptions{}))
+
+	// only the content recorded last is stored as is
+	history, err := readHistory(pth + historyFileSuffix)
+	require.NoError(t, err)
+	require.Equal(t, 11, len(history.Contents))
+	var contentsAsIs []string
+	for hash, stored := range history.Contents {
+		if stored.Base == "" {
+			contentsAsIs = append(contentsAsIs, hash)
+		}
+	}
+	require.Equal(t, []string{history.Revisions[len(history.Revisions)-1].Hash}, contentsAsIs)
+
+	_, err = store.Undo(ctx)
+	require.NoError(t, err)
+	requireEnvValue(t, store, "KEY_5", "value 5")
+	for i := 9; i >= 0; i-- {
+		requireEnvValue(t, store, fmt.Sprintf("KEY_%d", i), fmt.Sprintf("value %d", i))
+		_, err = store.Undo(ctx)
+		require.NoError(t, err)
+	}
+	envs, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(envs))
+}
+
+func TestEnvstoreCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	pth := filepath.Join(t.TempDir(), ".envstore.yml")
+
+	store := openTestEnvstoreWithHistory(t, pth, 1)
+	require.NoError(t, store.Init(ctx, InitOptions{}))
+	require.NoError(t, store.Add(ctx, "STAGE", "release", AddOptions{}))
+	require.NoError(t, store.Checkpoint(ctx, "release"))
+	require.NoError(t, store.Add(ctx, "STAGE", "dev", AddOptions{}))
+	require.NoError(t, store.Add(ctx, "STAGE", "test", AddOptions{}))
+
+	// the checkpoint is kept, even if its revision is dropped by the limit
+	require.NoError(t, store.Restore(ctx, "release"))
+	requireEnvValue(t, store, "STAGE", "release")
+
+	revisions, checkpoints, err := store.History(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(revisions))
+	require.Equal(t, "restore release", revisions[0].Command)
+	require.Equal(t, 1, len(checkpoints))
+	require.Equal(t, "release", checkpoints[0].Name)
+
+	_, err = store.Undo(ctx)
+	require.NoError(t, err)
+	requireEnvValue(t, store, "STAGE", "test")
+
+	require.EqualError(t, store.Restore(ctx, "missing"), "checkpoint (missing) not found in the history of envstore: "+pth)
+	require.EqualError(t, store.Checkpoint(ctx, ""), "no checkpoint name provided")
+
+	memoryStore := NewWithBackend(NewMemoryBackend(), Options{})
+	require.EqualError(t, memoryStore.Checkpoint(ctx, "release"), "history is not supported by envstore: "+memoryStore.Path())
+}
+
+func TestEnvstoreHistory_Permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on Windows")
+	}
+
+	ctx := context.Background()
+	pth := filepath.Join(t.TempDir(), ".envstore.yml")
+	require.NoError(t, os.WriteFile(pth, []byte("envs: []\n"), 0644))
+	store := openTestEnvstoreWithHistory(t, pth, 10)
+	require.NoError(t, store.Add(ctx, "TOKEN", "s3cr3t", AddOptions{}))
+
+	info, err := os.Stat(pth + historyFileSuffix)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(historyFilePerm), info.Mode().Perm())
+}
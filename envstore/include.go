@@ -0,0 +1,113 @@
+package envstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// resolveEnvstoreIncludes returns the envs of the envstore with the envs of its includes spliced in before its own envs.
+// The includes are resolved recursively in declaration order (the matches of a glob in lexical order),
+// relative paths are relative to baseDir (the directory of the including envstore).
+// includeStack holds the absolute paths of the envstores being resolved, to detect include cycles.
+// The envstore defaults are applied to the envs of each envstore (with a warning about the templates they don't render),
+// and their value source paths are resolved.
+// The $$ of the envstores older than models.DollarEscapeFormatVersion are rewritten, so the envs are evaluated with the escape.
+func resolveEnvstoreIncludes(envstore models.EnvsSerializeModel, baseDir string, includeStack []string) ([]models.EnvironmentItemModel, error) {
+	if err := upgradeDollarSigns(envstore); err != nil {
+		return nil, err
+	}
+	ownEnvs, err := applyDefaults(envstore)
+	if err != nil {
+		return nil, err
+	}
+	if err := warnUnrenderedTemplates(ownEnvs); err != nil {
+		return nil, err
+	}
+	if err := resolveValueSourcePaths(ownEnvs, baseDir); err != nil {
+		return nil, err
+	}
+	if len(envstore.Include) == 0 {
+		return ownEnvs, nil
+	}
+
+	envs := []models.EnvironmentItemModel{}
+	for _, include := range envstore.Include {
+		if include.Path == "" {
+			return nil, errors.New("include path is not specified")
+		}
+
+		pattern := include.Path
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+
+		pths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include (%s): %s", include.Path, err)
+		}
+		if len(pths) == 0 {
+			if include.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("included envstore not found: %s (mark the include optional if it may be missing)", pattern)
+		}
+
+		for _, pth := range pths {
+			includedEnvs, err := readIncludedEnvstore(pth, includeStack)
+			if err != nil {
+				return nil, err
+			}
+			envs = append(envs, includedEnvs...)
+		}
+	}
+
+	return append(envs, ownEnvs...), nil
+}
+
+// readIncludedEnvstore reads the envstore at pth with its includes resolved.
+func readIncludedEnvstore(pth string, includeStack []string) ([]models.EnvironmentItemModel, error) {
+	absPth, err := filepath.Abs(pth)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, includingPth := range includeStack {
+		if includingPth == absPth {
+			cycle := append(append([]string{}, includeStack...), absPth)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	envstore, err := readEnvstore(absPth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read included envstore (%s): %s", absPth, err)
+	}
+
+	return resolveEnvstoreIncludes(envstore, filepath.Dir(absPth), append(includeStack, absPth))
+}
+
+// loadResolvedEnvstore loads the backend's envstore with its includes resolved and its defaults applied,
+// without decrypting its values.
+func loadResolvedEnvstore(ctx context.Context, backend Backend) (models.EnvsSerializeModel, error) {
+	envstore, err := backend.Load(ctx)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+
+	baseDir, includeStack, err := includeRoot(backend)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+
+	envs, err := resolveEnvstoreIncludes(envstore, baseDir, includeStack)
+	if err != nil {
+		return models.EnvsSerializeModel{}, err
+	}
+	envstore.Envs = envs
+	return envstore, nil
+}
@@ -0,0 +1,105 @@
+package envstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func envKeys(t *testing.T, envs []models.EnvironmentItemModel) []string {
+	var keys []string
+	for _, env := range envs {
+		key, _, err := env.GetKeyValuePair()
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestIncludes(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(tmpDir, "shared"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "shared", "a.yml"), []byte(`envs:
+- A: a
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "shared", "b.yml"), []byte(`include:
+- ../common.yml
+envs:
+- B: b
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "common.yml"), []byte(`envs:
+- COMMON: common
+`), 0644))
+
+	envStorePth := filepath.Join(tmpDir, ".envstore.yml")
+	store := openTestEnvstore(t, envStorePth)
+	require.NoError(t, os.WriteFile(envStorePth, []byte(`include:
+- shared/*.yml
+- path: missing.yml
+  optional: true
+envs:
+- OWN: own
+`), 0644))
+
+	t.Log("included envs are declared before the own envs")
+	{
+		envs, err := store.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"A", "COMMON", "B", "OWN"}, envKeys(t, envs))
+	}
+
+	t.Log("write commands leave the includes alone")
+	{
+		require.NoError(t, store.Add(ctx, "ADDED", "added", AddOptions{}))
+
+		ownEnvs, err := store.ListOwn(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"OWN", "ADDED"}, envKeys(t, ownEnvs))
+
+		envstore, err := readEnvstore(envStorePth)
+		require.NoError(t, err)
+		require.Equal(t, []models.EnvstoreIncludeModel{
+			{Path: "shared/*.yml"},
+			{Path: "missing.yml", Optional: true},
+		}, envstore.Include)
+
+		envs, err := store.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"A", "COMMON", "B", "OWN", "ADDED"}, envKeys(t, envs))
+	}
+
+	t.Log("missing include")
+	{
+		pth := filepath.Join(tmpDir, "missing-include.yml")
+		require.NoError(t, os.WriteFile(pth, []byte(`include:
+- missing.yml
+envs: []
+`), 0644))
+
+		_, err := openTestEnvstore(t, pth).List(ctx)
+		require.EqualError(t, err, "included envstore not found: "+filepath.Join(tmpDir, "missing.yml")+" (mark the include optional if it may be missing)")
+	}
+
+	t.Log("include cycle")
+	{
+		aPth := filepath.Join(tmpDir, "cycle-a.yml")
+		bPth := filepath.Join(tmpDir, "cycle-b.yml")
+		require.NoError(t, os.WriteFile(aPth, []byte(`include:
+- cycle-b.yml
+envs: []
+`), 0644))
+		require.NoError(t, os.WriteFile(bPth, []byte(`include:
+- cycle-a.yml
+envs: []
+`), 0644))
+
+		_, err := openTestEnvstore(t, aPth).List(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "include cycle: "+aPth+" -> "+bPth+" -> "+aPth)
+	}
+}
@@ -0,0 +1,333 @@
+package envstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	log "github.com/sirupsen/logrus"
+)
+
+// A journal envstore starts with the journalHeader line, followed by one JSON encoded journalRecord per line.
+// Adding, unsetting, removing or importing envs appends their records instead of rewriting the whole envstore,
+// reading the envstore replays the records in order.
+const journalHeader = "# envman journal v1"
+
+const (
+	journalOpAdd        = "add"
+	journalOpUnset      = "unset"
+	journalOpRemove     = "remove"
+	journalOpRecipients = "recipients"
+	journalOpVersion    = "format_version"
+	journalOpDefaults   = "defaults"
+	journalOpInclude    = "include"
+)
+
+type journalRecord struct {
+	Op         string                        `json:"op"`
+	Env        models.EnvironmentItemModel   `json:"env,omitempty"`
+	Key        string                        `json:"key,omitempty"`
+	Replace    bool                          `json:"replace,omitempty"`
+	Recipients []string                      `json:"recipients,omitempty"`
+	Version    int                           `json:"format_version,omitempty"`
+	Defaults   *models.EnvstoreDefaultsModel `json:"defaults,omitempty"`
+	Include    []models.EnvstoreIncludeModel `json:"include,omitempty"`
+}
+
+func isJournal(content []byte) bool {
+	firstLine, _, _ := bytes.Cut(content, []byte("\n"))
+	return strings.TrimSpace(string(firstLine)) == journalHeader
+}
+
+func isJournalStore(pth string) (bool, error) {
+	file, err := os.Open(pth)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close envstore: %s", err)
+		}
+	}()
+
+	header := make([]byte, len(journalHeader)+1)
+	n, err := file.Read(header)
+	if err != nil && n == 0 {
+		// empty file
+		return false, nil
+	}
+	return isJournal(header[:n]), nil
+}
+
+// ParseJournal replays the records of a journal envstore.
+func ParseJournal(content []byte) ([]models.EnvironmentItemModel, error) {
+	envstore, err := parseJournal(content)
+	if err != nil {
+		return []models.EnvironmentItemModel{}, err
+	}
+	return envstore.Envs, nil
+}
+
+func parseJournal(content []byte) (models.EnvsSerializeModel, error) {
+	if !isJournal(content) {
+		return models.EnvsSerializeModel{}, errors.New("not an envman journal")
+	}
+
+	lines := strings.Split(string(content), "\n")
+	envstore := models.EnvsSerializeModel{Envs: []models.EnvironmentItemModel{}}
+	for i, line := range lines[1:] {
+		lineNum := i + 2
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			if lineNum == len(lines) {
+				// The last record has no line ending: the process writing it was interrupted.
+				log.Warnf("Ignoring incomplete journal record at line %d", lineNum)
+				break
+			}
+			return models.EnvsSerializeModel{}, fmt.Errorf("invalid journal record at line %d: %s", lineNum, err)
+		}
+
+		if err := replayJournalRecord(&envstore, record); err != nil {
+			return models.EnvsSerializeModel{}, fmt.Errorf("failed to replay journal record at line %d: %s", lineNum, err)
+		}
+	}
+
+	return envstore, nil
+}
+
+// isJournalHeaderRecord returns whether the record belongs to the header of the journal (the records before the env records).
+func isJournalHeaderRecord(record journalRecord) bool {
+	switch record.Op {
+	case journalOpRecipients, journalOpVersion, journalOpDefaults, journalOpInclude:
+		return true
+	default:
+		return false
+	}
+}
+
+// readJournalHeader reads the journal envstore up to its first env record, the returned envstore has no envs.
+// journal is false if the envstore doesn't exist, or it's not a journal.
+func readJournalHeader(pth string) (envstore models.EnvsSerializeModel, journal bool, err error) {
+	file, err := os.Open(pth)
+	if os.IsNotExist(err) {
+		return models.EnvsSerializeModel{}, false, nil
+	} else if err != nil {
+		return models.EnvsSerializeModel{}, false, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close envstore: %s", err)
+		}
+	}()
+
+	reader := bufio.NewReader(file)
+	line, readErr := reader.ReadString('\n')
+	if !isJournal([]byte(line)) {
+		return models.EnvsSerializeModel{}, false, nil
+	}
+
+	for lineNum := 2; readErr == nil; lineNum++ {
+		line, readErr = reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return models.EnvsSerializeModel{}, false, readErr
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			if readErr == io.EOF {
+				// incomplete last record, see parseJournal
+				break
+			}
+			return models.EnvsSerializeModel{}, false, fmt.Errorf("invalid journal record at line %d: %s", lineNum, err)
+		}
+		if !isJournalHeaderRecord(record) {
+			break
+		}
+		if err := replayJournalRecord(&envstore, record); err != nil {
+			return models.EnvsSerializeModel{}, false, fmt.Errorf("failed to replay journal record at line %d: %s", lineNum, err)
+		}
+	}
+
+	return envstore, true, nil
+}
+
+func replayJournalRecord(envstore *models.EnvsSerializeModel, record journalRecord) error {
+	switch record.Op {
+	case journalOpAdd:
+		if err := record.Env.NormalizeValidateFillDefaults(); err != nil {
+			return err
+		}
+		envs, err := UpsertEnv(envstore.Envs, record.Env, record.Replace)
+		if err != nil {
+			return err
+		}
+		envstore.Envs = envs
+	case journalOpUnset:
+		env, err := newUnsetEnv(record.Key)
+		if err != nil {
+			return err
+		}
+		envs, err := UpsertEnv(envstore.Envs, env, record.Replace)
+		if err != nil {
+			return err
+		}
+		envstore.Envs = envs
+	case journalOpRemove:
+		envs, err := removeEnv(envstore.Envs, record.Key)
+		if err != nil {
+			return err
+		}
+		envstore.Envs = envs
+	case journalOpRecipients:
+		envstore.Recipients = record.Recipients
+	case journalOpVersion:
+		if err := checkEnvstoreFormatVersion(record.Version); err != nil {
+			return err
+		}
+		envstore.FormatVersion = record.Version
+	case journalOpDefaults:
+		envstore.Defaults = record.Defaults
+	case journalOpInclude:
+		envstore.Include = record.Include
+	default:
+		return fmt.Errorf("unknown operation: %s", record.Op)
+	}
+	return nil
+}
+
+func newUnsetEnv(key string) (models.EnvironmentItemModel, error) {
+	env := models.EnvironmentItemModel{
+		key: "",
+		models.OptionsKey: models.EnvironmentItemOptionsModel{
+			Unset: pointers.NewBoolPtr(true),
+		},
+	}
+	if err := env.NormalizeValidateFillDefaults(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+func newJournalAddRecord(env models.EnvironmentItemModel, replace bool) (journalRecord, error) {
+	// do not modify the caller's env, removeDefaults writes into the map
+	envCopy := models.EnvironmentItemModel{}
+	for key, value := range env {
+		envCopy[key] = value
+	}
+
+	formatted, err := generateFormattedYMLForEnvModels([]models.EnvironmentItemModel{envCopy})
+	if err != nil {
+		return journalRecord{}, err
+	}
+
+	return journalRecord{
+		Op:      journalOpAdd,
+		Env:     formatted.Envs[0],
+		Replace: replace,
+	}, nil
+}
+
+// appendJournalRecords appends the records to the journal with a single write.
+func appendJournalRecords(pth string, records []journalRecord) error {
+	var lines []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+
+	file, err := os.OpenFile(pth, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	// drop the incomplete record of a previously interrupted write
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if info.Size() > 0 {
+		lastByte := make([]byte, 1)
+		if _, err := file.ReadAt(lastByte, info.Size()-1); err != nil {
+			_ = file.Close()
+			return err
+		}
+		if lastByte[0] != '\n' {
+			content := make([]byte, info.Size())
+			if _, err := file.ReadAt(content, 0); err != nil {
+				_ = file.Close()
+				return err
+			}
+			if err := file.Truncate(int64(bytes.LastIndexByte(content, '\n') + 1)); err != nil {
+				_ = file.Close()
+				return err
+			}
+		}
+	}
+
+	if _, err := file.Write(lines); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// formatJournal returns the envstore in the journal layout, with one record per env.
+func formatJournal(envstore models.EnvsSerializeModel) ([]byte, error) {
+	content := []byte(journalHeader + "\n")
+
+	records := []journalRecord{{Op: journalOpVersion, Version: envstore.FormatVersion}}
+	if len(envstore.Recipients) > 0 {
+		records = append(records, journalRecord{Op: journalOpRecipients, Recipients: envstore.Recipients})
+	}
+	if envstore.Defaults != nil {
+		records = append(records, journalRecord{Op: journalOpDefaults, Defaults: envstore.Defaults})
+	}
+	if len(envstore.Include) > 0 {
+		records = append(records, journalRecord{Op: journalOpInclude, Include: envstore.Include})
+	}
+
+	for _, env := range envstore.Envs {
+		record, err := newJournalAddRecord(env, false)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, line...)
+		content = append(content, '\n')
+	}
+
+	return content, nil
+}
@@ -0,0 +1,214 @@
+package envstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournalEnvStore(t *testing.T) {
+	ctx := context.Background()
+	envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+	store := openTestEnvstore(t, envStorePth)
+	require.NoError(t, store.Init(ctx, InitOptions{Journal: true}))
+
+	require.NoError(t, store.Add(ctx, "A", "first", AddOptions{}))
+	require.NoError(t, store.Add(ctx, "B", "multi\nline", AddOptions{EnvOptions: models.EnvironmentItemOptionsModel{IsExpand: pointers.NewBoolPtr(false), IsSensitive: pointers.NewBoolPtr(true)}}))
+	require.NoError(t, store.Add(ctx, "A", "second", AddOptions{}))
+	require.NoError(t, store.Unset(ctx, "C", AddOptions{}))
+
+	content, err := os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Equal(t, journalHeader, lines[0])
+	require.Equal(t, `{"op":"format_version","format_version":1}`, lines[1])
+	require.Equal(t, 6, len(lines))
+
+	envs, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(envs))
+
+	key, value, err := envs[0].GetKeyValuePair()
+	require.NoError(t, err)
+	require.Equal(t, "A", key)
+	require.Equal(t, "second", value)
+
+	key, value, err = envs[1].GetKeyValuePair()
+	require.NoError(t, err)
+	require.Equal(t, "B", key)
+	require.Equal(t, "multi\nline", value)
+	opts, err := envs[1].GetOptions()
+	require.NoError(t, err)
+	require.False(t, *opts.IsExpand)
+	require.True(t, *opts.IsSensitive)
+
+	key, _, err = envs[2].GetKeyValuePair()
+	require.NoError(t, err)
+	require.Equal(t, "C", key)
+	opts, err = envs[2].GetOptions()
+	require.NoError(t, err)
+	require.True(t, *opts.Unset)
+
+	require.NoError(t, store.Compact(ctx))
+
+	content, err = os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	require.Equal(t, `format_version: 1
+envs:
+- A: second
+- B: |-
+    multi
+    line
+  opts:
+    is_expand: false
+    is_sensitive: true
+- C: ""
+  opts:
+    unset: true
+`, string(content))
+
+	compactedEnvs, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, envs, compactedEnvs)
+}
+
+func TestJournalEnvStore_Clear(t *testing.T) {
+	ctx := context.Background()
+	envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+	store := openTestEnvstore(t, envStorePth)
+	require.NoError(t, store.Init(ctx, InitOptions{Journal: true}))
+	require.NoError(t, store.Add(ctx, "A", "B", AddOptions{}))
+
+	require.NoError(t, store.Clear(ctx))
+
+	journal, err := isJournalStore(envStorePth)
+	require.NoError(t, err)
+	require.True(t, journal)
+
+	envs, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []models.EnvironmentItemModel{}, envs)
+}
+
+func TestJournalEnvStore_Include(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.yml"), []byte("envs:\n- BASE: base\n"), 0644))
+
+	envStorePth := filepath.Join(dir, ".envstore.yml")
+	store := openTestEnvstore(t, envStorePth)
+	require.NoError(t, store.Init(ctx, InitOptions{Journal: true}))
+	require.NoError(t, os.WriteFile(envStorePth, []byte(journalHeader+"\n"+
+		`{"op":"format_version","format_version":2}`+"\n"+
+		`{"op":"include","include":[{"path":"base.yml"}]}`+"\n"), 0644))
+
+	requireEnvValue(t, store, "BASE", "base")
+
+	// the include is kept by the rewrites of the journal
+	require.NoError(t, store.Add(ctx, "A", "B", AddOptions{}))
+	require.NoError(t, store.Clear(ctx))
+	require.NoError(t, store.Add(ctx, "A", "B", AddOptions{}))
+
+	journal, err := isJournalStore(envStorePth)
+	require.NoError(t, err)
+	require.True(t, journal)
+	requireEnvValue(t, store, "BASE", "base")
+	requireEnvValue(t, store, "A", "B")
+}
+
+func TestJournalEnvStore_Append(t *testing.T) {
+	ctx := context.Background()
+	envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+	store := openTestEnvstore(t, envStorePth)
+	require.NoError(t, store.Init(ctx, InitOptions{Journal: true}))
+	require.NoError(t, store.Add(ctx, "A", "first", AddOptions{}))
+
+	// the journal is not replayed, only its header is read: the invalid record is kept as it is
+	invalidRecord := `{"op":"unknown"}`
+	content, err := os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	content = append(content, invalidRecord+"\n"...)
+	require.NoError(t, os.WriteFile(envStorePth, content, 0644))
+
+	require.NoError(t, store.Add(ctx, "A", "second", AddOptions{}))
+	require.NoError(t, store.Unset(ctx, "B", AddOptions{}))
+	require.NoError(t, store.Import(ctx, []models.EnvironmentItemModel{{"C": "c"}, {"D": "d"}}, AddOptions{Append: true}))
+
+	appended, err := os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(appended), string(content)))
+	require.Equal(t, []string{
+		`{"op":"add","env":{"A":"second"},"replace":true}`,
+		`{"op":"unset","key":"B","replace":true}`,
+		`{"op":"add","env":{"C":"c"}}`,
+		`{"op":"add","env":{"D":"d"}}`,
+	}, strings.Split(strings.TrimSpace(strings.TrimPrefix(string(appended), string(content))), "\n"))
+
+	t.Log("imported envs are appended after the validation too")
+	{
+		envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+		store := openTestEnvstore(t, envStorePth)
+		require.NoError(t, store.Init(ctx, InitOptions{Journal: true}))
+		require.NoError(t, store.Add(ctx, "A", "first", AddOptions{}))
+
+		content, err := os.ReadFile(envStorePth)
+		require.NoError(t, err)
+
+		var validated []string
+		validate := func(envs []models.EnvironmentItemModel, key, value string, opts AddOptions) (string, AddOptions, error) {
+			validated = append(validated, key)
+			return value, opts, nil
+		}
+		require.NoError(t, store.Import(ctx, []models.EnvironmentItemModel{{"B": "b"}, {"A": "second"}}, AddOptions{Validate: validate}))
+		require.Equal(t, []string{"B", "A"}, validated)
+
+		appended, err := os.ReadFile(envStorePth)
+		require.NoError(t, err)
+		require.Equal(t, string(content)+`{"op":"add","env":{"B":"b"},"replace":true}`+"\n"+`{"op":"add","env":{"A":"second"},"replace":true}`+"\n", string(appended))
+		requireEnvValue(t, store, "A", "second")
+		requireEnvValue(t, store, "B", "b")
+	}
+}
+
+func TestParseJournal(t *testing.T) {
+	ctx := context.Background()
+	t.Log("incomplete last record is ignored")
+	{
+		content := journalHeader + "\n" + `{"op":"add","env":{"A":"B"},"replace":true}` + "\n" + `{"op":"add","env":{"C":`
+		envs, err := ParseJournal([]byte(content))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(envs))
+	}
+
+	t.Log("invalid record in the middle of the journal")
+	{
+		content := journalHeader + "\n" + `{"op":"add","env":{"C":` + "\n" + `{"op":"add","env":{"A":"B"},"replace":true}` + "\n"
+		_, err := ParseJournal([]byte(content))
+		require.EqualError(t, err, "invalid journal record at line 2: unexpected end of JSON input")
+	}
+
+	t.Log("unknown operation")
+	{
+		content := journalHeader + "\n" + `{"op":"rename","key":"A"}` + "\n"
+		_, err := ParseJournal([]byte(content))
+		require.EqualError(t, err, "failed to replay journal record at line 2: unknown operation: rename")
+	}
+
+	t.Log("appending after an incomplete record")
+	{
+		envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+		store := openTestEnvstore(t, envStorePth)
+		require.NoError(t, os.WriteFile(envStorePth, []byte(journalHeader+"\n"+`{"op":"add","env":{"C":`), 0644))
+		require.NoError(t, store.Add(ctx, "A", "B", AddOptions{}))
+
+		envs, err := store.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(envs))
+	}
+}
@@ -0,0 +1,123 @@
+package envstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/bitrise-io/envman/v2/env"
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// Layers is an ordered list of envstores, which are evaluated as one:
+// the envs of the later layers can reference and override the envs of the earlier ones.
+// Modifications are made on a single layer (see Layer).
+type Layers struct {
+	layers []*Envstore
+	opts   Options
+}
+
+// NewLayers returns the envstores at the locations (see New) as layers, in order.
+// The layers are evaluated in the initial environment (Options.EnvSource) with Options.Evaluation.
+func NewLayers(locations []string, opts Options) (*Layers, error) {
+	if len(locations) == 0 {
+		return nil, errors.New("no envstore location provided")
+	}
+
+	var layers []*Envstore
+	for _, location := range locations {
+		store, err := New(location, opts)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, store)
+	}
+	// the options of the layers, with the defaults set by New
+	return &Layers{layers: layers, opts: layers[0].opts}, nil
+}
+
+// Len returns the number of layers.
+func (l *Layers) Len() int {
+	return len(l.layers)
+}
+
+// Layer returns the envstore of the layer (0-based index), the write methods of the returned envstore modify the layer.
+func (l *Layers) Layer(idx int) *Envstore {
+	return l.layers[idx]
+}
+
+// List returns the envs of the layers in order (see Envstore.List), and the index of the layer each env is declared in.
+func (l *Layers) List(ctx context.Context) ([]models.EnvironmentItemModel, []int, error) {
+	envs, layerIdxs, _, err := l.list(ctx)
+	return envs, layerIdxs, err
+}
+
+func (l *Layers) list(ctx context.Context) ([]models.EnvironmentItemModel, []int, []string, error) {
+	envs := []models.EnvironmentItemModel{}
+	var layerIdxs []int
+	var resolutions []string
+	for i, layer := range l.layers {
+		layerEnvs, resolution, err := layer.ListWithResolution(ctx)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to read envstore layer (%s): %s", layer.Path(), err)
+		}
+		for _, env := range layerEnvs {
+			envs = append(envs, env)
+			layerIdxs = append(layerIdxs, i)
+		}
+		resolutions = append(resolutions, resolution)
+	}
+	return envs, layerIdxs, resolutions, nil
+}
+
+// LayersEvaluationOptions returns the declaration options of evaluating layers with the default resolutions
+// (see Envstore.Resolution), the options set by opts are kept: the graph resolution is used if any of the layers selects it,
+// and the envstore value sources are looked up by ValueSourceLookup(lookupOpts).
+// $$ is an escaped dollar sign in the simple expansion (see env.DeclarationOptions.DollarEscape).
+func LayersEvaluationOptions(resolutions []string, opts env.DeclarationOptions, lookupOpts Options) env.DeclarationOptions {
+	opts.DollarEscape = true
+	if opts.ValueSources.LookupEnvstore == nil {
+		opts.ValueSources.LookupEnvstore = ValueSourceLookup(lookupOpts)
+	}
+	if opts.Resolution != "" {
+		return opts
+	}
+	for _, resolution := range resolutions {
+		if resolution == models.ResolutionGraph {
+			opts.Resolution = models.ResolutionGraph
+		}
+	}
+	return opts
+}
+
+// Evaluate evaluates the envs of the layers as one, like Envstore.Evaluate evaluates the envs of an envstore.
+// The indexes of the result (like the ones in Provenance) are the indexes of the envs returned by List.
+func (l *Layers) Evaluate(ctx context.Context) (env.DeclarationSideEffects, error) {
+	envs, _, resolutions, err := l.list(ctx)
+	if err != nil {
+		return env.DeclarationSideEffects{}, err
+	}
+
+	opts := LayersEvaluationOptions(resolutions, l.opts.Evaluation, l.opts)
+	return env.GetDeclarationsSideEffectsWithOptions(envs, l.opts.EnvSource, opts)
+}
+
+// Get returns the evaluated value of the env, and whether any of the layers declares (and doesn't unset) it.
+func (l *Layers) Get(ctx context.Context, key string) (string, bool, error) {
+	result, err := l.Evaluate(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := result.EvaluatedNewEnvs[key]
+	return value, ok, nil
+}
+
+// Command returns the command, with the evaluated environment of the layers (see Envstore.Command).
+func (l *Layers) Command(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	result, err := l.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return command(ctx, result, name, args...), nil
+}
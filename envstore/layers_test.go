@@ -0,0 +1,57 @@
+package envstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayers(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	basePth := filepath.Join(tmpDir, "base.yml")
+	jobPth := filepath.Join(tmpDir, "job.yml")
+	require.NoError(t, os.WriteFile(basePth, []byte("envs:\n- URL: https://$HOST/api\n- GREETING: hello\n"), 0644))
+	require.NoError(t, os.WriteFile(jobPth, []byte("defaults:\n  resolution: graph\nenvs:\n- MESSAGE: $GREETING world\n- HOST: example.com\n"), 0644))
+
+	layers, err := NewLayers([]string{basePth, jobPth}, Options{EnvSource: testEnvSource{}})
+	require.NoError(t, err)
+	require.Equal(t, 2, layers.Len())
+	require.Equal(t, jobPth, layers.Layer(1).Path())
+
+	envs, layerIdxs, err := layers.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(envs))
+	require.Equal(t, []int{0, 0, 1, 1}, layerIdxs)
+
+	// the later layers can reference the earlier ones, and the graph resolution of a layer applies to all layers
+	value, found, err := layers.Get(ctx, "MESSAGE")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "hello world", value)
+	value, _, err = layers.Get(ctx, "URL")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/api", value)
+
+	// the layers are modified one by one
+	require.NoError(t, layers.Layer(0).Add(ctx, "GREETING", "hi", AddOptions{}))
+	value, _, err = layers.Get(ctx, "MESSAGE")
+	require.NoError(t, err)
+	require.Equal(t, "hi world", value)
+
+	cmd, err := layers.Command(ctx, "env")
+	require.NoError(t, err)
+	require.Contains(t, cmd.Env, "GREETING=hi")
+
+	_, err = NewLayers(nil, Options{})
+	require.EqualError(t, err, "no envstore location provided")
+
+	missing, err := NewLayers([]string{basePth, filepath.Join(tmpDir, "missing.yml")}, Options{EnvSource: testEnvSource{}})
+	require.NoError(t, err)
+	_, _, err = missing.List(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to read envstore layer ("+filepath.Join(tmpDir, "missing.yml")+")")
+}
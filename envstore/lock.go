@@ -0,0 +1,93 @@
+package envstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockFileSuffix    = ".lock"
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// envstoreLock is an advisory, inter-process lock guarding an envstore file.
+// The lock is held on a separate <envstore>.lock file, which also records the PID of the holder.
+type envstoreLock struct {
+	file *os.File
+}
+
+func lockEnvstore(ctx context.Context, envStorePth string, timeout time.Duration) (*envstoreLock, error) {
+	lockPth := envStorePth + lockFileSuffix
+	file, err := os.OpenFile(lockPth, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %s", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to lock envstore: %s", err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			holderPID := readLockHolderPID(lockPth)
+			_ = file.Close()
+			return nil, NewLockedError(envStorePth, holderPID, timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = file.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	if err := file.Truncate(0); err != nil {
+		_ = unlockFile(file)
+		_ = file.Close()
+		return nil, err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		_ = unlockFile(file)
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &envstoreLock{file: file}, nil
+}
+
+func (l *envstoreLock) unlock() error {
+	// The lock file itself is kept: removing it would let a waiting process
+	// lock the already unlinked file, while a new one locks a fresh file.
+	if err := l.file.Truncate(0); err != nil {
+		_ = unlockFile(l.file)
+		_ = l.file.Close()
+		return err
+	}
+	if err := unlockFile(l.file); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+func readLockHolderPID(lockPth string) int {
+	content, err := os.ReadFile(lockPth)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
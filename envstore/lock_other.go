@@ -0,0 +1,16 @@
+//go:build !unix
+
+package envstore
+
+import "os"
+
+// Advisory file locking is only implemented on unix platforms,
+// elsewhere the lock is always granted.
+
+func tryLockFile(_ *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
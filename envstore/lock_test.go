@@ -0,0 +1,130 @@
+package envstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockEnvstore(t *testing.T) {
+	envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+
+	lock, err := lockEnvstore(context.Background(), envStorePth, time.Second)
+	require.NoError(t, err)
+
+	_, err = lockEnvstore(context.Background(), envStorePth, 100*time.Millisecond)
+	var lockedErr LockedError
+	require.True(t, errors.As(err, &lockedErr))
+	require.Equal(t, os.Getpid(), lockedErr.HolderPID)
+	require.Equal(t, envStorePth, lockedErr.EnvstorePath)
+
+	require.NoError(t, lock.unlock())
+
+	lock, err = lockEnvstore(context.Background(), envStorePth, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, lock.unlock())
+}
+
+func TestLockEnvstore_Canceled(t *testing.T) {
+	envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+
+	lock, err := lockEnvstore(context.Background(), envStorePth, time.Second)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, lock.unlock())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = lockEnvstore(ctx, envStorePth, time.Minute)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestEnvstoreAdd_Concurrent(t *testing.T) {
+	store := newTestEnvstore(t)
+
+	const count = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.Add(context.Background(), fmt.Sprintf("KEY_%d", i), "value", AddOptions{})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	envs, err := store.List(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, count, len(envs))
+}
+
+func TestEnvstoreAdd_ConcurrentValidate(t *testing.T) {
+	store := newTestEnvstore(t)
+
+	// the validator sees the envs added by the other goroutines, so each env gets a different value
+	validate := func(envs []models.EnvironmentItemModel, key, value string, opts AddOptions) (string, AddOptions, error) {
+		opts.Append = true
+		return strconv.Itoa(len(envs)), opts, nil
+	}
+
+	const count = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.Add(context.Background(), "COUNTER", "", AddOptions{Validate: validate})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	envs, err := store.ListOwn(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, count, len(envs))
+	for i, env := range envs {
+		_, value, err := env.GetKeyValuePair()
+		require.NoError(t, err)
+		require.Equal(t, strconv.Itoa(i), value)
+	}
+}
+
+func TestWriteFileAtomically(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), ".envstore.yml")
+	require.NoError(t, os.WriteFile(pth, []byte("envs: []\n"), 0600))
+
+	require.NoError(t, writeFileAtomically(pth, []byte("envs:\n- A: B\n")))
+
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	require.Equal(t, "envs:\n- A: B\n", string(content))
+
+	info, err := os.Stat(pth)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(pth))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+}
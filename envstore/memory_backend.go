@@ -0,0 +1,111 @@
+package envstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+var (
+	namedMemoryBackendsMu sync.Mutex
+	namedMemoryBackends   = map[string]*MemoryBackend{}
+)
+
+// MemoryBackend keeps the envstore in memory, for tests and for tools embedding envman.
+// The envstore is kept in the YAML layout, so it behaves like a file envstore.
+type MemoryBackend struct {
+	name string
+
+	mu      sync.Mutex
+	content []byte
+
+	lock chan struct{}
+}
+
+// NewMemoryBackend returns an empty, uninitialized in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{lock: make(chan struct{}, 1)}
+}
+
+// NamedMemoryBackend returns the in-memory backend registered with the name (the mem://NAME location),
+// the backend is created on first use and is shared within the process.
+func NamedMemoryBackend(name string) *MemoryBackend {
+	namedMemoryBackendsMu.Lock()
+	defer namedMemoryBackendsMu.Unlock()
+
+	backend, ok := namedMemoryBackends[name]
+	if !ok {
+		backend = NewMemoryBackend()
+		backend.name = name
+		namedMemoryBackends[name] = backend
+	}
+	return backend
+}
+
+// String ...
+func (b *MemoryBackend) String() string {
+	return MemoryScheme + schemeSeparator + b.name
+}
+
+// Exists ...
+func (b *MemoryBackend) Exists(_ context.Context) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.content != nil, nil
+}
+
+// Load ...
+func (b *MemoryBackend) Load(_ context.Context) (models.EnvsSerializeModel, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.content == nil {
+		return models.EnvsSerializeModel{}, errors.New("No envstore found in memory: " + b.String())
+	}
+	return parseEnvstoreYML(b.content)
+}
+
+// Save ...
+func (b *MemoryBackend) Save(_ context.Context, envstore models.EnvsSerializeModel) error {
+	content, err := formatYML(envstore)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.content = content
+	return nil
+}
+
+// Remove ...
+func (b *MemoryBackend) Remove(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.content = nil
+	return nil
+}
+
+// Lock ...
+func (b *MemoryBackend) Lock(ctx context.Context, timeout time.Duration) (func() error, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case b.lock <- struct{}{}:
+		return func() error {
+			<-b.lock
+			return nil
+		}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, NewLockedError(b.String(), 0, timeout)
+	}
+}
@@ -0,0 +1,172 @@
+package envstore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// envstoreMigration upgrades an envstore from FromVersion to FromVersion+1.
+type envstoreMigration struct {
+	FromVersion int
+	Migrate     func(envstore *models.EnvsSerializeModel) error
+}
+
+// envstoreMigrations are run in order when an envstore is written, every format version bump needs a migration here.
+var envstoreMigrations = []envstoreMigration{
+	{
+		// 0 -> 1: the envstore gets its format_version, the layout is unchanged.
+		FromVersion: 0,
+		Migrate: func(_ *models.EnvsSerializeModel) error {
+			return nil
+		},
+	},
+	{
+		// 1 -> 2: the envstore uses include, defaults or the evaluation options of the envs, the layout is unchanged.
+		FromVersion: 1,
+		Migrate: func(_ *models.EnvsSerializeModel) error {
+			return nil
+		},
+	},
+	{
+		// 2 -> 3: $$ is an escaped dollar sign in the simple expansion, the layout is unchanged.
+		// The $$ of the older envstores are rewritten when they are loaded (see upgradeDollarSigns),
+		// so the $$ of the envstore to migrate are all escapes.
+		FromVersion: 2,
+		Migrate: func(_ *models.EnvsSerializeModel) error {
+			return nil
+		},
+	},
+}
+
+// requiredFormatVersion returns the lowest format version, which supports everything the envstore uses:
+// envstores using include, defaults or the evaluation options of the envs need EvaluationOptionsFormatVersion,
+// envstores using the $$ escape need DollarEscapeFormatVersion,
+// so older envman versions refuse them instead of ignoring these fields (or expanding $$ to an empty string).
+func requiredFormatVersion(envstore models.EnvsSerializeModel) (int, error) {
+	version := 1
+	if len(envstore.Include) > 0 || envstore.Defaults != nil {
+		version = models.EvaluationOptionsFormatVersion
+	}
+	for _, env := range envstore.Envs {
+		opts, err := env.GetOptions()
+		if err != nil {
+			return 0, err
+		}
+		if usesEvaluationOptions(opts) && version < models.EvaluationOptionsFormatVersion {
+			version = models.EvaluationOptionsFormatVersion
+		}
+
+		_, value, err := env.GetKeyValuePair()
+		if err != nil {
+			return 0, err
+		}
+		if strings.Contains(value, "$$") && isSimpleExpanded(opts, envstore.Defaults) {
+			return models.DollarEscapeFormatVersion, nil
+		}
+	}
+	return version, nil
+}
+
+// usesEvaluationOptions returns whether the env options use a field, which older envman versions would ignore.
+func usesEvaluationOptions(opts models.EnvironmentItemOptionsModel) bool {
+	return opts.Expansion != nil || opts.Strict != nil || opts.ValueFrom != nil || len(opts.Transforms) > 0 ||
+		opts.Type != nil || opts.Operation != nil || opts.Separator != nil || opts.Dedupe != nil ||
+		opts.Pattern != nil || opts.MinLength != nil || opts.MaxLength != nil || opts.Min != nil || opts.Max != nil
+}
+
+// isSimpleExpanded returns whether the value of the env (with the envstore defaults) is expanded by the simple expansion.
+// The template expansion expands the values, which are not templates, by the simple expansion too.
+func isSimpleExpanded(opts models.EnvironmentItemOptionsModel, defaults *models.EnvstoreDefaultsModel) bool {
+	if opts.ValueFrom != nil || (opts.IsExpand != nil && !*opts.IsExpand) {
+		return false
+	}
+
+	expansion := models.ExpansionSimple
+	if opts.Expansion != nil {
+		expansion = *opts.Expansion
+	} else if defaults != nil && defaults.Expansion != "" {
+		expansion = defaults.Expansion
+	}
+
+	switch expansion {
+	case models.ExpansionSimple:
+		return true
+	case models.ExpansionTemplate:
+		return opts.IsTemplate == nil || !*opts.IsTemplate
+	default:
+		return false
+	}
+}
+
+// upgradeDollarSigns rewrites the $$ in the simple expansion values of an envstore older than DollarEscapeFormatVersion to ${$}:
+// ${$} expands to an empty string (like $$ without the escape) both with and without the escape,
+// so the values keep their meaning when the envstore is evaluated with the escape, or written with a newer format version.
+// The envs are modified in place. The encrypted values are not rewritten, these are only written by envman versions supporting the escape.
+func upgradeDollarSigns(envstore models.EnvsSerializeModel) error {
+	if envstore.FormatVersion >= models.DollarEscapeFormatVersion {
+		return nil
+	}
+
+	for _, env := range envstore.Envs {
+		key, value, err := env.GetKeyValuePair()
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(value, "$$") {
+			continue
+		}
+
+		opts, err := env.GetOptions()
+		if err != nil {
+			return err
+		}
+		if isSimpleExpanded(opts, envstore.Defaults) {
+			env[key] = strings.ReplaceAll(value, "$$", "${$}")
+		}
+	}
+	return nil
+}
+
+func checkEnvstoreFormatVersion(formatVersion int) error {
+	if formatVersion > models.CurrentEnvstoreFormatVersion {
+		return NewFormatVersionError(formatVersion, models.CurrentEnvstoreFormatVersion)
+	}
+	if formatVersion < 0 {
+		return fmt.Errorf("invalid envstore format version: %d", formatVersion)
+	}
+	return nil
+}
+
+// migrateEnvstore upgrades the envstore to the format version required by its content (see requiredFormatVersion),
+// the format version is never downgraded.
+func migrateEnvstore(envstore *models.EnvsSerializeModel) error {
+	if err := checkEnvstoreFormatVersion(envstore.FormatVersion); err != nil {
+		return err
+	}
+
+	targetVersion, err := requiredFormatVersion(*envstore)
+	if err != nil {
+		return err
+	}
+	if envstore.FormatVersion > targetVersion {
+		targetVersion = envstore.FormatVersion
+	}
+
+	for _, migration := range envstoreMigrations {
+		if migration.FromVersion != envstore.FormatVersion || envstore.FormatVersion >= targetVersion {
+			continue
+		}
+
+		if err := migration.Migrate(envstore); err != nil {
+			return fmt.Errorf("failed to migrate envstore from format version %d: %s", migration.FromVersion, err)
+		}
+		envstore.FormatVersion = migration.FromVersion + 1
+	}
+
+	if envstore.FormatVersion != targetVersion {
+		return fmt.Errorf("no migration found from envstore format version %d", envstore.FormatVersion)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package envstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/bitrise-io/go-utils/pointers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateEnvstore(t *testing.T) {
+	envstore := models.EnvsSerializeModel{}
+	require.NoError(t, migrateEnvstore(&envstore))
+	require.Equal(t, 1, envstore.FormatVersion)
+
+	for _, envstore := range []models.EnvsSerializeModel{
+		{Include: []models.EnvstoreIncludeModel{{Path: "base.yml"}}},
+		{Defaults: &models.EnvstoreDefaultsModel{Expansion: models.ExpansionBash}},
+		{Envs: []models.EnvironmentItemModel{{"PORT": "8080", "opts": map[string]interface{}{"type": "int"}}}},
+		{Envs: []models.EnvironmentItemModel{{"TOKEN": "", "opts": map[string]interface{}{"value_from": map[string]interface{}{"file": "token.txt"}}}}},
+		{FormatVersion: 1, Envs: []models.EnvironmentItemModel{{"VERSION": "v1", "opts": map[string]interface{}{"pattern": "^v"}}}},
+	} {
+		require.NoError(t, migrateEnvstore(&envstore))
+		require.Equal(t, models.EvaluationOptionsFormatVersion, envstore.FormatVersion)
+	}
+
+	for _, envstore := range []models.EnvsSerializeModel{
+		{Envs: []models.EnvironmentItemModel{{"PRICE": "$$5"}}},
+		{Envs: []models.EnvironmentItemModel{{"PRICE": "$$5", "opts": map[string]interface{}{"expansion": "template"}}}},
+		{FormatVersion: 2, Envs: []models.EnvironmentItemModel{{"PRICE": "$$5", "opts": map[string]interface{}{"type": "string"}}}},
+	} {
+		require.NoError(t, migrateEnvstore(&envstore))
+		require.Equal(t, models.DollarEscapeFormatVersion, envstore.FormatVersion)
+	}
+
+	// $$ is only an escape in the simple expansion
+	envstore = models.EnvsSerializeModel{Envs: []models.EnvironmentItemModel{
+		{"LITERAL": "$$5", "opts": map[string]interface{}{"is_expand": false}},
+		{"BASH": "$$5", "opts": map[string]interface{}{"expansion": "bash"}},
+	}}
+	require.NoError(t, migrateEnvstore(&envstore))
+	require.Equal(t, models.EvaluationOptionsFormatVersion, envstore.FormatVersion)
+
+	// the format version is not downgraded
+	envstore = models.EnvsSerializeModel{FormatVersion: 2, Envs: []models.EnvironmentItemModel{{"A": "B"}}}
+	require.NoError(t, migrateEnvstore(&envstore))
+	require.Equal(t, 2, envstore.FormatVersion)
+
+	envstore = models.EnvsSerializeModel{FormatVersion: models.CurrentEnvstoreFormatVersion + 1}
+	require.Equal(t, NewFormatVersionError(models.CurrentEnvstoreFormatVersion+1, models.CurrentEnvstoreFormatVersion), migrateEnvstore(&envstore))
+}
+
+func TestEnvstoreFormatVersion(t *testing.T) {
+	ctx := context.Background()
+	t.Log("legacy envstore is migrated when written")
+	{
+		envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+		store := openTestEnvstore(t, envStorePth)
+		require.NoError(t, os.WriteFile(envStorePth, []byte("envs:\n- A: B\n"), 0644))
+
+		envs, err := store.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(envs))
+
+		require.NoError(t, store.Add(ctx, "C", "D", AddOptions{}))
+
+		content, err := os.ReadFile(envStorePth)
+		require.NoError(t, err)
+		require.Equal(t, "format_version: 1\nenvs:\n- A: B\n- C: D\n", string(content))
+
+		require.NoError(t, store.Add(ctx, "PORT", "8080", AddOptions{EnvOptions: models.EnvironmentItemOptionsModel{Type: pointers.NewStringPtr(models.TypeInt)}}))
+
+		content, err = os.ReadFile(envStorePth)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "format_version: 2\n")
+	}
+
+	t.Log("journal envstore is rewritten with the required format version")
+	{
+		envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+		store := openTestEnvstore(t, envStorePth)
+		require.NoError(t, store.Init(ctx, InitOptions{Journal: true}))
+		require.NoError(t, store.Add(ctx, "A", "B", AddOptions{}))
+		require.NoError(t, store.Add(ctx, "PORT", "8080", AddOptions{EnvOptions: models.EnvironmentItemOptionsModel{Type: pointers.NewStringPtr(models.TypeInt)}}))
+
+		journal, err := isJournalStore(envStorePth)
+		require.NoError(t, err)
+		require.True(t, journal)
+
+		envstore, err := readEnvstore(envStorePth)
+		require.NoError(t, err)
+		require.Equal(t, models.EvaluationOptionsFormatVersion, envstore.FormatVersion)
+		require.Equal(t, 2, len(envstore.Envs))
+	}
+
+	t.Log("newer envstore is refused")
+	{
+		envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+		store := openTestEnvstore(t, envStorePth)
+		newerContent := "format_version: 999\nenvs:\n- A: B\n"
+		require.NoError(t, os.WriteFile(envStorePth, []byte(newerContent), 0644))
+
+		var formatVersionErr FormatVersionError
+
+		_, err := store.List(ctx)
+		require.True(t, errors.As(err, &formatVersionErr))
+		require.Equal(t, 999, formatVersionErr.FormatVersion)
+
+		err = store.Add(ctx, "C", "D", AddOptions{})
+		require.True(t, errors.As(err, &formatVersionErr))
+
+		err = store.Clear(ctx)
+		require.True(t, errors.As(err, &formatVersionErr))
+
+		content, err := os.ReadFile(envStorePth)
+		require.NoError(t, err)
+		require.Equal(t, newerContent, string(content))
+	}
+
+	t.Log("newer journal envstore is refused")
+	{
+		content := journalHeader + "\n" + `{"op":"format_version","format_version":999}` + "\n"
+		_, err := ParseJournal([]byte(content))
+		require.EqualError(t, err, "failed to replay journal record at line 2: envstore format version (999) is newer than the latest supported version (3), please upgrade envman")
+	}
+}
+
+func TestEnvstoreDollarEscape(t *testing.T) {
+	ctx := context.Background()
+	envStorePth := filepath.Join(t.TempDir(), ".envstore.yml")
+	store := openTestEnvstore(t, envStorePth)
+	require.NoError(t, os.WriteFile(envStorePth, []byte("format_version: 1\nenvs:\n- CURRENCY: usd\n- PRICE: $$5 $CURRENCY\n"), 0644))
+
+	t.Log("$$ expands to an empty string in the older envstores")
+	requireEnvValue(t, store, "PRICE", "5 usd")
+
+	t.Log("the older $$ keep their meaning, when the envstore is migrated")
+	require.NoError(t, store.Add(ctx, "DISCOUNT", "$$1 $CURRENCY", AddOptions{}))
+
+	content, err := os.ReadFile(envStorePth)
+	require.NoError(t, err)
+	require.Equal(t, "format_version: 3\nenvs:\n- CURRENCY: usd\n- PRICE: ${$}5 $CURRENCY\n- DISCOUNT: $$1 $CURRENCY\n", string(content))
+
+	requireEnvValue(t, store, "PRICE", "5 usd")
+	requireEnvValue(t, store, "DISCOUNT", "$1 usd")
+}
@@ -0,0 +1,64 @@
+package envstore
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+// ValueSourceLookup returns the lookup of the envstore value sources (env.ValueSourceOptions.LookupEnvstore):
+// the envstore at the location is opened like by OpenBackend, and its envs are evaluated with opts.
+func ValueSourceLookup(opts Options) func(location, key string) (string, bool, error) {
+	return valueSourceLookup(opts, nil)
+}
+
+// valueSourceLookup returns the lookup of the envstore value sources,
+// locations holds the envstores being evaluated for a value source, to detect cycles.
+func valueSourceLookup(opts Options, locations []string) func(location, key string) (string, bool, error) {
+	return func(location, key string) (string, bool, error) {
+		backend, err := OpenBackend(location)
+		if err != nil {
+			return "", false, err
+		}
+
+		for _, evaluated := range locations {
+			if evaluated == backend.String() {
+				cycle := append(append([]string{}, locations...), backend.String())
+				return "", false, fmt.Errorf("envstore value source cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		sourceOpts := opts
+		sourceOpts.Evaluation.ValueSources.LookupEnvstore = valueSourceLookup(opts, append(append([]string{}, locations...), backend.String()))
+		return NewWithBackend(backend, sourceOpts).Get(context.Background(), key)
+	}
+}
+
+// resolveValueSourcePaths makes the relative file and envstore paths of the value sources relative to baseDir
+// (the directory of the envstore), the locations with a scheme are kept as is.
+// Like applyDefaults, it's only called on the envs loaded for evaluation.
+func resolveValueSourcePaths(envs []models.EnvironmentItemModel, baseDir string) error {
+	for _, env := range envs {
+		opts, err := env.GetOptions()
+		if err != nil {
+			return err
+		}
+		if opts.ValueFrom == nil {
+			continue
+		}
+
+		source := *opts.ValueFrom
+		if source.File != "" && !filepath.IsAbs(source.File) {
+			source.File = filepath.Join(baseDir, source.File)
+		}
+		if source.Envstore != "" && !strings.Contains(source.Envstore, schemeSeparator) && !filepath.IsAbs(source.Envstore) {
+			source.Envstore = filepath.Join(baseDir, source.Envstore)
+		}
+		opts.ValueFrom = &source
+		env[models.OptionsKey] = opts
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package envstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueSources(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "secrets"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secrets", "token.txt"), []byte("file-token"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secrets", "shared.yml"), []byte(`envs:
+- HOST: example.com
+- API_URL: https://$HOST/api
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secrets", "base.yml"), []byte(`envs:
+- TOKEN: ""
+  opts:
+    value_from:
+      file: token.txt
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".envstore.yml"), []byte(`include:
+- secrets/base.yml
+envs:
+- API_URL: ""
+  opts:
+    value_from:
+      envstore: secrets/shared.yml
+      key: API_URL
+`), 0644))
+
+	result, err := openTestEnvstore(t, filepath.Join(dir, ".envstore.yml")).Evaluate(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"TOKEN": "file-token", "API_URL": "https://example.com/api"}, result.EvaluatedNewEnvs)
+
+	t.Run("cycle", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yml"), []byte(`envs:
+- A: ""
+  opts:
+    value_from:
+      envstore: b.yml
+      key: B
+`), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b.yml"), []byte(`envs:
+- B: ""
+  opts:
+    value_from:
+      envstore: a.yml
+      key: A
+`), 0644))
+
+		_, err := openTestEnvstore(t, filepath.Join(dir, "a.yml")).Evaluate(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "envstore value source cycle: "+filepath.Join(dir, "b.yml")+" -> "+filepath.Join(dir, "a.yml")+" -> "+filepath.Join(dir, "b.yml"))
+	})
+}
@@ -0,0 +1,135 @@
+package importer
+
+// ParseDotenv parses a .env file:
+//   - KEY=value lines, blanks are allowed around the = sign, an optional export prefix is skipped
+//   - lines starting with # are comments, so is a # preceded by a blank after an unquoted or quoted value
+//   - single-quoted values are literal, they can span multiple lines
+//   - double-quoted values can span multiple lines, \n, \r, \t, \", \\ and \$ are escapes in them
+//   - unquoted values are trimmed, backslashes are literal in them
+//
+// $NAME and ${NAME} references are expanded in the double-quoted and unquoted values.
+func ParseDotenv(content string) ([]Entry, error) {
+	p := newParser(content)
+
+	var entries []Entry
+	for {
+		for !p.done() && (isBlank(p.peek()) || p.peek() == '\n') {
+			p.next()
+		}
+		if p.done() {
+			return entries, nil
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		line := p.line
+		p.consumeExport()
+		key := p.readKey()
+		if key == "" {
+			return nil, p.errorf("invalid env var name")
+		}
+		p.skipBlanks()
+		if p.peek() != '=' {
+			return nil, p.errorf("missing = after %s", key)
+		}
+		p.next()
+		blank := isBlank(p.peek())
+		p.skipBlanks()
+
+		var b valueBuilder
+		var err error
+		switch {
+		case blank && p.peek() == '#':
+			// empty value followed by a comment
+		case p.peek() == '\'':
+			err = p.readSingleQuoted(&b, key)
+		case p.peek() == '"':
+			err = p.readDotenvDoubleQuoted(&b, key)
+		default:
+			err = p.readDotenvUnquoted(&b, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipBlanks()
+		switch {
+		case p.done() || p.peek() == '\n':
+		case p.peek() == '#':
+			p.skipLine()
+		default:
+			return nil, p.errorf("unexpected character after the value of %s: %q", key, p.peek())
+		}
+
+		entries = append(entries, b.entry(key, line))
+	}
+}
+
+func (p *parser) readDotenvDoubleQuoted(b *valueBuilder, key string) error {
+	p.next()
+	for !p.done() {
+		switch r := p.peek(); r {
+		case '"':
+			p.next()
+			return nil
+		case '$':
+			if err := p.readDollar(b, key); err != nil {
+				return err
+			}
+		case '\\':
+			p.next()
+			if p.done() {
+				b.writeLiteral('\\')
+				continue
+			}
+			switch escaped := p.next(); escaped {
+			case 'n':
+				b.writeLiteral('\n')
+			case 'r':
+				b.writeLiteral('\r')
+			case 't':
+				b.writeLiteral('\t')
+			case '"', '\\', '$':
+				b.writeLiteral(escaped)
+			default:
+				b.writeLiteral('\\')
+				b.writeLiteral(escaped)
+			}
+		default:
+			b.writeLiteral(p.next())
+		}
+	}
+	return p.errorf("unterminated double-quoted value of %s", key)
+}
+
+// readDotenvUnquoted reads the value until the end of the line or an inline comment, trailing blanks are dropped.
+func (p *parser) readDotenvUnquoted(b *valueBuilder, key string) error {
+	var pending []rune
+	flush := func() {
+		for _, r := range pending {
+			b.writeLiteral(r)
+		}
+		pending = nil
+	}
+
+	for !p.done() && p.peek() != '\n' {
+		r := p.peek()
+		switch {
+		case isBlank(r):
+			pending = append(pending, p.next())
+		case r == '#' && len(pending) > 0:
+			return nil
+		case r == '$':
+			flush()
+			if err := p.readDollar(b, key); err != nil {
+				return err
+			}
+		default:
+			flush()
+			b.writeLiteral(p.next())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDotenv(t *testing.T) {
+	content := `# comment
+export APP_NAME=envman
+EMPTY=
+EMPTY_WITH_COMMENT= # comment
+UNQUOTED = some value   # inline comment
+HASH=a#b
+WINDOWS_PATH=C:\tools\bin
+SINGLE='literal $HOME \n'
+DOUBLE="line1\nline2\t\"quoted\" \$5"
+MULTILINE="first
+second"
+REFERENCE=$HOME/bin
+BRACED="${HOME}/bin $$"
+DEFAULT=${BRANCH:-main}
+PRICE="\$5 for $USER"
+`
+
+	entries, err := ParseDotenv(content)
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{Key: "APP_NAME", Value: "envman", Line: 2},
+		{Key: "EMPTY", Value: "", Line: 3},
+		{Key: "EMPTY_WITH_COMMENT", Value: "", Line: 4},
+		{Key: "UNQUOTED", Value: "some value", Line: 5},
+		{Key: "HASH", Value: "a#b", Line: 6},
+		{Key: "WINDOWS_PATH", Value: `C:\tools\bin`, Line: 7},
+		{Key: "SINGLE", Value: `literal $HOME \n`, Line: 8},
+		{Key: "DOUBLE", Value: "line1\nline2\t\"quoted\" $5", Line: 9},
+		{Key: "MULTILINE", Value: "first\nsecond", Line: 10},
+		{Key: "REFERENCE", Value: "$HOME/bin", Expand: true, ExpandableValue: "$HOME/bin", Line: 12},
+		{Key: "BRACED", Value: "${HOME}/bin $$", Expand: true, ExpandableValue: "${HOME}/bin $$$$", Line: 13},
+		{Key: "DEFAULT", Value: "${BRANCH:-main}", Expand: true, ExpandableValue: "${BRANCH:-main}", Expansion: models.ExpansionBash, Line: 14},
+		{Key: "PRICE", Value: "$5 for $USER", Expand: true, ExpandableValue: "$$5 for $USER", Line: 15},
+	}, entries)
+}
+
+func TestParseDotenv_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		wantErr string
+	}{
+		{content: "1KEY=value", wantErr: "line 1: invalid env var name"},
+		{content: "\nKEY value", wantErr: "line 2: missing = after KEY"},
+		{content: "KEY=\"value\nnext", wantErr: "line 2: unterminated double-quoted value of KEY"},
+		{content: "KEY='value", wantErr: "line 1: unterminated single-quoted value of KEY"},
+		{content: "KEY=\"value\" rest", wantErr: "line 1: unexpected character after the value of KEY: 'r'"},
+		{content: "KEY=$(whoami)", wantErr: "line 1: command substitution in the value of KEY is not supported"},
+	} {
+		t.Run(tc.content, func(t *testing.T) {
+			_, err := ParseDotenv(tc.content)
+			require.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,283 @@
+// Package importer parses env var files of other tools (like .env files, shell export scripts, JSON or YAML maps
+// and Kubernetes manifests) into envman envs.
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bitrise-io/envman/v2/models"
+)
+
+const (
+	// FormatDotenv is the .env file format: KEY=value lines with optional quoting and export prefixes
+	FormatDotenv = "dotenv"
+	// FormatShell is a shell script of (exported) variable assignments: export KEY=value
+	FormatShell = "shell"
+	// FormatJSON is a JSON object, its nested objects are flattened
+	FormatJSON = "json"
+	// FormatYAML is a YAML map, its nested maps are flattened
+	FormatYAML = "yaml"
+	// FormatK8s is Kubernetes ConfigMap and Secret manifests
+	FormatK8s = "k8s"
+)
+
+// Formats are the supported import formats.
+var Formats = []string{FormatDotenv, FormatShell, FormatJSON, FormatYAML, FormatK8s}
+
+// Options configure the parsing of an imported file.
+type Options struct {
+	// Separator joins the keys of the nested JSON and YAML maps, defaults to DefaultSeparator
+	Separator string
+	// Prefix is prepended to the keys of the entries
+	Prefix string
+}
+
+func (opts Options) separator() string {
+	if opts.Separator == "" {
+		return DefaultSeparator
+	}
+	return opts.Separator
+}
+
+// Entry is an env var read from an imported file.
+type Entry struct {
+	Key string
+	// Value is the unquoted value, its references are kept as they are (not expanded)
+	Value string
+	// Expand is set if the value references other env vars ($NAME or ${...})
+	Expand bool
+	// ExpandableValue is the value with its literal $ signs escaped ($$), set if Expand is set
+	ExpandableValue string
+	// Expansion is set to models.ExpansionBash if the references use bash parameter expansion operators, like ${VAR:-default}
+	Expansion string
+	// Sensitive is set for the values of Kubernetes Secrets
+	Sensitive bool
+	// Line is the line of the entry in the dotenv and shell files
+	Line int
+}
+
+// Parse parses the content of a file in the format.
+func Parse(format string, content []byte, opts Options) ([]Entry, error) {
+	var entries []Entry
+	var err error
+	switch format {
+	case FormatDotenv:
+		entries, err = ParseDotenv(string(content))
+	case FormatShell:
+		entries, err = ParseShell(string(content))
+	case FormatJSON:
+		entries, err = ParseJSON(content, opts)
+	case FormatYAML:
+		entries, err = ParseYAML(content, opts)
+	case FormatK8s:
+		entries, err = ParseK8s(content)
+	default:
+		return nil, fmt.Errorf("unknown import format (%s), supported formats: %s", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Prefix != "" && !isName(opts.Prefix) {
+		return nil, fmt.Errorf("invalid prefix (%s): %s", opts.Prefix, invalidNameReason)
+	}
+	for i := range entries {
+		entries[i].Key = opts.Prefix + entries[i].Key
+	}
+	return entries, nil
+}
+
+// valueBuilder builds the value of an entry from its literal and expandable parts.
+type valueBuilder struct {
+	// literal is the value as it is
+	literal strings.Builder
+	// escaped is the value with the literal $ signs escaped, used if the value is expanded
+	escaped strings.Builder
+	expand  bool
+	bash    bool
+}
+
+func (b *valueBuilder) writeLiteral(r rune) {
+	b.literal.WriteRune(r)
+	if r == '$' {
+		b.escaped.WriteString("$$")
+	} else {
+		b.escaped.WriteRune(r)
+	}
+}
+
+func (b *valueBuilder) writeReference(reference string) {
+	b.literal.WriteString(reference)
+	b.escaped.WriteString(reference)
+	b.expand = true
+}
+
+func (b *valueBuilder) entry(key string, line int) Entry {
+	entry := Entry{Key: key, Value: b.literal.String(), Line: line}
+	if b.expand {
+		entry.Expand = true
+		entry.ExpandableValue = b.escaped.String()
+		if b.bash {
+			entry.Expansion = models.ExpansionBash
+		}
+	}
+	return entry
+}
+
+// parser is a cursor over the content of an imported file.
+type parser struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func newParser(content string) *parser {
+	return &parser{src: []rune(strings.ReplaceAll(content, "\r\n", "\n")), line: 1}
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *parser) peek() rune {
+	if p.done() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) peekAt(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+func (p *parser) next() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+	}
+	return r
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func isBlank(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+func (p *parser) skipBlanks() {
+	for !p.done() && isBlank(p.peek()) {
+		p.next()
+	}
+}
+
+// skipLine skips the rest of the line, including the line break.
+func (p *parser) skipLine() {
+	for !p.done() {
+		if p.next() == '\n' {
+			return
+		}
+	}
+}
+
+// consumeExport skips the export keyword (followed by blanks) at the cursor.
+func (p *parser) consumeExport() {
+	const keyword = "export"
+	if !strings.HasPrefix(string(p.src[p.pos:min(p.pos+len(keyword), len(p.src))]), keyword) || !isBlank(p.peekAt(len(keyword))) {
+		return
+	}
+	for range keyword {
+		p.next()
+	}
+	p.skipBlanks()
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || (r >= '0' && r <= '9')
+}
+
+// readKey reads an env var name, empty if there is no name at the cursor.
+func (p *parser) readKey() string {
+	start := p.pos
+	if !isNameStart(p.peek()) {
+		return ""
+	}
+	for !p.done() && isNameChar(p.peek()) {
+		p.next()
+	}
+	return string(p.src[start:p.pos])
+}
+
+// readDollar reads a $ sign at the cursor: $NAME and ${...} are references, other $ signs are literal.
+// References using bash parameter expansion operators (like ${VAR:-default}) switch the value to the bash expansion.
+func (p *parser) readDollar(b *valueBuilder, key string) error {
+	p.next()
+	switch {
+	case p.peek() == '(':
+		return p.errorf("command substitution in the value of %s is not supported", key)
+	case p.peek() == '{':
+		start := p.pos
+		depth := 0
+		for !p.done() {
+			r := p.next()
+			if r == '{' {
+				depth++
+			} else if r == '}' {
+				depth--
+				if depth == 0 {
+					reference := string(p.src[start:p.pos])
+					name := reference[1 : len(reference)-1]
+					if !isName(name) {
+						b.bash = true
+					}
+					b.writeReference("$" + reference)
+					return nil
+				}
+			}
+		}
+		return p.errorf("unterminated ${ reference in the value of %s", key)
+	case isNameStart(p.peek()):
+		b.writeReference("$" + p.readKey())
+	default:
+		b.writeLiteral('$')
+	}
+	return nil
+}
+
+// invalidNameReason explains why a key of the structured formats is not a valid env var name.
+const invalidNameReason = "only letters, digits and underscores are allowed, and the first character can't be a digit"
+
+func isName(s string) bool {
+	if s == "" || !isNameStart(rune(s[0])) {
+		return false
+	}
+	for _, r := range s {
+		if !isNameChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// readSingleQuoted reads a single-quoted value: everything is literal until the closing quote, line breaks included.
+func (p *parser) readSingleQuoted(b *valueBuilder, key string) error {
+	p.next()
+	for !p.done() {
+		r := p.next()
+		if r == '\'' {
+			return nil
+		}
+		b.writeLiteral(r)
+	}
+	return p.errorf("unterminated single-quoted value of %s", key)
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	k8sKindConfigMap = "ConfigMap"
+	k8sKindSecret    = "Secret"
+	k8sKindList      = "List"
+)
+
+type k8sManifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Data       yaml.MapSlice `yaml:"data"`
+	StringData yaml.MapSlice `yaml:"stringData"`
+	BinaryData yaml.MapSlice `yaml:"binaryData"`
+	Items      []k8sManifest `yaml:"items"`
+}
+
+// ParseK8s parses Kubernetes ConfigMap and Secret manifests (YAML or JSON, multiple documents and List manifests are supported),
+// the manifests of other kinds are skipped, so are the keys which are not valid env var names (with a warning).
+// The data of a ConfigMap is imported as it is (its binaryData is base64 decoded),
+// the data of a Secret is base64 decoded and imported as sensitive, so is its stringData.
+func ParseK8s(content []byte) ([]Entry, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	var entries []Entry
+	found := false
+	for {
+		var manifest k8sManifest
+		if err := decoder.Decode(&manifest); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		manifestEntries, ok, err := k8sManifestEntries(manifest)
+		if err != nil {
+			return nil, err
+		}
+		found = found || ok
+		entries = append(entries, manifestEntries...)
+	}
+
+	if !found {
+		return nil, errors.New("no ConfigMap or Secret manifest found")
+	}
+	return entries, nil
+}
+
+// k8sManifestEntries returns the entries of a ConfigMap or Secret (or of the ones in a List),
+// and whether the manifest is (or contains) one of them.
+func k8sManifestEntries(manifest k8sManifest) ([]Entry, bool, error) {
+	switch manifest.Kind {
+	case k8sKindList:
+		var entries []Entry
+		found := false
+		for _, item := range manifest.Items {
+			itemEntries, ok, err := k8sManifestEntries(item)
+			if err != nil {
+				return nil, false, err
+			}
+			found = found || ok
+			entries = append(entries, itemEntries...)
+		}
+		return entries, found, nil
+	case k8sKindConfigMap:
+		entries, err := k8sDataEntries(nil, manifest, manifest.Data, false, false)
+		if err != nil {
+			return nil, false, err
+		}
+		entries, err = k8sDataEntries(entries, manifest, manifest.BinaryData, true, false)
+		return entries, true, err
+	case k8sKindSecret:
+		entries, err := k8sDataEntries(nil, manifest, manifest.Data, true, true)
+		if err != nil {
+			return nil, false, err
+		}
+		entries, err = k8sDataEntries(entries, manifest, manifest.StringData, false, true)
+		return entries, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+func k8sDataEntries(entries []Entry, manifest k8sManifest, data yaml.MapSlice, encoded, sensitive bool) ([]Entry, error) {
+	for _, item := range data {
+		key := fmt.Sprint(item.Key)
+		if !isName(key) {
+			// the keys of ConfigMaps and Secrets are often file names (like app.properties), the other keys are still imported
+			log.Warnf("Skipping %s of %s (%s), it's not a valid env var name: %s", key, manifest.Kind, manifest.Metadata.Name, invalidNameReason)
+			continue
+		}
+		value, err := scalarValue(item.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s in %s (%s): %s", key, manifest.Kind, manifest.Metadata.Name, err)
+		}
+		if encoded {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode the value of %s in %s (%s): %s", key, manifest.Kind, manifest.Metadata.Name, err)
+			}
+			value = string(decoded)
+		}
+		entries = append(entries, Entry{Key: key, Value: value, Sensitive: sensitive})
+	}
+	return entries, nil
+}
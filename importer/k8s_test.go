@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseK8s(t *testing.T) {
+	content := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  APP_ENV: production
+  LOG_LEVEL: debug
+binaryData:
+  BINARY: aGVsbG8=
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+type: Opaque
+data:
+  API_TOKEN: czNjcjN0
+stringData:
+  PASSWORD: plain
+`
+
+	entries, err := ParseK8s([]byte(content))
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{Key: "APP_ENV", Value: "production"},
+		{Key: "LOG_LEVEL", Value: "debug"},
+		{Key: "BINARY", Value: "hello"},
+		{Key: "API_TOKEN", Value: "s3cr3t", Sensitive: true},
+		{Key: "PASSWORD", Value: "plain", Sensitive: true},
+	}, entries)
+}
+
+func TestParseK8s_List(t *testing.T) {
+	content := `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "token"}, "data": {"TOKEN": "dG9rZW4="}}
+  ]
+}`
+
+	entries, err := Parse(FormatK8s, []byte(content), Options{Prefix: "CI_"})
+	require.NoError(t, err)
+	require.Equal(t, []Entry{{Key: "CI_TOKEN", Value: "token", Sensitive: true}}, entries)
+}
+
+func TestParseK8s_Errors(t *testing.T) {
+	_, err := ParseK8s([]byte("kind: Deployment\n"))
+	require.EqualError(t, err, "no ConfigMap or Secret manifest found")
+
+	_, err = ParseK8s([]byte("kind: Secret\nmetadata:\n  name: broken\ndata:\n  TOKEN: not-base64!\n"))
+	require.EqualError(t, err, "failed to decode the value of TOKEN in Secret (broken): illegal base64 data at input byte 3")
+}
+
+func TestParseK8s_InvalidKeys(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	content := `kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  app.properties: a=b
+  LOG_LEVEL: debug
+`
+
+	entries, err := ParseK8s([]byte(content))
+	require.NoError(t, err)
+	require.Equal(t, []Entry{{Key: "LOG_LEVEL", Value: "debug"}}, entries)
+	require.Contains(t, logs.String(), "Skipping app.properties of ConfigMap (app-config), it's not a valid env var name")
+}
@@ -0,0 +1,145 @@
+package importer
+
+// ParseShell parses a shell script of variable assignments, with POSIX shell quoting:
+//   - statements are NAME=value assignments (multiple ones are allowed in a statement), with an optional export prefix,
+//     separated by line breaks or ;
+//   - # starts a comment at the beginning of a word
+//   - single-quoted parts are literal, they can span multiple lines
+//   - in double-quoted parts \$, \`, \" and \\ are escapes and a backslash-line break is a line continuation,
+//     they can span multiple lines
+//   - in unquoted parts a backslash escapes the next character, a backslash-line break is a line continuation
+//   - the quoted and unquoted parts of a value are concatenated, like FOO="a"'b'c
+//
+// $NAME and ${NAME} references are expanded in the double-quoted and unquoted parts.
+// Command substitutions, pipes, redirections and other commands are not supported.
+func ParseShell(content string) ([]Entry, error) {
+	p := newParser(content)
+
+	var entries []Entry
+	for {
+		for !p.done() && (isBlank(p.peek()) || p.peek() == '\n' || p.peek() == ';') {
+			p.next()
+		}
+		if p.done() {
+			return entries, nil
+		}
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		p.consumeExport()
+		assignments := 0
+		for {
+			p.skipShellBlanks()
+			if p.done() || p.peek() == '\n' || p.peek() == ';' {
+				break
+			}
+			if p.peek() == '#' {
+				p.skipLine()
+				break
+			}
+
+			line := p.line
+			key := p.readKey()
+			if key == "" || p.peek() != '=' {
+				return nil, p.errorf("only variable assignments (NAME=value) are supported")
+			}
+			p.next()
+
+			var b valueBuilder
+			if err := p.readShellWord(&b, key); err != nil {
+				return nil, err
+			}
+			entries = append(entries, b.entry(key, line))
+			assignments++
+		}
+		if assignments == 0 {
+			return nil, p.errorf("only variable assignments (NAME=value) are supported")
+		}
+	}
+}
+
+// skipShellBlanks skips the blanks and the line continuations.
+func (p *parser) skipShellBlanks() {
+	for !p.done() {
+		switch {
+		case isBlank(p.peek()):
+			p.next()
+		case p.peek() == '\\' && p.peekAt(1) == '\n':
+			p.next()
+			p.next()
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) readShellWord(b *valueBuilder, key string) error {
+	for !p.done() {
+		switch r := p.peek(); r {
+		case ' ', '\t', '\n', ';':
+			return nil
+		case '\'':
+			if err := p.readSingleQuoted(b, key); err != nil {
+				return err
+			}
+		case '"':
+			if err := p.readShellDoubleQuoted(b, key); err != nil {
+				return err
+			}
+		case '$':
+			if err := p.readDollar(b, key); err != nil {
+				return err
+			}
+		case '\\':
+			p.next()
+			if p.done() {
+				b.writeLiteral('\\')
+			} else if escaped := p.next(); escaped != '\n' {
+				b.writeLiteral(escaped)
+			}
+		case '`':
+			return p.errorf("command substitution in the value of %s is not supported", key)
+		case '|', '&', '<', '>', '(', ')':
+			return p.errorf("unsupported shell syntax in the value of %s: %q", key, r)
+		default:
+			b.writeLiteral(p.next())
+		}
+	}
+	return nil
+}
+
+func (p *parser) readShellDoubleQuoted(b *valueBuilder, key string) error {
+	p.next()
+	for !p.done() {
+		switch r := p.peek(); r {
+		case '"':
+			p.next()
+			return nil
+		case '$':
+			if err := p.readDollar(b, key); err != nil {
+				return err
+			}
+		case '`':
+			return p.errorf("command substitution in the value of %s is not supported", key)
+		case '\\':
+			p.next()
+			if p.done() {
+				b.writeLiteral('\\')
+				continue
+			}
+			switch escaped := p.next(); escaped {
+			case '\n':
+			case '$', '`', '"', '\\':
+				b.writeLiteral(escaped)
+			default:
+				b.writeLiteral('\\')
+				b.writeLiteral(escaped)
+			}
+		default:
+			b.writeLiteral(p.next())
+		}
+	}
+	return p.errorf("unterminated double-quoted value of %s", key)
+}
@@ -0,0 +1,56 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShell(t *testing.T) {
+	content := `#!/bin/sh
+# comment
+export APP_NAME=envman
+export A=1 B=2; C=3 # comment
+CONCAT="double $USER"'single $USER'unquoted\ word
+ESCAPED="\$5 \"quoted\" \n"
+MULTILINE='first
+second'
+CONTINUED="first \
+second"
+EMPTY=
+`
+
+	entries, err := ParseShell(content)
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{Key: "APP_NAME", Value: "envman", Line: 3},
+		{Key: "A", Value: "1", Line: 4},
+		{Key: "B", Value: "2", Line: 4},
+		{Key: "C", Value: "3", Line: 4},
+		{Key: "CONCAT", Value: "double $USERsingle $USERunquoted word", Expand: true, ExpandableValue: "double $USERsingle $$USERunquoted word", Line: 5},
+		{Key: "ESCAPED", Value: `$5 "quoted" \n`, Line: 6},
+		{Key: "MULTILINE", Value: "first\nsecond", Line: 7},
+		{Key: "CONTINUED", Value: "first second", Line: 9},
+		{Key: "EMPTY", Value: "", Line: 11},
+	}, entries)
+}
+
+func TestParseShell_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		wantErr string
+	}{
+		{content: "echo hello", wantErr: "line 1: only variable assignments (NAME=value) are supported"},
+		{content: "export\n", wantErr: "line 1: only variable assignments (NAME=value) are supported"},
+		{content: "export KEY", wantErr: "line 1: only variable assignments (NAME=value) are supported"},
+		{content: "KEY=`whoami`", wantErr: "line 1: command substitution in the value of KEY is not supported"},
+		{content: "KEY=\"$(whoami)\"", wantErr: "line 1: command substitution in the value of KEY is not supported"},
+		{content: "KEY=a|b", wantErr: "line 1: unsupported shell syntax in the value of KEY: '|'"},
+		{content: "KEY=\"value", wantErr: "line 1: unterminated double-quoted value of KEY"},
+	} {
+		t.Run(tc.content, func(t *testing.T) {
+			_, err := ParseShell(tc.content)
+			require.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/bitrise-io/envman/v2/models"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultSeparator joins the keys of the nested maps if Options.Separator is not set.
+const DefaultSeparator = "_"
+
+// ParseJSON parses a JSON object, see ParseYAML.
+// Flat objects of string values (like the output of envman print --format json) are imported in the order of their keys.
+func ParseJSON(content []byte, opts Options) ([]Entry, error) {
+	if !json.Valid(content) {
+		var value interface{}
+		return nil, fmt.Errorf("invalid JSON: %s", json.Unmarshal(content, &value))
+	}
+	if list, err := models.NewEnvJSONList(string(content)); err == nil {
+		return envJSONListEntries(list)
+	}
+	// JSON is valid YAML, parsing it as YAML keeps the order of the keys
+	return ParseYAML(content, opts)
+}
+
+func envJSONListEntries(list models.EnvsJSONListModel) ([]Entry, error) {
+	keys := make([]string, 0, len(list))
+	for key := range list {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var entries []Entry
+	for _, key := range keys {
+		if !isName(key) {
+			return nil, fmt.Errorf("invalid env var name (%s): %s", key, invalidNameReason)
+		}
+		entries = append(entries, Entry{Key: key, Value: list[key]})
+	}
+	return entries, nil
+}
+
+// ParseYAML parses a YAML map: the keys of the nested maps are joined by opts.Separator,
+// lists are imported as JSON, null values as empty strings. The joined keys have to be valid env var names.
+func ParseYAML(content []byte, opts Options) ([]Entry, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, nil
+	}
+	if _, ok := document.(map[interface{}]interface{}); !ok {
+		return nil, errors.New("the document is not a map")
+	}
+
+	// the maps are decoded as MapSlices (recursively) if the document is decoded into a MapSlice
+	var m yaml.MapSlice
+	if err := yaml.Unmarshal(content, &m); err != nil {
+		return nil, err
+	}
+	return flattenMap(nil, "", m, opts.separator())
+}
+
+func flattenMap(entries []Entry, prefix string, m yaml.MapSlice, separator string) ([]Entry, error) {
+	for _, item := range m {
+		key := prefix + fmt.Sprint(item.Key)
+		if nested, ok := item.Value.(yaml.MapSlice); ok {
+			var err error
+			entries, err = flattenMap(entries, key+separator, nested, separator)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !isName(key) {
+			return nil, fmt.Errorf("invalid env var name (%s): %s", key, invalidNameReason)
+		}
+		value, err := scalarValue(item.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %s", key, err)
+		}
+		entries = append(entries, Entry{Key: key, Value: value})
+	}
+	return entries, nil
+}
+
+// scalarValue returns the value of an env var: strings as they are, numbers and booleans formatted,
+// lists encoded as JSON.
+func scalarValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case []interface{}:
+		bytes, err := json.Marshal(jsonCompatible(value))
+		if err != nil {
+			return "", err
+		}
+		return string(bytes), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// jsonCompatible converts the YAML maps (with interface{} keys) to JSON encodable maps.
+func jsonCompatible(value interface{}) interface{} {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		m := map[string]interface{}{}
+		for _, item := range value {
+			m[fmt.Sprint(item.Key)] = jsonCompatible(item.Value)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = jsonCompatible(item)
+		}
+		return list
+	default:
+		return value
+	}
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSON(t *testing.T) {
+	content := `{
+  "APP_NAME": "envman",
+  "database": {"host": "localhost", "port": 5432, "options": {"ssl": true}},
+  "RATIO": 1.5,
+  "EMPTY": null,
+  "TAGS": ["ci", {"name": "cd"}]
+}`
+
+	entries, err := ParseJSON([]byte(content), Options{})
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{Key: "APP_NAME", Value: "envman"},
+		{Key: "database_host", Value: "localhost"},
+		{Key: "database_port", Value: "5432"},
+		{Key: "database_options_ssl", Value: "true"},
+		{Key: "RATIO", Value: "1.5"},
+		{Key: "EMPTY", Value: ""},
+		{Key: "TAGS", Value: `["ci",{"name":"cd"}]`},
+	}, entries)
+
+	entries, err = Parse(FormatJSON, []byte(`{"db": {"host": "localhost"}}`), Options{Separator: "__", Prefix: "APP_"})
+	require.NoError(t, err)
+	require.Equal(t, []Entry{{Key: "APP_db__host", Value: "localhost"}}, entries)
+
+	_, err = ParseJSON([]byte(`{"A": }`), Options{})
+	require.EqualError(t, err, "invalid JSON: invalid character '}' looking for beginning of value")
+
+	_, err = ParseJSON([]byte(`["A"]`), Options{})
+	require.EqualError(t, err, "the document is not a map")
+
+	_, err = ParseJSON([]byte(`{"app": {"log-level": "debug"}}`), Options{})
+	require.EqualError(t, err, "invalid env var name (app_log-level): only letters, digits and underscores are allowed, and the first character can't be a digit")
+
+	_, err = Parse(FormatJSON, []byte(`{"HOST": "localhost"}`), Options{Prefix: "my-"})
+	require.EqualError(t, err, "invalid prefix (my-): only letters, digits and underscores are allowed, and the first character can't be a digit")
+}
+
+func TestParseJSON_EnvList(t *testing.T) {
+	// the output of envman print --format json
+	entries, err := ParseJSON([]byte(`{"PORT": "8080", "HOST": "localhost", "EMPTY": null}`), Options{})
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{Key: "EMPTY", Value: ""},
+		{Key: "HOST", Value: "localhost"},
+		{Key: "PORT", Value: "8080"},
+	}, entries)
+
+	_, err = ParseJSON([]byte(`{"app.properties": "a=b"}`), Options{})
+	require.EqualError(t, err, "invalid env var name (app.properties): only letters, digits and underscores are allowed, and the first character can't be a digit")
+}
+
+func TestParseYAML(t *testing.T) {
+	content := `APP_NAME: envman
+database:
+  host: localhost
+  port: 5432
+VERSION: "1.10"
+`
+
+	entries, err := ParseYAML([]byte(content), Options{Separator: "__"})
+	require.NoError(t, err)
+	require.Equal(t, []Entry{
+		{Key: "APP_NAME", Value: "envman"},
+		{Key: "database__host", Value: "localhost"},
+		{Key: "database__port", Value: "5432"},
+		{Key: "VERSION", Value: "1.10"},
+	}, entries)
+
+	_, err = ParseYAML([]byte(content), Options{Separator: "."})
+	require.EqualError(t, err, "invalid env var name (database.host): only letters, digits and underscores are allowed, and the first character can't be a digit")
+
+	_, err = ParseYAML([]byte("my-key: value\n"), Options{})
+	require.EqualError(t, err, "invalid env var name (my-key): only letters, digits and underscores are allowed, and the first character can't be a digit")
+
+	entries, err = ParseYAML([]byte(""), Options{})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(entries))
+}
@@ -5,6 +5,35 @@ type EnvironmentItemOptionsModel struct {
 	// These fields are processed by envman at envman run
 	IsExpand    *bool `json:"is_expand,omitempty" yaml:"is_expand,omitempty"`
 	SkipIfEmpty *bool `json:"skip_if_empty,omitempty" yaml:"skip_if_empty,omitempty"`
+	// Expansion selects how the value is expanded (if IsExpand is set): ExpansionSimple (the default), ExpansionBash
+	// or ExpansionTemplate (envman renders the IsTemplate values only with ExpansionTemplate)
+	Expansion *string `json:"expansion,omitempty" yaml:"expansion,omitempty"`
+	// Strict selects how the undefined references of the value are handled: StrictOff (the default), StrictWarn or StrictError
+	Strict *string `json:"strict,omitempty" yaml:"strict,omitempty"`
+	// ValueFrom is the source of the value, resolved at evaluation time (the declared value is ignored)
+	ValueFrom *ValueSourceModel `json:"value_from,omitempty" yaml:"value_from,omitempty"`
+	// Transforms are applied in order on the evaluated value (after the expansion)
+	Transforms []TransformModel `json:"transforms,omitempty" yaml:"transforms,omitempty"`
+	// Type is the type of the value (TypeString by default), the value is validated and normalized by its type
+	// (the values of TypeEnum are listed in ValueOptions)
+	Type *string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Operation is a list operation (OperationPrepend, OperationAppend or OperationRemove) with the items of the evaluated value,
+	// on the current value of a separator delimited list env var (like PATH)
+	Operation *string `json:"operation,omitempty" yaml:"operation,omitempty"`
+	// Separator is the separator of the list items (DefaultListSeparator by default)
+	Separator *string `json:"separator,omitempty" yaml:"separator,omitempty"`
+	// Dedupe removes the duplicated items of the list after the operation (the first occurrence is kept)
+	Dedupe *bool `json:"dedupe,omitempty" yaml:"dedupe,omitempty"`
+	// Pattern is a regular expression, which the evaluated value has to match
+	Pattern *string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	// MinLength and MaxLength are the bounds of the evaluated value's length (in characters)
+	MinLength *int `json:"min_length,omitempty" yaml:"min_length,omitempty"`
+	MaxLength *int `json:"max_length,omitempty" yaml:"max_length,omitempty"`
+	// Min and Max are the bounds of the evaluated numeric value
+	Min *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	// IsTemplate renders the value as a Go text/template (instead of expanding it), with the env vars declared so far as data
+	IsTemplate *bool `json:"is_template,omitempty" yaml:"is_template,omitempty"`
 	// These fields used only by bitrise
 	Title             *string  `json:"title,omitempty" yaml:"title,omitempty"`
 	Description       *string  `json:"description,omitempty" yaml:"description,omitempty"`
@@ -13,7 +42,6 @@ type EnvironmentItemOptionsModel struct {
 	ValueOptions      []string `json:"value_options,omitempty" yaml:"value_options,omitempty"`
 	IsRequired        *bool    `json:"is_required,omitempty" yaml:"is_required,omitempty"`
 	IsDontChangeValue *bool    `json:"is_dont_change_value,omitempty" yaml:"is_dont_change_value,omitempty"`
-	IsTemplate        *bool    `json:"is_template,omitempty" yaml:"is_template,omitempty"`
 	IsSensitive       *bool    `json:"is_sensitive,omitempty" yaml:"is_sensitive,omitempty"`
 	Unset             *bool    `json:"unset,omitempty" yaml:"unset,omitempty"`
 	//
@@ -25,7 +53,55 @@ type EnvironmentItemModel map[string]interface{}
 
 // EnvsSerializeModel ...
 type EnvsSerializeModel struct {
-	Envs []EnvironmentItemModel `json:"envs" yaml:"envs"`
+	// FormatVersion is the version of the envstore layout, envstores without it are version 0
+	FormatVersion int `json:"format_version,omitempty" yaml:"format_version,omitempty"`
+	// Recipients are the public keys the sensitive values are encrypted for
+	Recipients []string `json:"recipients,omitempty" yaml:"recipients,omitempty"`
+	// Include lists the envstores (relative paths or globs) whose envs are declared before the envstore's own envs
+	Include []EnvstoreIncludeModel `json:"include,omitempty" yaml:"include,omitempty"`
+	// Defaults are the default options of the envstore's own envs (the envs of its includes use the included envstore's defaults)
+	Defaults *EnvstoreDefaultsModel `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	Envs     []EnvironmentItemModel `json:"envs" yaml:"envs"`
+}
+
+// EnvstoreIncludeModel is an include of an envstore, it's either a path (or glob) string,
+// or a map with the path and the optional flag in the YAML.
+type EnvstoreIncludeModel struct {
+	Path string `json:"path" yaml:"path"`
+	// Optional includes are skipped if no envstore matches the path
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+// ValueSourceModel is the source of an env var's value, one of the file, the envstore (with the key) or the command is set.
+type ValueSourceModel struct {
+	// File is the path of the file, which content is the value
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Envstore is the path (or location) of the envstore, which evaluated Key is the value
+	Envstore string `json:"envstore,omitempty" yaml:"envstore,omitempty"`
+	Key      string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Command is a shell command, which trimmed stdout is the value
+	Command string `json:"command,omitempty" yaml:"command,omitempty"`
+	// TimeoutInSecs limits the run of the command, DefaultValueSourceTimeoutInSecs is used if it's not set
+	TimeoutInSecs int `json:"timeout_in_secs,omitempty" yaml:"timeout_in_secs,omitempty"`
+}
+
+// TransformModel is a transform of an env var's value, it's either a name string (like trim),
+// or a map with the name and the arguments of the transform in the YAML.
+type TransformModel struct {
+	Name string `json:"name" yaml:"name"`
+	// Path is the argument of the json_path transform, like .data.items[0].token
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Old and New are the arguments of the replace transform
+	Old string `json:"old,omitempty" yaml:"old,omitempty"`
+	New string `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+// EnvstoreDefaultsModel holds the options applied to the envs of an envstore, which don't set the option themselves.
+type EnvstoreDefaultsModel struct {
+	Expansion string `json:"expansion,omitempty" yaml:"expansion,omitempty"`
+	Strict    string `json:"strict,omitempty" yaml:"strict,omitempty"`
+	// Resolution is not an env option, it's the resolution of the envstore's evaluation (including its includes)
+	Resolution string `json:"resolution,omitempty" yaml:"resolution,omitempty"`
 }
 
 // EnvsJSONListModel ...
@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/bitrise-io/go-utils/pointers"
 	"github.com/bitrise-io/go-utils/v2/parseutil"
@@ -15,6 +19,19 @@ const (
 	OptionsKey = "opts"
 )
 
+const (
+	// CurrentEnvstoreFormatVersion is the latest envstore format version written by this envman version,
+	// envstores with a newer format version are refused.
+	CurrentEnvstoreFormatVersion = 3
+	// EvaluationOptionsFormatVersion is the format version of the envstores using include, defaults
+	// or the evaluation options of the envs (like value_from or type), older envman versions would ignore them.
+	// The other envstores are written with format version 1.
+	EvaluationOptionsFormatVersion = 2
+	// DollarEscapeFormatVersion is the format version of the envstores using $$ (an escaped dollar sign) in a value
+	// of the simple expansion. In the envstores of older format versions $$ expands to an empty string (like with os.Expand).
+	DollarEscapeFormatVersion = 3
+)
+
 const (
 	// DefaultIsExpand ...
 	DefaultIsExpand = true
@@ -31,6 +48,114 @@ const (
 	DefaultIsTemplate = false
 	// DefaultUnset ...
 	DefaultUnset = false
+	// DefaultValueSourceTimeoutInSecs ...
+	DefaultValueSourceTimeoutInSecs = 10
+)
+
+const (
+	// ExpansionSimple expands the $VAR and ${VAR} references of a value, like os.Expand
+	ExpansionSimple = "simple"
+	// ExpansionBash supports the bash parameter expansion operators as well, like ${VAR:-default} or ${VAR#prefix}
+	ExpansionBash = "bash"
+	// ExpansionTemplate renders the values of the envs with IsTemplate as Go text/templates,
+	// the other values are expanded like with ExpansionSimple
+	ExpansionTemplate = "template"
+)
+
+const (
+	// StrictOff expands the undefined references of a value to empty strings
+	StrictOff = "off"
+	// StrictWarn reports the undefined references of a value, without failing the evaluation
+	StrictWarn = "warn"
+	// StrictError fails the evaluation, if a value references undefined env vars
+	StrictError = "error"
+)
+
+const (
+	// TransformTrim removes the leading and trailing white space
+	TransformTrim = "trim"
+	// TransformTrimNewline removes the trailing newlines (\n and \r\n)
+	TransformTrimNewline = "trim_newline"
+	// TransformBase64Encode encodes the value with the standard base64 encoding
+	TransformBase64Encode = "base64_encode"
+	// TransformBase64Decode decodes the standard base64 encoded value
+	TransformBase64Decode = "base64_decode"
+	// TransformUpper converts the value to upper case
+	TransformUpper = "upper"
+	// TransformLower converts the value to lower case
+	TransformLower = "lower"
+	// TransformJSONPath extracts the field at the path from the JSON value
+	TransformJSONPath = "json_path"
+	// TransformReplace replaces the occurrences of old with new
+	TransformReplace = "replace"
+	// TransformPath normalizes the file path value (expands the leading ~ and cleans the path)
+	TransformPath = "path"
+)
+
+// Transforms are the supported transforms.
+var Transforms = []string{TransformTrim, TransformTrimNewline, TransformBase64Encode, TransformBase64Decode,
+	TransformUpper, TransformLower, TransformJSONPath, TransformReplace, TransformPath}
+
+const (
+	// TypeString is any string value
+	TypeString = "string"
+	// TypeInt is a (base 10) integer
+	TypeInt = "int"
+	// TypeBool is a boolean, normalized to true or false (yes, no, on, off, 1 and 0 are accepted too)
+	TypeBool = "bool"
+	// TypeFloat is a floating point number
+	TypeFloat = "float"
+	// TypeURL is an absolute URL, with a scheme and a host
+	TypeURL = "url"
+	// TypePath is a file path, normalized to its cleaned form
+	TypePath = "path"
+	// TypeDuration is a Go duration (like 1m30s)
+	TypeDuration = "duration"
+	// TypeEnum is one of the values listed in the value_options
+	TypeEnum = "enum"
+	// TypeJSON is a JSON document, normalized to its compact form
+	TypeJSON = "json"
+)
+
+// Types are the supported value types.
+var Types = []string{TypeString, TypeInt, TypeBool, TypeFloat, TypeURL, TypePath, TypeDuration, TypeEnum, TypeJSON}
+
+// ValidateType returns an error if the value type is not supported.
+func ValidateType(valueType string) error {
+	for _, supported := range Types {
+		if valueType == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown type (%s), supported types: %s", valueType, strings.Join(Types, ", "))
+}
+
+const (
+	// OperationPrepend adds the items to the beginning of the list
+	OperationPrepend = "prepend"
+	// OperationAppend adds the items to the end of the list
+	OperationAppend = "append"
+	// OperationRemove removes the items from the list
+	OperationRemove = "remove"
+	// DefaultListSeparator is the separator of the list items, if the env doesn't set it (like in PATH)
+	DefaultListSeparator = ":"
+)
+
+// ValidateOperation returns an error if the list operation is not supported.
+func ValidateOperation(operation string) error {
+	switch operation {
+	case OperationPrepend, OperationAppend, OperationRemove:
+		return nil
+	default:
+		return fmt.Errorf("unknown operation (%s), supported operations: %s, %s, %s", operation, OperationPrepend, OperationAppend, OperationRemove)
+	}
+}
+
+const (
+	// ResolutionOrdered evaluates the declarations in order, a value can only reference the preceding declarations
+	ResolutionOrdered = "ordered"
+	// ResolutionGraph evaluates the declarations in the order of their references
+	ResolutionGraph = "graph"
 )
 
 func NewEnvJSONList(jsonStr string) (EnvsJSONListModel, error) {
@@ -164,17 +289,152 @@ func (env EnvironmentItemModel) Normalize() error {
 }
 
 func (env EnvironmentItemModel) Validate() error {
-	_, _, err := env.GetKeyValuePair()
+	key, _, err := env.GetKeyValuePair()
 	if err != nil {
 		return err
 	}
-	_, err = env.GetOptions()
+	options, err := env.GetOptions()
 	if err != nil {
 		return err
 	}
+	if options.Expansion != nil {
+		if err := ValidateExpansion(*options.Expansion); err != nil {
+			return fmt.Errorf("invalid options of env var (%s): %s", key, err)
+		}
+	}
+	if options.Strict != nil {
+		if err := ValidateStrict(*options.Strict); err != nil {
+			return fmt.Errorf("invalid options of env var (%s): %s", key, err)
+		}
+	}
+	if options.ValueFrom != nil {
+		if err := options.ValueFrom.Validate(); err != nil {
+			return fmt.Errorf("invalid options of env var (%s): %s", key, err)
+		}
+	}
+	for _, transform := range options.Transforms {
+		if err := transform.Validate(); err != nil {
+			return fmt.Errorf("invalid options of env var (%s): %s", key, err)
+		}
+	}
+	if options.Operation != nil {
+		if err := ValidateOperation(*options.Operation); err != nil {
+			return fmt.Errorf("invalid options of env var (%s): %s", key, err)
+		}
+	}
+	if options.Separator != nil && *options.Separator == "" {
+		return fmt.Errorf("invalid options of env var (%s): empty separator", key)
+	}
+	if err := options.validateConstraints(); err != nil {
+		return fmt.Errorf("invalid options of env var (%s): %s", key, err)
+	}
+	if options.Type != nil {
+		if err := ValidateType(*options.Type); err != nil {
+			return fmt.Errorf("invalid options of env var (%s): %s", key, err)
+		}
+		if *options.Type == TypeEnum && len(options.ValueOptions) == 0 {
+			return fmt.Errorf("invalid options of env var (%s): type (%s) requires value_options", key, TypeEnum)
+		}
+	}
+	return nil
+}
+
+// validateConstraints returns an error if the pattern is not a valid regular expression, or the bounds are invalid.
+func (options EnvironmentItemOptionsModel) validateConstraints() error {
+	if options.Pattern != nil {
+		if _, err := regexp.Compile(*options.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %s", err)
+		}
+	}
+	if options.MinLength != nil && *options.MinLength < 0 {
+		return fmt.Errorf("invalid min_length: %d", *options.MinLength)
+	}
+	if options.MaxLength != nil && *options.MaxLength < 0 {
+		return fmt.Errorf("invalid max_length: %d", *options.MaxLength)
+	}
+	if options.MinLength != nil && options.MaxLength != nil && *options.MinLength > *options.MaxLength {
+		return fmt.Errorf("min_length (%d) is greater than max_length (%d)", *options.MinLength, *options.MaxLength)
+	}
+	if options.Min != nil && options.Max != nil && *options.Min > *options.Max {
+		return fmt.Errorf("min (%v) is greater than max (%v)", *options.Min, *options.Max)
+	}
+	return nil
+}
+
+// Validate returns an error if the transform is not supported, or its arguments are missing.
+func (transform TransformModel) Validate() error {
+	switch transform.Name {
+	case TransformJSONPath:
+		if transform.Path == "" {
+			return fmt.Errorf("transform (%s) requires a path", transform.Name)
+		}
+	case TransformReplace:
+		if transform.Old == "" {
+			return fmt.Errorf("transform (%s) requires old", transform.Name)
+		}
+	case TransformTrim, TransformTrimNewline, TransformBase64Encode, TransformBase64Decode, TransformUpper, TransformLower, TransformPath:
+	default:
+		return fmt.Errorf("unknown transform (%s), supported transforms: %s", transform.Name, strings.Join(Transforms, ", "))
+	}
 	return nil
 }
 
+// Validate returns an error if not exactly one source is set.
+func (source ValueSourceModel) Validate() error {
+	sources := 0
+	for _, set := range []bool{source.File != "", source.Envstore != "", source.Command != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("value_from should set exactly one of file, envstore or command")
+	}
+	if source.Envstore != "" && source.Key == "" {
+		return errors.New("value_from envstore requires a key")
+	}
+	if source.Envstore == "" && source.Key != "" {
+		return errors.New("value_from key is only supported with envstore")
+	}
+	if source.TimeoutInSecs < 0 {
+		return fmt.Errorf("invalid value_from timeout: %d", source.TimeoutInSecs)
+	}
+	if source.Command == "" && source.TimeoutInSecs != 0 {
+		return errors.New("value_from timeout is only supported with command")
+	}
+	return nil
+}
+
+// ValidateExpansion returns an error if the expansion is not supported.
+func ValidateExpansion(expansion string) error {
+	switch expansion {
+	case ExpansionSimple, ExpansionBash, ExpansionTemplate:
+		return nil
+	default:
+		return fmt.Errorf("unknown expansion (%s), supported expansions: %s, %s, %s", expansion, ExpansionSimple, ExpansionBash, ExpansionTemplate)
+	}
+}
+
+// ValidateResolution returns an error if the resolution is not supported.
+func ValidateResolution(resolution string) error {
+	switch resolution {
+	case ResolutionOrdered, ResolutionGraph:
+		return nil
+	default:
+		return fmt.Errorf("unknown resolution (%s), supported resolutions: %s, %s", resolution, ResolutionOrdered, ResolutionGraph)
+	}
+}
+
+// ValidateStrict returns an error if the strict mode is not supported.
+func ValidateStrict(strict string) error {
+	switch strict {
+	case StrictOff, StrictWarn, StrictError:
+		return nil
+	default:
+		return fmt.Errorf("unknown strict mode (%s), supported strict modes: %s, %s, %s", strict, StrictOff, StrictWarn, StrictError)
+	}
+}
+
 func (env EnvironmentItemModel) FillMissingDefaults() error {
 	options, err := env.GetOptions()
 	if err != nil {
@@ -293,12 +553,62 @@ func (envSerModel *EnvironmentItemOptionsModel) ParseFromInterfaceMap(input map[
 				return fmt.Errorf("failed to parse bool value (%#v) for key (%s)", value, keyStr)
 			}
 			envSerModel.SkipIfEmpty = castedBoolPtr
+		case "expansion":
+			envSerModel.Expansion = parseutil.StringPtrFrom(value)
+		case "strict":
+			envSerModel.Strict = parseutil.StringPtrFrom(value)
+		case "type":
+			envSerModel.Type = parseutil.StringPtrFrom(value)
+		case "operation":
+			envSerModel.Operation = parseutil.StringPtrFrom(value)
+		case "separator":
+			envSerModel.Separator = parseutil.StringPtrFrom(value)
+		case "pattern":
+			envSerModel.Pattern = parseutil.StringPtrFrom(value)
+		case "min_length", "max_length":
+			castedIntPtr, ok := intPtrFrom(value)
+			if !ok {
+				return fmt.Errorf("failed to parse int value (%#v) for key (%s)", value, keyStr)
+			}
+			if keyStr == "min_length" {
+				envSerModel.MinLength = castedIntPtr
+			} else {
+				envSerModel.MaxLength = castedIntPtr
+			}
+		case "min", "max":
+			castedFloatPtr, ok := float64PtrFrom(value)
+			if !ok {
+				return fmt.Errorf("failed to parse number value (%#v) for key (%s)", value, keyStr)
+			}
+			if keyStr == "min" {
+				envSerModel.Min = castedFloatPtr
+			} else {
+				envSerModel.Max = castedFloatPtr
+			}
+		case "dedupe":
+			castedBoolPtr, ok := parseutil.BoolPtrFrom(value)
+			if !ok {
+				return fmt.Errorf("failed to parse bool value (%#v) for key (%s)", value, keyStr)
+			}
+			envSerModel.Dedupe = castedBoolPtr
 		case "unset":
 			castedBoolPtr, ok := parseutil.BoolPtrFrom(value)
 			if !ok {
 				return fmt.Errorf("failed to parse bool value (%#v) for key (%s)", value, keyStr)
 			}
 			envSerModel.Unset = castedBoolPtr
+		case "transforms":
+			transforms, err := parseTransforms(value)
+			if err != nil {
+				return fmt.Errorf("failed to parse transforms: %s", err)
+			}
+			envSerModel.Transforms = transforms
+		case "value_from":
+			source, err := parseValueSource(value)
+			if err != nil {
+				return fmt.Errorf("failed to parse value_from: %s", err)
+			}
+			envSerModel.ValueFrom = source
 		case "meta":
 			metaValue, err := convertMetaValue(value)
 			if err != nil {
@@ -326,6 +636,107 @@ func (envsSerializeObj *EnvsSerializeModel) Normalize() error {
 	return nil
 }
 
+// intPtrFrom converts the YAML or JSON number (or numeric string) to an int.
+func intPtrFrom(value interface{}) (*int, bool) {
+	f, ok := float64PtrFrom(value)
+	if !ok || *f != math.Trunc(*f) {
+		return nil, false
+	}
+	i := int(*f)
+	return &i, true
+}
+
+// float64PtrFrom converts the YAML or JSON number (or numeric string) to a float64.
+func float64PtrFrom(value interface{}) (*float64, bool) {
+	var f float64
+	switch v := value.(type) {
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case float64:
+		f = v
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, false
+		}
+		f = parsed
+	default:
+		return nil, false
+	}
+	return &f, true
+}
+
+func parseTransforms(value interface{}) ([]TransformModel, error) {
+	if transforms, ok := value.([]TransformModel); ok {
+		return transforms, nil
+	}
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("transforms is not a list: %#v", value)
+	}
+
+	var transforms []TransformModel
+	for _, item := range items {
+		if name, ok := item.(string); ok {
+			transforms = append(transforms, TransformModel{Name: name})
+			continue
+		}
+
+		converted, err := recursiveConvertToStringKeyedMap(item)
+		if err != nil {
+			return nil, err
+		}
+		fields, ok := converted.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("transform is neither a name nor a map: %#v", item)
+		}
+		bytes, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		var transform TransformModel
+		if err := json.Unmarshal(bytes, &transform); err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, transform)
+	}
+	return transforms, nil
+}
+
+func parseValueSource(value interface{}) (*ValueSourceModel, error) {
+	switch source := value.(type) {
+	case *ValueSourceModel:
+		return source, nil
+	case ValueSourceModel:
+		return &source, nil
+	}
+
+	converted, err := recursiveConvertToStringKeyedMap(value)
+	if err != nil {
+		return nil, err
+	}
+	fields, ok := converted.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value_from is not a map: %#v", value)
+	}
+
+	// the map is converted through JSON, so that both the YAML and the JSON types of the numbers are accepted
+	bytes, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var source ValueSourceModel
+	if err := json.Unmarshal(bytes, &source); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
 func convertMetaValue(metaValue any) (map[string]any, error) {
 	converted, err := recursiveConvertToStringKeyedMap(metaValue)
 	if err != nil {
@@ -382,3 +793,54 @@ func recursiveConvertToStringKeyedMap(source interface{}) (interface{}, error) {
 
 	return source, nil
 }
+
+// UnmarshalYAML accepts both the short (path only) and the long form of an include.
+func (include *EnvstoreIncludeModel) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var pth string
+	if err := unmarshal(&pth); err == nil {
+		*include = EnvstoreIncludeModel{Path: pth}
+		return nil
+	}
+
+	type includeModel EnvstoreIncludeModel
+	var model includeModel
+	if err := unmarshal(&model); err != nil {
+		return err
+	}
+	if model.Path == "" {
+		return errors.New("include path is not specified")
+	}
+
+	*include = EnvstoreIncludeModel(model)
+	return nil
+}
+
+// MarshalYAML writes the short form (the name) of the transforms without arguments.
+func (transform TransformModel) MarshalYAML() (interface{}, error) {
+	if transform == (TransformModel{Name: transform.Name}) {
+		return transform.Name, nil
+	}
+
+	type transformModel TransformModel
+	return transformModel(transform), nil
+}
+
+// MarshalJSON writes the short form (the name) of the transforms without arguments.
+func (transform TransformModel) MarshalJSON() ([]byte, error) {
+	if transform == (TransformModel{Name: transform.Name}) {
+		return json.Marshal(transform.Name)
+	}
+
+	type transformModel TransformModel
+	return json.Marshal(transformModel(transform))
+}
+
+// MarshalYAML writes the short form of the include, unless it's optional.
+func (include EnvstoreIncludeModel) MarshalYAML() (interface{}, error) {
+	if !include.Optional {
+		return include.Path, nil
+	}
+
+	type includeModel EnvstoreIncludeModel
+	return includeModel(include), nil
+}
@@ -492,6 +492,110 @@ func TestValidate(t *testing.T) {
 		"test_key": "test_value",
 	}
 	require.NoError(t, env.Validate())
+
+	// Unknown expansion
+	env = EnvironmentItemModel{
+		"test_key": "test_value",
+		OptionsKey: map[string]interface{}{"expansion": "zsh"},
+	}
+	require.EqualError(t, env.Validate(), "invalid options of env var (test_key): unknown expansion (zsh), supported expansions: simple, bash, template")
+
+	// Unknown strict mode
+	env = EnvironmentItemModel{
+		"test_key": "test_value",
+		OptionsKey: map[string]interface{}{"strict": "on"},
+	}
+	require.EqualError(t, env.Validate(), "invalid options of env var (test_key): unknown strict mode (on), supported strict modes: off, warn, error")
+
+	// Value source
+	env = EnvironmentItemModel{
+		"test_key": "",
+		OptionsKey: map[interface{}]interface{}{"value_from": map[interface{}]interface{}{"command": "git rev-parse HEAD", "timeout_in_secs": 5}},
+	}
+	require.NoError(t, env.Validate())
+	opts, err := env.GetOptions()
+	require.NoError(t, err)
+	require.Equal(t, &ValueSourceModel{Command: "git rev-parse HEAD", TimeoutInSecs: 5}, opts.ValueFrom)
+
+	// Invalid value sources
+	for source, wantErr := range map[string]string{
+		`{}`: "value_from should set exactly one of file, envstore or command",
+		`{"file": "a.txt", "command": "cat a.txt"}`:  "value_from should set exactly one of file, envstore or command",
+		`{"envstore": "shared.yml"}`:                 "value_from envstore requires a key",
+		`{"file": "a.txt", "key": "A"}`:              "value_from key is only supported with envstore",
+		`{"file": "a.txt", "timeout_in_secs": 5}`:    "value_from timeout is only supported with command",
+		`{"command": "date", "timeout_in_secs": -1}`: "invalid value_from timeout: -1",
+	} {
+		var valueFrom map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(source), &valueFrom))
+		env = EnvironmentItemModel{"test_key": "", OptionsKey: map[string]interface{}{"value_from": valueFrom}}
+		require.EqualError(t, env.Validate(), "invalid options of env var (test_key): "+wantErr, source)
+	}
+
+	// Transforms
+	env = EnvironmentItemModel{
+		"test_key": "",
+		OptionsKey: map[interface{}]interface{}{"transforms": []interface{}{
+			"trim",
+			map[interface{}]interface{}{"name": "json_path", "path": ".data.token"},
+			map[interface{}]interface{}{"name": "replace", "old": "-", "new": "_"},
+		}},
+	}
+	require.NoError(t, env.Validate())
+	opts, err = env.GetOptions()
+	require.NoError(t, err)
+	require.Equal(t, []TransformModel{
+		{Name: "trim"},
+		{Name: "json_path", Path: ".data.token"},
+		{Name: "replace", Old: "-", New: "_"},
+	}, opts.Transforms)
+
+	bytes, err := json.Marshal(opts.Transforms)
+	require.NoError(t, err)
+	require.Equal(t, `["trim",{"name":"json_path","path":".data.token"},{"name":"replace","old":"-","new":"_"}]`, string(bytes))
+
+	// Value constraint rules
+	env = EnvironmentItemModel{
+		"test_key": "",
+		OptionsKey: map[interface{}]interface{}{"pattern": "^[a-z]+$", "min_length": 1, "max_length": "8", "min": 0.5, "max": 10},
+	}
+	require.NoError(t, env.Validate())
+	opts, err = env.GetOptions()
+	require.NoError(t, err)
+	require.Equal(t, "^[a-z]+$", *opts.Pattern)
+	require.Equal(t, 1, *opts.MinLength)
+	require.Equal(t, 8, *opts.MaxLength)
+	require.Equal(t, 0.5, *opts.Min)
+	require.Equal(t, 10.0, *opts.Max)
+
+	// Invalid value constraint rules
+	for rules, wantErr := range map[string]string{
+		`{"pattern": "[a-"}`:                 "invalid pattern: error parsing regexp: missing closing ]: `[a-`",
+		`{"min_length": -1}`:                 "invalid min_length: -1",
+		`{"min_length": 3, "max_length": 2}`: "min_length (3) is greater than max_length (2)",
+		`{"min": 3, "max": 2.5}`:             "min (3) is greater than max (2.5)",
+	} {
+		var options map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(rules), &options))
+		env = EnvironmentItemModel{"test_key": "", OptionsKey: options}
+		require.EqualError(t, env.Validate(), "invalid options of env var (test_key): "+wantErr, rules)
+	}
+
+	env = EnvironmentItemModel{"test_key": "", OptionsKey: map[string]interface{}{"min_length": 1.5}}
+	_, err = env.GetOptions()
+	require.EqualError(t, err, "failed to parse int value (1.5) for key (min_length)")
+
+	// Invalid transforms
+	for transform, wantErr := range map[string]string{
+		`"rot13"`:               "unknown transform (rot13), supported transforms: trim, trim_newline, base64_encode, base64_decode, upper, lower, json_path, replace, path",
+		`{"name": "json_path"}`: "transform (json_path) requires a path",
+		`{"name": "replace"}`:   "transform (replace) requires old",
+	} {
+		var transformValue interface{}
+		require.NoError(t, json.Unmarshal([]byte(transform), &transformValue))
+		env = EnvironmentItemModel{"test_key": "", OptionsKey: map[string]interface{}{"transforms": []interface{}{transformValue}}}
+		require.EqualError(t, env.Validate(), "invalid options of env var (test_key): "+wantErr, transform)
+	}
 }
 
 func Test_EnvsSerializeModel_Normalize(t *testing.T) {